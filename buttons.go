@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// buttonAction is the callback data sent by a button, so flows never depend on the button's label
+type buttonAction string
+
+const (
+	btnYes        buttonAction = "yes"
+	btnNo         buttonAction = "no"
+	btnExit       buttonAction = "exit"
+	btnCancel     buttonAction = "cancel"
+	btnKeep       buttonAction = "keep"
+	btnToss       buttonAction = "toss"
+	btnRevealAns  buttonAction = "reveal"
+	btnCorrect    buttonAction = "correct"
+	btnWrong      buttonAction = "wrong"
+	btnEndQuiz    buttonAction = "end"
+	btnMyQuiz     buttonAction = "my_quiz"
+	btnFriendQuiz buttonAction = "friend_quiz"
+	btnPrivate    buttonAction = "private"
+	btnShared     buttonAction = "shared"
+	btnPublic     buttonAction = "public"
+	btnAddEditors buttonAction = "add_editors"
+	btnDelEditors buttonAction = "remove_editors"
+	btnUnchanged  buttonAction = "unchanged"
+	btnRate1      buttonAction = "rate_1"
+	btnRate2      buttonAction = "rate_2"
+	btnRate3      buttonAction = "rate_3"
+	btnRate4      buttonAction = "rate_4"
+	btnRate5      buttonAction = "rate_5"
+	btnSkip       buttonAction = "skip"
+)
+
+// buttonLabels are the English labels of the buttons, translated for each chat
+var buttonLabels = map[buttonAction]string{
+	btnYes:        "Yes",
+	btnNo:         "No",
+	btnExit:       "Exit",
+	btnCancel:     "Cancel",
+	btnKeep:       "Keep",
+	btnToss:       "Toss",
+	btnRevealAns:  "Reveal Ans",
+	btnCorrect:    "Correct",
+	btnWrong:      "Wrong",
+	btnEndQuiz:    "End Quiz",
+	btnMyQuiz:     "My own quiz",
+	btnFriendQuiz: "A friend's quiz",
+	btnPrivate:    "Private",
+	btnShared:     "Shared",
+	btnPublic:     "Public",
+	btnAddEditors: "Add editors",
+	btnDelEditors: "Remove editors",
+	btnUnchanged:  "Keep current",
+	btnRate1:      "1 ★",
+	btnRate2:      "2 ★",
+	btnRate3:      "3 ★",
+	btnRate4:      "4 ★",
+	btnRate5:      "5 ★",
+	btnSkip:       "Skip",
+}
+
+// newActionButton makes a button labelled in the language of the chat. Its callback data is the action,
+// so translations never change what it does
+func newActionButton(chatID int64, action buttonAction) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(tr(chatID, buttonLabels[action]), "btn:"+string(action))
+}
+
+// editsInPlace reports whether the flow handling the button rewrites its message, instead of just removing its buttons
+func (action buttonAction) editsInPlace() bool {
+	switch action {
+	case btnRevealAns, btnCorrect, btnWrong, btnKeep, btnToss:
+		return true
+	}
+	return false
+}
+
+// parseCallbackData splits callback data such as "btn:yes" or "pick:3" into its kind and value
+func parseCallbackData(data string) (string, string) {
+	dataParts := strings.SplitN(data, ":", 2)
+	if len(dataParts) != 2 {
+		return "", ""
+	}
+	return dataParts[0], dataParts[1]
+}
+
+// callbackMessage makes a button press look like a message from the user, so it goes through the usual flow
+func callbackMessage(query *tgbotapi.CallbackQuery, text string) *tgbotapi.Message {
+	message := &tgbotapi.Message{
+		MessageID: query.Message.MessageID,
+		From:      query.From,
+		Chat:      query.Message.Chat,
+		Date:      query.Message.Date,
+		Text:      text,
+	}
+
+	if strings.HasPrefix(text, "/") {
+		commandLen := strings.Index(text, " ")
+		if commandLen < 0 {
+			commandLen = len(text)
+		}
+		message.Entities = []tgbotapi.MessageEntity{
+			{Type: "bot_command", Offset: 0, Length: commandLen},
+		}
+	}
+
+	return message
+}
+
+// sendPrompt sends a message with buttons and returns its ID, so that only the latest prompt's buttons are accepted.
+// Unlike queued messages it is waited for, but only while the messages queued to the chat before it are sent without pacing
+func sendPrompt(msg tgbotapi.MessageConfig, bot *tgbotapi.BotAPI) int {
+	sentMsg, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("An error has occurred trying to send message: %s", err)
+	}
+	return sentMsg.MessageID
+}
+
+func removeInlineKeyboard(chatID int64, messageID int, bot *tgbotapi.BotAPI) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+
+	queueMessage(bot, edit.ChatID, edit)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseCallbackData(t *testing.T) {
+	kind, value := parseCallbackData("btn:" + string(btnYes))
+	if kind != "btn" || buttonAction(value) != btnYes {
+		t.Error("Expected: btn yes but got: " + kind + " " + value)
+	}
+
+	kind, value = parseCallbackData("Yes")
+	if kind != "" || value != "" {
+		t.Error("Expected no kind or value for a label but got: " + kind + " " + value)
+	}
+}
+
+func TestCallbackMessage(t *testing.T) {
+	query := &tgbotapi.CallbackQuery{
+		From:    &tgbotapi.User{ID: 1},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}},
+	}
+
+	picker := &quizPicker{action: "remove_qns"}
+	message := callbackMessage(query, picker.selectionText("demo quiz"))
+
+	if !message.IsCommand() || message.Command() != "remove_qns" {
+		t.Error("Expected: remove_qns command but got: " + message.Text)
+	}
+	if commandParse(message.Text, "remove_qns") != "demo quiz" {
+		t.Error("Expected: demo quiz but got: " + commandParse(message.Text, "remove_qns"))
+	}
+
+	if callbackMessage(query, "").IsCommand() {
+		t.Error("Expected a button press not to be a command")
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// number of quizzes shown on each page of the catalog
+const catalogPageSize = 5
+
+// number of categories offered by /browse, the most used tags of public quizzes
+const catalogMaxCategories = 12
+
+// most public quizzes loaded for /browse and /search, the best ranked
+const catalogMaxEntries = 300
+
+// most characters of a quiz's questions and answers kept in the catalog to search
+const catalogSearchTextLimit = 20000
+
+// catalogEntry is a public quiz listed in the catalog
+type catalogEntry struct {
+	ownerID     string
+	quizName    string
+	tags        []string
+	numQns      int
+	attempts    int
+	ratingSum   int
+	ratingCount int
+	searchText  string // the title, tags, questions and answers in lower case
+}
+
+// catalogView is an open catalog message, showing categories until one is chosen and then a page of quizzes
+type catalogView struct {
+	public     []catalogEntry
+	categories []string
+	title      string
+	results    []catalogEntry
+	page       int
+}
+
+// public quizzes are listed in their owner's CATALOG collection, a small copy of what the catalog shows and
+// searches, so that the catalog is loaded without the questions of every public quiz
+func catalogDoc(client *firestore.Client, ownerID string, quizName string) *firestore.DocumentRef {
+	return client.Collection("USERS").Doc(ownerID).Collection("CATALOG").Doc(quizName)
+}
+
+// catalogEntryData returns the catalog's copy of the quiz
+func catalogEntryData(quizName string, quizData map[string]interface{}) map[string]interface{} {
+	entry := catalogEntry{quizName: quizName, tags: quizTags(quizData)}
+	if attempts, ok := quizData["attempts"].(int64); ok {
+		entry.attempts = int(attempts)
+	}
+	if ratingSum, ok := quizData["ratingSum"].(int64); ok {
+		entry.ratingSum = int(ratingSum)
+	}
+	if ratingCount, ok := quizData["ratingCount"].(int64); ok {
+		entry.ratingCount = int(ratingCount)
+	}
+
+	questions := quizQuestions(quizData)
+	var questionList []string
+	for question := range questions {
+		questionList = append(questionList, question)
+	}
+	sort.Strings(questionList)
+
+	searchText := strings.ToLower(quizName + "\n" + strings.Join(entry.tags, "\n"))
+	for _, question := range questionList {
+		if len(searchText) >= catalogSearchTextLimit {
+			break
+		}
+		searchText += "\n" + strings.ToLower(question+"\n"+questions[question])
+	}
+
+	return map[string]interface{}{
+		"quizName":    quizName,
+		"tags":        entry.tags,
+		"numQns":      len(questions),
+		"attempts":    entry.attempts,
+		"ratingSum":   entry.ratingSum,
+		"ratingCount": entry.ratingCount,
+		"rank":        entry.rank(),
+		"searchText":  searchText,
+	}
+}
+
+func newCatalogEntry(ownerID string, data map[string]interface{}) catalogEntry {
+	entry := catalogEntry{ownerID: ownerID, tags: quizTags(data)}
+	entry.quizName, _ = data["quizName"].(string)
+	entry.searchText, _ = data["searchText"].(string)
+	if numQns, ok := data["numQns"].(int64); ok {
+		entry.numQns = int(numQns)
+	}
+	if attempts, ok := data["attempts"].(int64); ok {
+		entry.attempts = int(attempts)
+	}
+	if ratingSum, ok := data["ratingSum"].(int64); ok {
+		entry.ratingSum = int(ratingSum)
+	}
+	if ratingCount, ok := data["ratingCount"].(int64); ok {
+		entry.ratingCount = int(ratingCount)
+	}
+	return entry
+}
+
+// loadPublicQuizzes returns the best ranked public quizzes of every user
+func loadPublicQuizzes(ctx context.Context, client *firestore.Client) ([]catalogEntry, error) {
+	docs, err := client.CollectionGroup("CATALOG").OrderBy("rank", firestore.Desc).Limit(catalogMaxEntries).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []catalogEntry
+	for _, doc := range docs {
+		entries = append(entries, newCatalogEntry(doc.Ref.Parent.Parent.ID, doc.Data()))
+	}
+	return entries, nil
+}
+
+// updateCatalogEntry keeps the catalog's copy of the quiz in step with it after a change, listing the quiz
+// while it is public and has questions
+func updateCatalogEntry(ctx context.Context, client *firestore.Client, ownerID string, quizName string) {
+	doc, err := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		log.Printf("An error has occurred trying to update catalog: %s", err)
+		return
+	}
+
+	if doc.Exists() && quizVisibility(doc.Data()) == visibilityPublic && len(quizQuestions(doc.Data())) > 0 {
+		_, err = catalogDoc(client, ownerID, quizName).Set(ctx, catalogEntryData(quizName, doc.Data()))
+	} else {
+		_, err = catalogDoc(client, ownerID, quizName).Delete(ctx)
+	}
+	if err != nil {
+		log.Printf("An error has occurred trying to update catalog: %s", err)
+	}
+}
+
+// fillCatalog lists the quizzes that were public before the catalog was kept, once, while it is still empty
+func fillCatalog(ctx context.Context, client *firestore.Client) {
+	listed, err := client.CollectionGroup("CATALOG").Limit(1).Documents(ctx).GetAll()
+	if err != nil || len(listed) > 0 {
+		if err != nil {
+			log.Printf("An error has occurred trying to load catalog: %s", err)
+		}
+		return
+	}
+
+	docs, err := client.CollectionGroup("QUIZZES").Where("visibility", "==", visibilityPublic).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load public quizzes: %s", err)
+		return
+	}
+	for _, doc := range docs {
+		if len(quizQuestions(doc.Data())) == 0 {
+			continue
+		}
+		if _, err := catalogDoc(client, doc.Ref.Parent.Parent.ID, doc.Ref.ID).Set(ctx, catalogEntryData(doc.Ref.ID, doc.Data())); err != nil {
+			log.Printf("An error has occurred trying to update catalog: %s", err)
+		}
+	}
+}
+
+// averageRating is the quiz's average rating out of 5, pulled towards 3 while it has few ratings
+func (entry catalogEntry) averageRating() float64 {
+	return float64(entry.ratingSum+3*2) / float64(entry.ratingCount+2)
+}
+
+// rank orders the catalog by rating, weighted by how often the quiz has been tried
+func (entry catalogEntry) rank() float64 {
+	return entry.averageRating() * math.Log2(float64(entry.attempts)+2)
+}
+
+func rankCatalog(entries []catalogEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].rank() > entries[j].rank()
+	})
+}
+
+// searchCatalog returns the quizzes whose title, tags or questions contain every keyword, best ranked first
+func searchCatalog(entries []catalogEntry, keywords string) []catalogEntry {
+	words := strings.Fields(strings.ToLower(keywords))
+
+	var results []catalogEntry
+	for _, entry := range entries {
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(entry.searchText, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, entry)
+		}
+	}
+
+	rankCatalog(results)
+	return results
+}
+
+// catalogCategories returns the tags used by the most public quizzes
+func catalogCategories(entries []catalogEntry) []string {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		for _, tag := range entry.tags {
+			counts[tag]++
+		}
+	}
+
+	var categories []string
+	for tag := range counts {
+		categories = append(categories, tag)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	if len(categories) > catalogMaxCategories {
+		categories = categories[:catalogMaxCategories]
+	}
+	return categories
+}
+
+func catalogCategory(entries []catalogEntry, category string) []catalogEntry {
+	var results []catalogEntry
+	for _, entry := range entries {
+		if category == "" || containsString(entry.tags, category) {
+			results = append(results, entry)
+		}
+	}
+
+	rankCatalog(results)
+	return results
+}
+
+func (view *catalogView) numPages() int {
+	return (len(view.results) + catalogPageSize - 1) / catalogPageSize
+}
+
+func (view *catalogView) categoriesKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+
+	// buttons refer to categories by index, -1 being every public quiz
+	for i, category := range view.categories {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("#"+category, "cat:"+strconv.Itoa(i)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "All quizzes"), "cat:-1"),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (view *catalogView) pageEntries() (int, []catalogEntry) {
+	start := view.page * catalogPageSize
+	end := start + catalogPageSize
+	if end > len(view.results) {
+		end = len(view.results)
+	}
+	return start, view.results[start:end]
+}
+
+func (view *catalogView) resultsText(ctx context.Context, client *firestore.Client, chatID int64) string {
+	if len(view.results) == 0 {
+		return tr(chatID, "No public quizzes found for %s.", escapeHTML(view.title))
+	}
+
+	start, entries := view.pageEntries()
+	ownerIDs := make([]string, len(entries))
+	for i, entry := range entries {
+		ownerIDs[i] = entry.ownerID
+	}
+	owners := usernamesForIDs(ctx, client, ownerIDs)
+
+	text := tr(chatID, "Public quizzes for %s:\n", escapeHTML(view.title))
+	for i, entry := range entries {
+		text += tr(chatID, "<strong>%d. %s</strong> by %s\n", start+i+1, escapeHTML(entry.quizName), escapeHTML(owners[i]))
+		text += trn(chatID, entry.numQns, "%d question", "%d questions", entry.numQns)
+		text += trn(chatID, entry.attempts, ", tried %d time", ", tried %d times", entry.attempts)
+		if entry.ratingCount > 0 {
+			text += tr(chatID, ", rated %.1f/5 by %d", float64(entry.ratingSum)/float64(entry.ratingCount), entry.ratingCount)
+		}
+		if len(entry.tags) > 0 {
+			text += "\n" + escapeHTML(formatTags(entry.tags))
+		}
+		text += "\n"
+	}
+	return text
+}
+
+func (view *catalogView) resultsKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	start, entries := view.pageEntries()
+	for i := range entries {
+		index := strconv.Itoa(start + i)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "Try %d", start+i+1), "try:"+index),
+			tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "Copy %d to my quizzes", start+i+1), "copy:"+index),
+		))
+	}
+
+	var navRow []tgbotapi.InlineKeyboardButton
+	if view.page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "« Prev"), "cpage:"+strconv.Itoa(view.page-1)))
+	}
+	if view.page < view.numPages()-1 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "Next »"), "cpage:"+strconv.Itoa(view.page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+
+	if len(rows) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// sendCatalogCategories sends the categories of /browse and returns the ID of its message
+func sendCatalogCategories(chatID int64, bot *tgbotapi.BotAPI, view *catalogView) int {
+	msg := tgbotapi.NewMessage(chatID, tr(chatID, "Choose a category to browse public quizzes, or use /search keywords:"))
+	msg.ReplyMarkup = view.categoriesKeyboard(chatID)
+
+	return sendPrompt(msg, bot)
+}
+
+// sendCatalogResults sends the first page of quizzes found by /search and returns the ID of its message
+func sendCatalogResults(ctx context.Context, client *firestore.Client, chatID int64, bot *tgbotapi.BotAPI, view *catalogView) int {
+	msg := tgbotapi.NewMessage(chatID, view.resultsText(ctx, client, chatID))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = view.resultsKeyboard(chatID)
+
+	return sendPrompt(msg, bot)
+}
+
+// handleCatalogCallback shows a category or turns a page of the catalog,
+// and returns the quiz whose Try or Copy button was pressed
+func handleCatalogCallback(ctx context.Context, client *firestore.Client, query *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI, view *catalogView) (catalogEntry, bool) {
+	kind, value := parseCallbackData(query.Data)
+	index, err := strconv.Atoi(value)
+	if err != nil {
+		return catalogEntry{}, false
+	}
+
+	switch kind {
+	case "cat":
+		if index >= len(view.categories) {
+			return catalogEntry{}, false
+		}
+
+		if index < 0 {
+			view.title = tr(query.Message.Chat.ID, "all categories")
+			view.results = catalogCategory(view.public, "")
+		} else {
+			view.title = "#" + view.categories[index]
+			view.results = catalogCategory(view.public, view.categories[index])
+		}
+		view.page = 0
+
+	case "cpage":
+		if index < 0 || index >= view.numPages() || index == view.page {
+			return catalogEntry{}, false
+		}
+		view.page = index
+
+	case "try", "copy":
+		if index < 0 || index >= len(view.results) {
+			return catalogEntry{}, false
+		}
+		return view.results[index], true
+
+	default:
+		return catalogEntry{}, false
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, view.resultsText(ctx, client, query.Message.Chat.ID), view.resultsKeyboard(query.Message.Chat.ID))
+	edit.ParseMode = "HTML"
+	queueMessage(bot, edit.ChatID, edit)
+	return catalogEntry{}, false
+}
+
+// recordFriendAttempt counts a finished attempt of another user's quiz towards its popularity,
+// returning whether the quiz is public and can be rated
+func recordFriendAttempt(ctx context.Context, client *firestore.Client, ownerID string, quizName string) (bool, error) {
+	quizRef := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName)
+
+	doc, err := quizRef.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = quizRef.Update(ctx, []firestore.Update{
+		{
+			Path:  "attempts",
+			Value: firestore.Increment(1),
+		},
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, ownerID, quizName)
+	}
+	return quizVisibility(doc.Data()) == visibilityPublic, err
+}
+
+// rateQuiz saves the user's rating of a quiz, replacing any rating they gave it before
+func rateQuiz(ctx context.Context, client *firestore.Client, ownerID string, quizName string, userID string, stars int) error {
+	quizRef := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName)
+	ratingRef := quizRef.Collection("RATINGS").Doc(userID)
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ratingDoc, err := tx.Get(ratingRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		ratingSum := stars
+		ratingCount := 1
+		if ratingDoc.Exists() {
+			if previous, ok := ratingDoc.Data()["stars"].(int64); ok {
+				ratingSum -= int(previous)
+				ratingCount = 0
+			}
+		}
+
+		if err := tx.Set(ratingRef, map[string]interface{}{"stars": stars}); err != nil {
+			return err
+		}
+		return tx.Update(quizRef, []firestore.Update{
+			{Path: "ratingSum", Value: firestore.Increment(ratingSum)},
+			{Path: "ratingCount", Value: firestore.Increment(ratingCount)},
+		})
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, ownerID, quizName)
+	}
+	return err
+}
+
+func ratingKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnRate1),
+			newActionButton(chatID, btnRate2),
+			newActionButton(chatID, btnRate3),
+			newActionButton(chatID, btnRate4),
+			newActionButton(chatID, btnRate5),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnSkip),
+		),
+	)
+}
+
+// ratingStars returns the rating given by a rating button
+func ratingStars(action buttonAction) (int, bool) {
+	switch action {
+	case btnRate1:
+		return 1, true
+	case btnRate2:
+		return 2, true
+	case btnRate3:
+		return 3, true
+	case btnRate4:
+		return 4, true
+	case btnRate5:
+		return 5, true
+	}
+	return 0, false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchCatalog(t *testing.T) {
+	entries := []catalogEntry{
+		newCatalogEntry("1", catalogEntryData("Cell biology", map[string]interface{}{"What is the powerhouse of the cell?": "mitochondria"})),
+		newCatalogEntry("2", catalogEntryData("Capitals", map[string]interface{}{"tags": []interface{}{"geography"}, "Capital of France?": "Paris"})),
+	}
+
+	if results := searchCatalog(entries, "MITOCHONDRIA"); len(results) != 1 || results[0].quizName != "Cell biology" {
+		t.Errorf("Expected question text to match but got: %v", results)
+	}
+	if results := searchCatalog(entries, "geography france"); len(results) != 1 || results[0].quizName != "Capitals" {
+		t.Errorf("Expected tags and questions to match but got: %v", results)
+	}
+	if results := searchCatalog(entries, "cell paris"); len(results) != 0 {
+		t.Errorf("Expected every keyword to be required but got: %v", results)
+	}
+}
+
+func TestCatalogEntryData(t *testing.T) {
+	data := catalogEntryData("Capitals", map[string]interface{}{
+		"tags":               []interface{}{"geography"},
+		"attempts":           int64(4),
+		"ratingSum":          int64(9),
+		"ratingCount":        int64(2),
+		"visibility":         visibilityPublic,
+		"Capital of France?": "Paris",
+		"Capital of Peru?":   "Lima",
+	})
+
+	// the copy is stored, so it is read back with firestore's int64 numbers
+	for _, field := range []string{"numQns", "attempts", "ratingSum", "ratingCount"} {
+		data[field] = int64(data[field].(int))
+	}
+	entry := newCatalogEntry("1", data)
+	if entry.ownerID != "1" || entry.quizName != "Capitals" || entry.numQns != 2 || entry.attempts != 4 || entry.ratingCount != 2 {
+		t.Errorf("Expected the quiz's details but got: %+v", entry)
+	}
+	if data["rank"] != entry.rank() {
+		t.Errorf("Expected the rank to be kept for ordering but got: %v", data["rank"])
+	}
+	if strings.Contains(entry.searchText, "public") {
+		t.Errorf("Expected only the title, tags and questions to be searched but got: %q", entry.searchText)
+	}
+}
+
+func TestRankCatalog(t *testing.T) {
+	entries := []catalogEntry{
+		{quizName: "new"},
+		{quizName: "popular", attempts: 50, ratingSum: 40, ratingCount: 10},
+		{quizName: "disliked", attempts: 50, ratingSum: 10, ratingCount: 10},
+	}
+	rankCatalog(entries)
+
+	if entries[0].quizName != "popular" || entries[2].quizName != "new" {
+		t.Errorf("Expected popular, disliked, new but got: %v", entries)
+	}
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var errChallengeClosed = errors.New("challenge is no longer open")
+
+// challengeQuestion is a question of a challenge, with the answer options both players are shown
+type challengeQuestion struct {
+	question string
+	answer   string
+	options  []string
+	media    questionMedia
+}
+
+// challengeAnswer is a player's answer to a question of a challenge
+type challengeAnswer struct {
+	correct bool
+	seconds float64
+}
+
+// challengeSession is a player answering a challenge in their private chat with the bot
+type challengeSession struct {
+	challengeID string
+	userID      string
+	chatID      int64
+	questions   []challengeQuestion
+	answers     []challengeAnswer
+	qnMsgID     int
+	qnSentAt    time.Time
+}
+
+func challengeDoc(client *firestore.Client, challengeID string) *firestore.DocumentRef {
+	return client.Collection("CHALLENGES").Doc(challengeID)
+}
+
+// newChallengeQuestions shuffles the quiz's questions once, so both players get them in the same order with the same options
+func newChallengeQuestions(quizData map[string]interface{}) []challengeQuestion {
+	game := newLiveGame(0, "", "", quizData)
+
+	var questions []challengeQuestion
+	for _, question := range game.questions {
+		questions = append(questions, challengeQuestion{
+			question: question,
+			answer:   game.answers[question],
+			options:  liveOptions(game.answers[question], game.answers),
+			media:    game.media[question].question,
+		})
+	}
+	return questions
+}
+
+// createChallenge saves a new challenge between two players on the challenger's quiz
+func createChallenge(ctx context.Context, client *firestore.Client, challengerID string, challengerName string, opponentID string, quizName string, questions []challengeQuestion) (string, error) {
+	var questionsData []map[string]interface{}
+	for _, question := range questions {
+		questionData := map[string]interface{}{
+			"question": question.question,
+			"answer":   question.answer,
+			"options":  question.options,
+		}
+		if question.media.fileID != "" {
+			questionData["media"] = question.media.data()
+		}
+		questionsData = append(questionsData, questionData)
+	}
+
+	ref, _, err := client.Collection("CHALLENGES").Add(ctx, map[string]interface{}{
+		"challengerID":   challengerID,
+		"challengerName": challengerName,
+		"opponentID":     opponentID,
+		"quizName":       quizName,
+		"questions":      questionsData,
+		"status":         "pending",
+		"createdAt":      time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return ref.ID, nil
+}
+
+func challengeQuestionsFromData(data map[string]interface{}) []challengeQuestion {
+	var questions []challengeQuestion
+	questionsData, _ := data["questions"].([]interface{})
+	for _, questionData := range questionsData {
+		fields, ok := questionData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var question challengeQuestion
+		question.question, _ = fields["question"].(string)
+		question.answer, _ = fields["answer"].(string)
+		question.media = newQuestionMedia(fields["media"])
+		if options, ok := fields["options"].([]interface{}); ok {
+			for _, option := range options {
+				if optionStr, ok := option.(string); ok {
+					question.options = append(question.options, optionStr)
+				}
+			}
+		}
+		questions = append(questions, question)
+	}
+	return questions
+}
+
+// answerChallengeInvite marks a pending challenge as accepted or declined by the opponent, returning its data
+func answerChallengeInvite(ctx context.Context, client *firestore.Client, challengeID string, opponentID string, accept bool) (map[string]interface{}, error) {
+	ref := challengeDoc(client, challengeID)
+	var data map[string]interface{}
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		data = doc.Data()
+
+		if data["opponentID"] != opponentID || data["status"] != "pending" {
+			return errChallengeClosed
+		}
+
+		newStatus := "declined"
+		if accept {
+			newStatus = "accepted"
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "status", Value: newStatus}})
+	})
+	return data, err
+}
+
+func newChallengeSession(challengeID string, userID string, chatID int64, questions []challengeQuestion) *challengeSession {
+	return &challengeSession{
+		challengeID: challengeID,
+		userID:      userID,
+		chatID:      chatID,
+		questions:   questions,
+	}
+}
+
+func (session *challengeSession) finished() bool {
+	return len(session.answers) >= len(session.questions)
+}
+
+// sendQuestion sends the player's next question with its answer options
+func (session *challengeSession) sendQuestion(bot *tgbotapi.BotAPI) {
+	qnIndex := len(session.answers)
+	question := session.questions[qnIndex]
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, option := range question.options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option, "chal:"+strconv.Itoa(qnIndex)+":"+strconv.Itoa(i)),
+		))
+	}
+
+	sendMedia(session.chatID, question.media, bot)
+
+	msg := tgbotapi.NewMessage(session.chatID, "")
+	msg.ParseMode = "HTML"
+	msg.Text = tr(session.chatID, "<strong>Challenge question %d/%d</strong>\n%s\n\n<i>Tap an answer or type it.</i>",
+		qnIndex+1, len(session.questions), escapeHTML(question.question))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	session.qnMsgID = sendPrompt(msg, bot)
+	session.qnSentAt = time.Now()
+}
+
+// recordAnswer marks the current question as answered and shows the player whether they were right
+func (session *challengeSession) recordAnswer(bot *tgbotapi.BotAPI, answer string) {
+	question := session.questions[len(session.answers)]
+	correct := normalizeAnswer(answer) == normalizeAnswer(question.answer)
+
+	session.answers = append(session.answers, challengeAnswer{
+		correct: correct,
+		seconds: time.Since(session.qnSentAt).Seconds(),
+	})
+
+	mark := tr(session.chatID, "❌ Wrong, the answer was %s", question.answer)
+	if correct {
+		mark = tr(session.chatID, "✅ Correct!")
+	}
+	edit := tgbotapi.NewEditMessageText(session.chatID, session.qnMsgID, tr(session.chatID, "Challenge question %d/%d\n%s\n\n%s",
+		len(session.answers), len(session.questions), question.question, mark))
+	queueMessage(bot, edit.ChatID, edit)
+}
+
+// handleChallengeCallback records an answer given with a button, returning the text shown to the player
+func (session *challengeSession) handleChallengeCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) string {
+	dataParts := strings.Split(query.Data, ":")
+	if len(dataParts) != 3 || session.finished() {
+		return tr(session.chatID, "This question is closed")
+	}
+	qnIndex, err1 := strconv.Atoi(dataParts[1])
+	option, err2 := strconv.Atoi(dataParts[2])
+	options := session.questions[len(session.answers)].options
+	if err1 != nil || err2 != nil || qnIndex != len(session.answers) || option < 0 || option >= len(options) {
+		return tr(session.chatID, "This question is closed")
+	}
+
+	session.recordAnswer(bot, options[option])
+	return ""
+}
+
+// saveChallengeResult saves the player's answers, returning the challenge's data once both players have finished
+func saveChallengeResult(ctx context.Context, client *firestore.Client, session *challengeSession) (map[string]interface{}, bool, error) {
+	ref := challengeDoc(client, session.challengeID)
+
+	// kept as the types firebase reads them back as, so challengeResultFromData reads both players alike
+	var correct []interface{}
+	var seconds []interface{}
+	for _, answer := range session.answers {
+		correct = append(correct, answer.correct)
+		seconds = append(seconds, answer.seconds)
+	}
+
+	var data map[string]interface{}
+	bothFinished := false
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		data = doc.Data()
+
+		results, _ := data["results"].(map[string]interface{})
+		if results == nil {
+			results = make(map[string]interface{})
+		}
+		results[session.userID] = map[string]interface{}{
+			"correct": correct,
+			"seconds": seconds,
+		}
+		data["results"] = results
+
+		updates := []firestore.Update{{FieldPath: []string{"results", session.userID}, Value: results[session.userID]}}
+		bothFinished = len(results) == 2
+		if bothFinished {
+			data["status"] = "finished"
+			updates = append(updates, firestore.Update{Path: "status", Value: "finished"})
+		}
+		return tx.Update(ref, updates)
+	})
+	return data, bothFinished, err
+}
+
+// challengeResultFromData reads a player's answers saved by saveChallengeResult
+func challengeResultFromData(data map[string]interface{}, userID string) []challengeAnswer {
+	results, _ := data["results"].(map[string]interface{})
+	result, _ := results[userID].(map[string]interface{})
+	correct, _ := result["correct"].([]interface{})
+	seconds, _ := result["seconds"].([]interface{})
+
+	var answers []challengeAnswer
+	for i := range correct {
+		var answer challengeAnswer
+		answer.correct, _ = correct[i].(bool)
+		if i < len(seconds) {
+			answer.seconds, _ = seconds[i].(float64)
+		}
+		answers = append(answers, answer)
+	}
+	return answers
+}
+
+func countCorrect(answers []challengeAnswer) int {
+	numCorrect := 0
+	for _, answer := range answers {
+		if answer.correct {
+			numCorrect++
+		}
+	}
+	return numCorrect
+}
+
+func sumSeconds(answers []challengeAnswer) float64 {
+	var total float64
+	for _, answer := range answers {
+		total += answer.seconds
+	}
+	return total
+}
+
+// challengeWinner returns 1 or 2 for the winning player, or 0 for a draw: most correct answers wins, then the fastest
+func challengeWinner(answers1 []challengeAnswer, answers2 []challengeAnswer) int {
+	correct1, correct2 := countCorrect(answers1), countCorrect(answers2)
+	if correct1 != correct2 {
+		if correct1 > correct2 {
+			return 1
+		}
+		return 2
+	}
+
+	seconds1, seconds2 := sumSeconds(answers1), sumSeconds(answers2)
+	if seconds1 < seconds2 {
+		return 1
+	} else if seconds2 < seconds1 {
+		return 2
+	}
+	return 0
+}
+
+// challengeComparison describes the outcome of a finished challenge, question by question, in the language of the chat
+func challengeComparison(chatID int64, questions []challengeQuestion, name1 string, answers1 []challengeAnswer, name2 string, answers2 []challengeAnswer) string {
+	// the results are sent as HTML
+	name1, name2 = escapeHTML(name1), escapeHTML(name2)
+
+	text := tr(chatID, "<strong>Challenge results</strong>\n")
+	switch challengeWinner(answers1, answers2) {
+	case 1:
+		text += tr(chatID, "🏆 %s wins!\n", name1)
+	case 2:
+		text += tr(chatID, "🏆 %s wins!\n", name2)
+	default:
+		text += tr(chatID, "It's a draw!\n")
+	}
+
+	text += tr(chatID, "%s: %d/%d correct in %.1fs\n", name1, countCorrect(answers1), len(questions), sumSeconds(answers1))
+	text += tr(chatID, "%s: %d/%d correct in %.1fs\n", name2, countCorrect(answers2), len(questions), sumSeconds(answers2)) + "\n"
+
+	mark := func(answers []challengeAnswer, i int) string {
+		if i >= len(answers) {
+			return "-"
+		}
+		if answers[i].correct {
+			return fmt.Sprintf("✅ %.1fs", answers[i].seconds)
+		}
+		return fmt.Sprintf("❌ %.1fs", answers[i].seconds)
+	}
+	for i, question := range questions {
+		text += fmt.Sprintf("%d. %s\n%s %s | %s %s\n", i+1, escapeHTML(question.question), name1, mark(answers1, i), name2, mark(answers2, i))
+	}
+	return text
+}
+
+// sendChallengeResults sends the comparison of a finished challenge to both players
+func sendChallengeResults(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, data map[string]interface{}) {
+	challengerID, _ := data["challengerID"].(string)
+	opponentID, _ := data["opponentID"].(string)
+	names := usernamesForIDs(ctx, client, []string{challengerID, opponentID})
+
+	for _, userID := range []string{challengerID, opponentID} {
+		chatID, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			continue
+		}
+		loadChatLanguage(ctx, client, chatID, "")
+
+		text := challengeComparison(chatID, challengeQuestionsFromData(data),
+			names[0], challengeResultFromData(data, challengerID),
+			names[1], challengeResultFromData(data, opponentID))
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = "HTML"
+		queueMessage(bot, msg.ChatID, msg)
+	}
+}
+
+// sendChallengeInvite asks the opponent to accept the challenge in their private chat with the bot
+func sendChallengeInvite(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, opponentID string, challengerName string, quizName string, numQns int, challengeID string) error {
+	chatID, err := strconv.ParseInt(opponentID, 10, 64)
+	if err != nil {
+		return err
+	}
+	loadChatLanguage(ctx, client, chatID, "")
+
+	msg := tgbotapi.NewMessage(chatID, trn(chatID, numQns,
+		"%s challenges you to quiz %s with %d question! You both get the same questions, and the most correct answers wins, then the fastest.",
+		"%s challenges you to quiz %s with %d questions! You both get the same questions, and the most correct answers wins, then the fastest.",
+		challengerName, quizName, numQns))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "Accept"), "chalinv:"+challengeID+":accept"),
+		tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "Decline"), "chalinv:"+challengeID+":decline"),
+	))
+
+	_, err = bot.Send(msg)
+	return err
+}
+
+// advanceChallenge sends the player's next question, or saves their answers once they have finished,
+// returning whether the session is over
+func advanceChallenge(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, session *challengeSession) bool {
+	if !session.finished() {
+		session.sendQuestion(bot)
+		return false
+	}
+
+	data, bothFinished, err := saveChallengeResult(ctx, client, session)
+	if err != nil {
+		log.Printf("An error has occurred trying to save challenge result: %s", err)
+		sendSimpleMsg(session.chatID, tr(session.chatID, "Sorry, your challenge result could not be saved."), bot)
+		return true
+	}
+
+	// a finished challenge counts towards the player's streak
+	streakText := recordStudyDayText(ctx, client, session.chatID, session.userID)
+
+	if bothFinished {
+		sendChallengeResults(ctx, client, bot, data)
+		if streakText != "" {
+			sendSimpleMsg(session.chatID, streakText, bot)
+		}
+	} else {
+		text := tr(session.chatID, "You got %d/%d correct in %.1fs! I will send you the results once the other player finishes.",
+			countCorrect(session.answers), len(session.questions), sumSeconds(session.answers))
+		if streakText != "" {
+			text += "\n\n" + streakText
+		}
+		sendSimpleMsg(session.chatID, text, bot)
+	}
+	return true
+}
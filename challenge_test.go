@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChallengeWinner(t *testing.T) {
+	fastWrong := []challengeAnswer{{correct: false, seconds: 1}, {correct: true, seconds: 1}}
+	slowRight := []challengeAnswer{{correct: true, seconds: 9}, {correct: true, seconds: 9}}
+	fastRight := []challengeAnswer{{correct: true, seconds: 2}, {correct: true, seconds: 2}}
+
+	if winner := challengeWinner(fastWrong, slowRight); winner != 2 {
+		t.Errorf("Expected more correct answers to win but got: %d", winner)
+	}
+	if winner := challengeWinner(fastRight, slowRight); winner != 1 {
+		t.Errorf("Expected the faster player to win a tie on answers but got: %d", winner)
+	}
+	if winner := challengeWinner(fastRight, fastRight); winner != 0 {
+		t.Errorf("Expected a draw but got: %d", winner)
+	}
+}
+
+func TestNewChallengeQuestions(t *testing.T) {
+	questions := newChallengeQuestions(map[string]interface{}{"numQns": int64(2), "1+1?": "2", "2+2?": "4"})
+
+	if len(questions) != 2 {
+		t.Fatalf("Expected 2 questions but got: %v", questions)
+	}
+	for _, question := range questions {
+		if !containsString(question.options, question.answer) {
+			t.Errorf("Expected the options of %q to include its answer but got: %v", question.question, question.options)
+		}
+	}
+}
+
+func TestChallengeQuestionMedia(t *testing.T) {
+	questions := newChallengeQuestions(map[string]interface{}{
+		"numQns":       int64(1),
+		"Whose voice?": "mine",
+		"media": map[string]interface{}{
+			"Whose voice?": map[string]interface{}{"question": questionMedia{kind: "voice", fileID: "file1"}.data()},
+		},
+	})
+	if len(questions) != 1 || questions[0].media.fileID != "file1" {
+		t.Fatalf("Expected the question to keep its media but got: %v", questions)
+	}
+
+	data := map[string]interface{}{"questions": []interface{}{
+		map[string]interface{}{"question": "Whose voice?", "answer": "mine", "media": questions[0].media.data()},
+	}}
+	if loaded := challengeQuestionsFromData(data); len(loaded) != 1 || loaded[0].media.kind != "voice" {
+		t.Errorf("Expected the saved question to keep its media but got: %v", loaded)
+	}
+}
+
+func TestChallengeResultFromData(t *testing.T) {
+	data := map[string]interface{}{
+		"results": map[string]interface{}{
+			"111": map[string]interface{}{
+				"correct": []interface{}{true, false},
+				"seconds": []interface{}{1.5, 2.0},
+			},
+		},
+	}
+
+	answers := challengeResultFromData(data, "111")
+	if len(answers) != 2 || !answers[0].correct || answers[1].correct || answers[0].seconds != 1.5 {
+		t.Errorf("Expected the saved answers but got: %v", answers)
+	}
+	if answers := challengeResultFromData(data, "222"); len(answers) != 0 {
+		t.Errorf("Expected no answers for a player who has not finished but got: %v", answers)
+	}
+}
+
+func TestChallengeComparisonEscapesHTML(t *testing.T) {
+	questions := []challengeQuestion{{question: "Is 3 < 5?", answer: "yes"}}
+	answers := []challengeAnswer{{correct: true, seconds: 1}}
+	text := challengeComparison(1, questions, "<b>a</b>", answers, "b&c", answers)
+
+	if strings.Contains(text, "3 < 5") || strings.Contains(text, "<b>a</b>") || strings.Contains(text, "b&c") {
+		t.Errorf("Expected questions and names to be escaped but got: %q", text)
+	}
+	if !strings.Contains(text, "3 &lt; 5") {
+		t.Errorf("Expected the escaped question but got: %q", text)
+	}
+}
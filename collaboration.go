@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errQuestionChanged = errors.New("question was changed by another editor")
+
+// errNotEditor is returned when the user was removed as an editor of the quiz while editing it
+var errNotEditor = errors.New("user can no longer edit the quiz")
+
+func quizEditors(quizData map[string]interface{}) []string {
+	var editors []string
+	if ids, ok := quizData["editors"].([]interface{}); ok {
+		for _, id := range ids {
+			if idStr, ok := id.(string); ok {
+				editors = append(editors, idStr)
+			}
+		}
+	}
+	return editors
+}
+
+// canEditQuiz reports whether the user may add, edit and remove questions of the quiz
+func canEditQuiz(quizData map[string]interface{}, ownerID string, userID string) bool {
+	return ownerID == userID || containsString(quizEditors(quizData), userID)
+}
+
+// quizzes of other users that a user co-edits are listed in their EDITING collection
+func editingCollection(client *firestore.Client, userID string) *firestore.CollectionRef {
+	return client.Collection("USERS").Doc(userID).Collection("EDITING")
+}
+
+func quizHistoryCollection(client *firestore.Client, ownerID string, quizName string) *firestore.CollectionRef {
+	return client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName).Collection("HISTORY")
+}
+
+// quizNameClash is returned when the user can edit more than one quiz of the name, so its owner has to be given
+type quizNameClash struct {
+	quizName string
+	ownerIDs []string
+}
+
+func (clash *quizNameClash) Error() string {
+	return "more than one editable quiz is named " + clash.quizName
+}
+
+// editableQuiz is a quiz the user owns or co-edits
+type editableQuiz struct {
+	ownerID  string
+	quizName string
+}
+
+// quizOwnerLabel names the owner of a quiz the way findEditableQuiz reads it, by @username or else by @id
+func quizOwnerLabel(ctx context.Context, client *firestore.Client, ownerID string) string {
+	return "@" + strings.TrimPrefix(usernamesForIDs(ctx, client, []string{ownerID})[0], "@")
+}
+
+// resolveQuizOwner returns the user named by an owner label, or "" if there is none
+func resolveQuizOwner(ctx context.Context, client *firestore.Client, label string) (string, error) {
+	label = strings.TrimPrefix(label, "@")
+	if _, err := strconv.ParseInt(label, 10, 64); err == nil {
+		return label, nil
+	}
+
+	docs, err := client.Collection("USERS").Where("username", "==", label).Limit(1).Documents(ctx).GetAll()
+	if err != nil || len(docs) == 0 {
+		return "", err
+	}
+	return docs[0].Ref.ID, nil
+}
+
+// findEditableQuiz returns the owner of the quiz the user may edit. Users who can edit more than one quiz of
+// the name put the owner first, e.g. "@friend demo quiz", and get a quizNameClash until they do
+func findEditableQuiz(ctx context.Context, client *firestore.Client, userID string, quizName string) (string, *firestore.DocumentSnapshot, error) {
+	if ownerLabel, name, found := strings.Cut(quizName, " "); found && strings.HasPrefix(ownerLabel, "@") {
+		ownerID, err := resolveQuizOwner(ctx, client, ownerLabel)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if ownerID != "" {
+			doc, err := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(strings.TrimSpace(name)).Get(ctx)
+			if err == nil && canEditQuiz(doc.Data(), ownerID, userID) {
+				return ownerID, doc, nil
+			}
+			if err != nil && status.Code(err) != codes.NotFound {
+				return "", nil, err
+			}
+		}
+		// the whole text may still be the name of a quiz
+	}
+
+	var ownerIDs []string
+	var docs []*firestore.DocumentSnapshot
+
+	doc, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+	if err == nil {
+		ownerIDs = append(ownerIDs, userID)
+		docs = append(docs, doc)
+	} else if status.Code(err) != codes.NotFound {
+		return "", nil, err
+	}
+
+	iter := editingCollection(client, userID).Where("quizName", "==", quizName).Documents(ctx)
+	defer iter.Stop()
+	for {
+		editingDoc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		ownerID, _ := editingDoc.Data()["ownerID"].(string)
+		doc, err := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+		if status.Code(err) == codes.NotFound {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		if canEditQuiz(doc.Data(), ownerID, userID) {
+			ownerIDs = append(ownerIDs, ownerID)
+			docs = append(docs, doc)
+		}
+	}
+
+	switch len(docs) {
+	case 0:
+		return "", nil, status.Error(codes.NotFound, "quiz not found")
+	case 1:
+		return ownerIDs[0], docs[0], nil
+	}
+	return "", nil, &quizNameClash{quizName: quizName, ownerIDs: ownerIDs}
+}
+
+// loadEditableQuizzes returns the quizzes of other users that the user co-edits
+func loadEditableQuizzes(ctx context.Context, client *firestore.Client, userID string) ([]editableQuiz, error) {
+	docs, err := editingCollection(client, userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var quizzes []editableQuiz
+	for _, doc := range docs {
+		ownerID, _ := doc.Data()["ownerID"].(string)
+		if quizName, ok := doc.Data()["quizName"].(string); ok {
+			quizzes = append(quizzes, editableQuiz{ownerID: ownerID, quizName: quizName})
+		}
+	}
+	return quizzes, nil
+}
+
+// editableQuizNames names the quizzes the way findEditableQuiz reads them, putting the owner first for names
+// shared by more than one of them
+func editableQuizNames(quizzes []editableQuiz, ownerLabel func(ownerID string) string) []string {
+	counts := make(map[string]int)
+	for _, quiz := range quizzes {
+		counts[quiz.quizName]++
+	}
+
+	names := make([]string, len(quizzes))
+	for i, quiz := range quizzes {
+		names[i] = quiz.quizName
+		if counts[quiz.quizName] > 1 {
+			names[i] = ownerLabel(quiz.ownerID) + " " + quiz.quizName
+		}
+	}
+	return names
+}
+
+// newClashPicker offers the quizzes of the same name the user can edit, each named with its owner
+func newClashPicker(ctx context.Context, client *firestore.Client, action string, clash *quizNameClash) *quizPicker {
+	picker := &quizPicker{action: action}
+	for _, ownerID := range clash.ownerIDs {
+		picker.quizNames = append(picker.quizNames, quizOwnerLabel(ctx, client, ownerID)+" "+clash.quizName)
+	}
+	return picker
+}
+
+func newHistoryDoc(editorID string, editorName string, action string, question string, answer string) map[string]interface{} {
+	return map[string]interface{}{
+		"editorID":   editorID,
+		"editorName": editorName,
+		"action":     action,
+		"question":   question,
+		"answer":     answer,
+		"at":         time.Now(),
+	}
+}
+
+// saveNewQuestions adds questions to the quiz without overwriting questions saved by other editors meanwhile,
+// returning the new number of questions
+func saveNewQuestions(ctx context.Context, client *firestore.Client, ownerID string, quizName string, editorID string, editorName string, questions map[string]string, attachments map[string]questionAttachments, formatting map[string]questionFormatting) (int, error) {
+	quizRef := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName)
+	var numQns int
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(quizRef)
+		if err != nil {
+			return err
+		}
+		if !canEditQuiz(doc.Data(), ownerID, editorID) {
+			return errNotEditor
+		}
+
+		existing := quizQuestions(doc.Data())
+		updates := []firestore.Update{
+			{
+				Path:  "score",
+				Value: "none",
+			},
+		}
+
+		for question, answer := range questions {
+			if quizInfoFields[question] {
+				continue
+			}
+
+			action := "add"
+			if _, ok := existing[question]; ok {
+				action = "edit"
+			}
+			existing[question] = answer
+
+			updates = append(updates, firestore.Update{FieldPath: []string{question}, Value: answer})
+			if attachment, ok := attachments[question]; ok && !attachment.empty() {
+				updates = append(updates, firestore.Update{FieldPath: []string{"media", question}, Value: attachment.data()})
+			} else if action == "edit" {
+				// a question added again without media replaces the old one's media
+				if _, ok := quizAttachments(doc.Data())[question]; ok {
+					updates = append(updates, firestore.Update{FieldPath: []string{"media", question}, Value: firestore.Delete})
+				}
+			}
+			if format, ok := formatting[question]; ok && !format.empty() {
+				updates = append(updates, firestore.Update{FieldPath: []string{"formatting", question}, Value: format.data()})
+			} else if action == "edit" {
+				if _, ok := quizFormatting(doc.Data())[question]; ok {
+					updates = append(updates, firestore.Update{FieldPath: []string{"formatting", question}, Value: firestore.Delete})
+				}
+			}
+			if err := tx.Create(quizHistoryCollection(client, ownerID, quizName).NewDoc(), newHistoryDoc(editorID, editorName, action, question, answer)); err != nil {
+				return err
+			}
+		}
+
+		numQns = len(existing)
+		updates = append(updates, firestore.Update{
+			Path:  "numQns",
+			Value: numQns,
+		})
+
+		return tx.Update(quizRef, updates)
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, ownerID, quizName)
+	}
+
+	return numQns, err
+}
+
+// editQuestion replaces a question and its answer, failing with errQuestionChanged if another editor changed it first
+func editQuestion(ctx context.Context, client *firestore.Client, ownerID string, quizName string, editorID string, editorName string, oldQuestion string, oldAnswer string, newQuestion string, newAnswer string) error {
+	quizRef := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName)
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(quizRef)
+		if err != nil {
+			return err
+		}
+		if !canEditQuiz(doc.Data(), ownerID, editorID) {
+			return errNotEditor
+		}
+
+		existing := quizQuestions(doc.Data())
+		if answer, ok := existing[oldQuestion]; !ok || answer != oldAnswer {
+			return errQuestionChanged
+		}
+		if _, ok := existing[newQuestion]; (ok && newQuestion != oldQuestion) || quizInfoFields[newQuestion] {
+			return errQuestionChanged
+		}
+
+		var updates []firestore.Update
+		if newQuestion != oldQuestion {
+			updates = append(updates, firestore.Update{FieldPath: []string{oldQuestion}, Value: firestore.Delete})
+
+			// media stays with the renamed question
+			if attachment, ok := quizAttachments(doc.Data())[oldQuestion]; ok {
+				updates = append(updates,
+					firestore.Update{FieldPath: []string{"media", oldQuestion}, Value: firestore.Delete},
+					firestore.Update{FieldPath: []string{"media", newQuestion}, Value: attachment.data()},
+				)
+			}
+		}
+		// formatting was kept for the old text, so an edited question is shown as typed
+		if _, ok := quizFormatting(doc.Data())[oldQuestion]; ok {
+			updates = append(updates, firestore.Update{FieldPath: []string{"formatting", oldQuestion}, Value: firestore.Delete})
+		}
+		updates = append(updates,
+			firestore.Update{FieldPath: []string{newQuestion}, Value: newAnswer},
+			firestore.Update{Path: "score", Value: "none"},
+		)
+
+		if err := tx.Create(quizHistoryCollection(client, ownerID, quizName).NewDoc(), newHistoryDoc(editorID, editorName, "edit", newQuestion, newAnswer)); err != nil {
+			return err
+		}
+		return tx.Update(quizRef, updates)
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, ownerID, quizName)
+	}
+	return err
+}
+
+// updateQuizEditors adds or removes co-editors of the owner's quiz
+func updateQuizEditors(ctx context.Context, client *firestore.Client, ownerID string, quizName string, editorIDs []string, add bool) error {
+	idValues := make([]interface{}, len(editorIDs))
+	for i, id := range editorIDs {
+		idValues[i] = id
+	}
+
+	var value interface{} = firestore.ArrayRemove(idValues...)
+	if add {
+		value = firestore.ArrayUnion(idValues...)
+	}
+
+	batch := client.Batch()
+	batch.Update(client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName), []firestore.Update{
+		{
+			Path:  "editors",
+			Value: value,
+		},
+	})
+
+	for _, editorID := range editorIDs {
+		// one EDITING document per owner and quiz, so adding an editor twice does not list the quiz twice
+		editingRef := editingCollection(client, editorID).Doc(ownerID + "_" + quizName)
+		if add {
+			batch.Set(editingRef, map[string]interface{}{
+				"ownerID":  ownerID,
+				"quizName": quizName,
+			})
+		} else {
+			batch.Delete(editingRef)
+		}
+	}
+
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// usernamesForIDs shows users by their @username where it is known, and by their id otherwise
+func usernamesForIDs(ctx context.Context, client *firestore.Client, userIDs []string) []string {
+	names := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		names[i] = userID
+
+		doc, err := client.Collection("USERS").Doc(userID).Get(ctx)
+		if err != nil {
+			continue
+		}
+		if username, ok := doc.Data()["username"].(string); ok && username != "" {
+			names[i] = "@" + username
+		}
+	}
+	return names
+}
+
+// quizHistoryList lists the latest changes to a quiz, newest first
+func quizHistoryList(ctx context.Context, client *firestore.Client, chatID int64, ownerID string, quizName string) *pagedList {
+	docs, err := quizHistoryCollection(client, ownerID, quizName).OrderBy("at", firestore.Desc).Limit(20).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load quiz history: %s", err)
+		return newPagedList(chatID, tr(chatID, "Sorry, the history of quiz %s could not be loaded. Please try again.", escapeHTML(quizName)), nil, "")
+	} else if len(docs) == 0 {
+		return newPagedList(chatID, tr(chatID, "No changes have been recorded for quiz %s yet.", escapeHTML(quizName)), nil, "")
+	}
+
+	actionNames := map[string]string{"add": tr(chatID, "added"), "edit": tr(chatID, "changed"), "remove": tr(chatID, "removed")}
+
+	var lines []string
+	for _, doc := range docs {
+		at, _ := doc.Data()["at"].(time.Time)
+		editorName, _ := doc.Data()["editorName"].(string)
+		action, _ := doc.Data()["action"].(string)
+		question, _ := doc.Data()["question"].(string)
+
+		lines = append(lines, fmt.Sprintf("%s <strong>%s</strong> %s: %s", at.Format("2 Jan 15:04"), escapeHTML(editorName), actionNames[action], escapeHTML(question)))
+	}
+	return newPagedList(chatID, tr(chatID, "Latest changes to quiz %s:\n", escapeHTML(quizName)), lines, "")
+}
+
+// numberedQuestionsList lists the questions of a quiz so that one can be chosen by its number
+func numberedQuestionsList(chatID int64, questionsMap2 map[int]string, formatting map[string]questionFormatting) *pagedList {
+	var lines []string
+	for i := 1; i <= len(questionsMap2); i++ {
+		lines = append(lines, "<strong>"+strconv.Itoa(i)+".</strong> "+formattedText(questionsMap2[i], formatting[questionsMap2[i]].question))
+	}
+	return newPagedList(chatID, tr(chatID, "QUESTIONS:\n"), lines, "")
+}
+
+// notifyUser messages a user in their own language in their private chat with the bot, which fails if they
+// never started it
+func notifyUser(ctx context.Context, client *firestore.Client, userID string, bot *tgbotapi.BotAPI, msgTxt string, args ...interface{}) {
+	notifyUserWith(ctx, client, userID, bot, func(chatID int64) string {
+		return tr(chatID, msgTxt, args...)
+	})
+}
+
+// notifyUserPlural is notifyUser for a text with a singular and a plural for n
+func notifyUserPlural(ctx context.Context, client *firestore.Client, userID string, bot *tgbotapi.BotAPI, n int, singular string, plural string, args ...interface{}) {
+	notifyUserWith(ctx, client, userID, bot, func(chatID int64) string {
+		return trn(chatID, n, singular, plural, args...)
+	})
+}
+
+// notifyUserWith sends the user the text, translated once their language is loaded
+func notifyUserWith(ctx context.Context, client *firestore.Client, userID string, bot *tgbotapi.BotAPI, text func(chatID int64) string) {
+	chatID, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return
+	}
+
+	loadChatLanguage(ctx, client, chatID, "")
+	queueMessage(bot, chatID, tgbotapi.NewMessage(chatID, text(chatID)))
+}
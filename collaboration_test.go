@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCanEditQuiz(t *testing.T) {
+	quizData := map[string]interface{}{
+		"numQns":  int64(1),
+		"editors": []interface{}{"222"},
+	}
+
+	if !canEditQuiz(quizData, "111", "111") {
+		t.Error("Expected the owner to edit their quiz")
+	}
+	if !canEditQuiz(quizData, "111", "222") {
+		t.Error("Expected a co-editor to edit the quiz")
+	}
+	if canEditQuiz(quizData, "111", "333") {
+		t.Error("Expected other users not to edit the quiz")
+	}
+}
+
+func TestEditorsAreNotQuestions(t *testing.T) {
+	questions := quizQuestions(map[string]interface{}{
+		"What is 1+1?": "2",
+		"editors":      []interface{}{"222"},
+		"score":        "none",
+	})
+
+	if len(questions) != 1 || questions["What is 1+1?"] != "2" {
+		t.Errorf("Expected only the question but got: %v", questions)
+	}
+}
+
+func TestEditableQuizNames(t *testing.T) {
+	quizzes := []editableQuiz{
+		{ownerID: "111", quizName: "demo quiz"},
+		{ownerID: "111", quizName: "biology"},
+		{ownerID: "222", quizName: "demo quiz"},
+	}
+	names := editableQuizNames(quizzes, func(ownerID string) string { return "@" + ownerID })
+
+	if len(names) != 3 || names[0] != "@111 demo quiz" || names[1] != "biology" || names[2] != "@222 demo quiz" {
+		t.Errorf("Expected quizzes of the same name to be named with their owner but got: %q", names)
+	}
+}
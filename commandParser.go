@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// command is a parsed command message, e.g. /try_quiz "Bio 101" mode=typed n=10
+type command struct {
+	name    string
+	text    string            // everything after the command, as typed
+	args    []string          // arguments, without their quotes
+	options map[string]string // key=value options
+}
+
+var errNotCommand = errors.New("not a command")
+
+// errOtherBot is returned for commands addressed to another bot in a group, e.g. /help@other_bot
+var errOtherBot = errors.New("command for another bot")
+
+// commandError is a problem with the arguments of a command, shown to the user along with its usage.
+// The problem is kept as a format and its args, so that it can be translated
+type commandError struct {
+	command string
+	problem string
+	args    []interface{}
+}
+
+func (err *commandError) Error() string {
+	return "/" + err.command + ": " + fmt.Sprintf(err.problem, err.args...)
+}
+
+// isQuote reports whether the character opens or closes a quoted argument. Phones often type curly quotes
+func isQuote(char rune) bool {
+	return char == '"' || char == '“' || char == '”'
+}
+
+// isOptionKey reports whether the text before an = names an option rather than being part of an argument
+func isOptionKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, char := range key {
+		if !(char >= 'a' && char <= 'z') && char != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCommand parses a command addressed to the bot with the username, or to no bot in particular.
+// Arguments are split at spaces unless quoted, and unquoted arguments like key=value are options.
+// For a problem with the arguments the command is returned along with a *commandError
+func parseCommand(text string, botUsername string) (*command, error) {
+	if !strings.HasPrefix(text, "/") {
+		return nil, errNotCommand
+	}
+
+	name := text[1:]
+	rest := ""
+	if end := strings.IndexFunc(name, unicode.IsSpace); end >= 0 {
+		name, rest = name[:end], name[end:]
+	}
+	if at := strings.IndexByte(name, '@'); at >= 0 {
+		if !strings.EqualFold(name[at+1:], botUsername) {
+			return nil, errOtherBot
+		}
+		name = name[:at]
+	}
+	if name == "" {
+		return nil, errNotCommand
+	}
+
+	cmd := &command{name: name, text: strings.TrimSpace(rest), options: make(map[string]string)}
+
+	chars := []rune(cmd.text)
+	for i := 0; i < len(chars); {
+		if unicode.IsSpace(chars[i]) {
+			i++
+			continue
+		}
+
+		// an option's key, up to the = before its value
+		key := ""
+		end := i
+		for end < len(chars) && chars[end] != '=' && !unicode.IsSpace(chars[end]) {
+			end++
+		}
+		if end < len(chars) && chars[end] == '=' && isOptionKey(string(chars[i:end])) {
+			key = string(chars[i:end])
+			i = end + 1
+		}
+
+		var value strings.Builder
+		if i < len(chars) && isQuote(chars[i]) {
+			closed := false
+			for i++; i < len(chars); i++ {
+				if chars[i] == '\\' && i+1 < len(chars) && isQuote(chars[i+1]) {
+					i++
+				} else if isQuote(chars[i]) {
+					closed = true
+					i++
+					break
+				}
+				value.WriteRune(chars[i])
+			}
+			if !closed {
+				return cmd, &commandError{command: name, problem: "a quote is not closed"}
+			}
+		} else {
+			for ; i < len(chars) && !unicode.IsSpace(chars[i]); i++ {
+				value.WriteRune(chars[i])
+			}
+		}
+
+		if key != "" {
+			cmd.options[key] = value.String()
+		} else {
+			cmd.args = append(cmd.args, value.String())
+		}
+	}
+
+	return cmd, nil
+}
+
+// argument returns the arguments as one, such as a quiz name with spaces, without the quotes it may be in.
+// Unless quotes or options are used it is the text exactly as typed
+func (cmd *command) argument() string {
+	if len(cmd.options) > 0 || (len(cmd.args) == 1 && strings.IndexFunc(cmd.text, isQuote) == 0) {
+		return strings.Join(cmd.args, " ")
+	}
+	return cmd.text
+}
+
+// checkOptions fails for options the command does not take
+func (cmd *command) checkOptions(allowed ...string) error {
+	for key := range cmd.options {
+		found := false
+		for _, option := range allowed {
+			found = found || key == option
+		}
+		if !found {
+			return &commandError{command: cmd.name, problem: "unknown option %s", args: []interface{}{key}}
+		}
+	}
+	return nil
+}
+
+// intOption returns the option as a number from 1 up, or fallback when it is not given
+func (cmd *command) intOption(key string, fallback int) (int, error) {
+	value, found := cmd.options[key]
+	if !found {
+		return fallback, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, &commandError{command: cmd.name, problem: "%s must be a number from 1 up, not %q", args: []interface{}{key, value}}
+	}
+	return number, nil
+}
+
+// choiceOption returns the option, which must be one of the choices, or the first choice when it is not given
+func (cmd *command) choiceOption(key string, choices ...string) (string, error) {
+	value, found := cmd.options[key]
+	if !found {
+		return choices[0], nil
+	}
+	for _, choice := range choices {
+		if strings.EqualFold(value, choice) {
+			return choice, nil
+		}
+	}
+	return "", &commandError{command: cmd.name, problem: "%s must be one of %s, not %q", args: []interface{}{key, strings.Join(choices, ", "), value}}
+}
+
+// commandUsageText tells the user in the language of the chat what is wrong with their command and how to use it
+func commandUsageText(chatID int64, err error, usage string) string {
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		return tr(chatID, "Sorry, %s.\nUsage: %s", tr(chatID, cmdErr.problem, cmdErr.args...), usage)
+	}
+	return tr(chatID, "Usage: %s", usage)
+}
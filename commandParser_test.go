@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	cmd, err := parseCommand(`/try_quiz@Real_Quiz_Bot "Bio 101" mode=typed n=10`, "real_quiz_bot")
+	if err != nil || cmd.name != "try_quiz" || cmd.argument() != "Bio 101" || cmd.options["mode"] != "typed" || cmd.options["n"] != "10" {
+		t.Errorf("Expected a quoted argument and options but got: %+v %v", cmd, err)
+	}
+
+	if _, err := parseCommand("/help@other_bot", "real_quiz_bot"); !errors.Is(err, errOtherBot) {
+		t.Errorf("Expected a command for another bot to be told apart but got: %v", err)
+	}
+
+	cmd, err = parseCommand("/add_qns   ", "real_quiz_bot")
+	if err != nil || cmd.name != "add_qns" || cmd.argument() != "" {
+		t.Errorf("Expected no argument after trailing spaces but got: %+v %v", cmd, err)
+	}
+
+	cmd, _ = parseCommand("/add_qns Tom's  quiz", "real_quiz_bot")
+	if cmd.argument() != "Tom's  quiz" {
+		t.Errorf("Expected an unquoted argument exactly as typed but got: %q", cmd.argument())
+	}
+
+	cmd, _ = parseCommand("/try_quiz “Bio 101”", "real_quiz_bot")
+	if cmd.argument() != "Bio 101" {
+		t.Errorf("Expected curly quotes to be removed but got: %q", cmd.argument())
+	}
+
+	cmd, err = parseCommand(`/try_quiz "Bio 101`, "real_quiz_bot")
+	var cmdErr *commandError
+	if !errors.As(err, &cmdErr) || cmd.text != `"Bio 101` {
+		t.Errorf("Expected an unclosed quote error along with the command but got: %+v %v", cmd, err)
+	}
+}
+
+func TestCommandOptions(t *testing.T) {
+	cmd, _ := parseCommand("/try_quiz demo n=0 colour=red", "real_quiz_bot")
+
+	if err := cmd.checkOptions("n", "mode"); err == nil || !strings.Contains(err.Error(), "unknown option colour") {
+		t.Errorf("Expected an unknown option error but got: %v", err)
+	}
+	if _, err := cmd.intOption("n", 5); err == nil {
+		t.Error("Expected n=0 to be refused")
+	}
+	if mode, err := cmd.choiceOption("mode", "reveal", "typed"); err != nil || mode != "reveal" {
+		t.Errorf("Expected the first choice by default but got: %q %v", mode, err)
+	}
+
+	_, err := cmd.intOption("n", 5)
+	if outputStr := commandUsageText(1, err, "/try_quiz quiz_name n=10"); !strings.HasPrefix(outputStr, "Sorry, n must be a number") || !strings.HasSuffix(outputStr, "Usage: /try_quiz quiz_name n=10") {
+		t.Errorf("Expected the problem and usage but got: %q", outputStr)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// commandScope is where a command is offered: in private chats, in groups, or in groups to their admins only
+type commandScope int
+
+const (
+	scopePrivate commandScope = 1 << iota
+	scopeGroup
+	scopeGroupAdmin
+)
+
+// commandHandler handles a command the user sent
+type commandHandler func(update tgbotapi.Update)
+
+// botCommand is a command the bot understands, as listed in /help and in telegram's command menu.
+// Its handler is set by the main loop with handleCommand, and its description is translated like other texts
+type botCommand struct {
+	name        string
+	usage       string // its arguments, e.g. quiz_name
+	description string
+	scopes      commandScope
+	anyone      bool // handled for any member of the chat in any state, also before they have started the bot
+	handler     commandHandler
+}
+
+// botCommands are listed in this order
+var botCommands = []botCommand{
+	{"help", "", "get list of commands", scopePrivate | scopeGroup, false, nil},
+	{"add_quiz", "quiz_name", "add a new quiz", scopePrivate, false, nil},
+	{"add_qns", "quiz_name", "add questions to a selected quiz", scopePrivate, false, nil},
+	{"edit_qns", "quiz_name", "edit questions of a selected quiz", scopePrivate, false, nil},
+	{"remove_qns", "quiz_name", "remove questions from a selected quiz", scopePrivate, false, nil},
+	{"try_quiz", "quiz_name n=10 mode=typed", "try a selected quiz, optionally only some questions or with typed answers", scopePrivate | scopeGroup, false, nil},
+	{"delete_quiz", "quiz_name", "delete a selected quiz", scopePrivate, false, nil},
+	{"list_quizzes", "#tag or folder", "list your quizzes, optionally filtered", scopePrivate, false, nil},
+	{"tag", "quiz_name", "add tags to a selected quiz", scopePrivate, false, nil},
+	{"untag", "quiz_name", "remove tags from a selected quiz", scopePrivate, false, nil},
+	{"folder", "quiz_name", "move a selected quiz into a folder", scopePrivate, false, nil},
+	{"trash", "", "list your deleted quizzes and questions", scopePrivate, false, nil},
+	{"restore", "number", "restore an item from your trash", scopePrivate, false, nil},
+	{"undo", "", "undo your last deletion", scopePrivate, false, nil},
+	{"share", "quiz_name", "get a link for friends to try a selected quiz", scopePrivate, false, nil},
+	{"unshare", "quiz_name", "stop sharing a selected quiz", scopePrivate, false, nil},
+	{"visibility", "quiz_name", "choose who can try a selected quiz", scopePrivate, false, nil},
+	{"copy_quiz", "friend quiz_name", "copy a friend's quiz to your quizzes", scopePrivate, false, nil},
+	{"sync", "quiz_name", "pull changes from the source of a copied quiz", scopePrivate, false, nil},
+	{"host_quiz", "quiz_name", "host a live quiz for everyone in a group chat", scopeGroup, true, nil},
+	{"poll_quiz", "quiz_name", "play a quiz as telegram quiz polls, alone or in a group", scopePrivate | scopeGroup, true, nil},
+	{"stop_quiz", "", "end the live quiz you are hosting", scopePrivate | scopeGroup, true, nil},
+	{"leaderboard", "weekly, monthly or all", "show the group's leaderboard", scopeGroup, true, nil},
+	{"weekly_summary", "on or off", "post the group's leaderboard every week", scopeGroupAdmin, true, nil},
+	{"challenge", "@friend quiz_name", "challenge a friend to one of your quizzes", scopePrivate, false, nil},
+	{"daily", "quiz_name HH:MM time_zone", "get a question from a quiz every day", scopePrivate, false, nil},
+	{"daily_off", "quiz_name", "stop the daily questions from a quiz", scopePrivate, false, nil},
+	{"profile", "", "see your quizzes, study streak and reminders", scopePrivate, false, nil},
+	{"nudge", "on or off", "get an evening reminder when your streak is about to break", scopePrivate, false, nil},
+	{"formatting", "on or off", "keep the bold, italics, code and spoilers of questions you add", scopePrivate, false, nil},
+	{"browse", "", "browse public quizzes by category", scopePrivate, false, nil},
+	{"search", "keywords", "search public quizzes", scopePrivate, false, nil},
+	{"editors", "quiz_name", "invite or remove co-editors of a selected quiz", scopePrivate, false, nil},
+	{"history", "quiz_name", "see the latest changes to a selected quiz", scopePrivate, false, nil},
+	{"language", "code", "choose the language I reply in", scopePrivate | scopeGroup, true, nil},
+	{"get_my_id", "", "get your telegram ID number", scopePrivate | scopeGroup, false, nil},
+}
+
+// findCommand returns the command with the name
+func findCommand(name string) (*botCommand, bool) {
+	for i := range botCommands {
+		if botCommands[i].name == name {
+			return &botCommands[i], true
+		}
+	}
+	return nil, false
+}
+
+// handleCommand sets the handler of a listed command
+func handleCommand(name string, handler commandHandler) {
+	command, found := findCommand(name)
+	if !found {
+		panic("handler for unlisted command /" + name)
+	}
+	command.handler = handler
+}
+
+// unhandledCommands lists the commands without a handler, which the bot checks for when it starts
+func unhandledCommands() []string {
+	var names []string
+	for _, command := range botCommands {
+		if command.handler == nil {
+			names = append(names, "/"+command.name)
+		}
+	}
+	return names
+}
+
+// commandsIn returns the commands offered in any of the scopes
+func commandsIn(scopes commandScope) []botCommand {
+	var commands []botCommand
+	for _, command := range botCommands {
+		if command.scopes&scopes != 0 {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// commandUsage returns how the command is used, e.g. /add_qns quiz_name
+func commandUsage(name string) string {
+	for _, command := range botCommands {
+		if command.name == name {
+			return strings.TrimSpace("/" + command.name + " " + command.usage)
+		}
+	}
+	return "/" + name
+}
+
+// helpText lists the commands offered in the scopes, in the language
+func helpText(lang string, scopes commandScope) string {
+	text := translate(lang, "I understand the following commands: \n")
+	for _, command := range commandsIn(scopes) {
+		text += "<strong>/" + command.name
+		if command.usage != "" {
+			text += " <i>" + escapeHTML(command.usage) + "</i>"
+		}
+		text += "</strong> - " + translate(lang, command.description) + "\n"
+	}
+	return strings.TrimSuffix(text, "\n")
+}
+
+func sendHelpMessage(chat *tgbotapi.Chat, bot *tgbotapi.BotAPI) {
+	chatID := chat.ID
+	scopes := scopePrivate
+	if isGroupChat(chat) {
+		scopes = scopeGroup | scopeGroupAdmin
+	}
+
+	msg := tgbotapi.NewMessage(chatID, helpText(chatLanguage(chatID), scopes))
+	msg.ParseMode = "HTML"
+
+	sendLongMessage(msg, bot)
+}
+
+// menuCommands are the commands of a scope as telegram's command menu lists them, in the language
+func menuCommands(lang string, scopes commandScope) []tgbotapi.BotCommand {
+	var commands []tgbotapi.BotCommand
+	for _, command := range commandsIn(scopes) {
+		commands = append(commands, tgbotapi.BotCommand{Command: command.name, Description: translate(lang, command.description)})
+	}
+	return commands
+}
+
+// languageMenus are the command menus of the scopes, one for each language, for telegram to show in the scope
+func languageMenus(scope tgbotapi.BotCommandScope, scopes commandScope) []tgbotapi.SetMyCommandsConfig {
+	var menus []tgbotapi.SetMyCommandsConfig
+	for lang := range languageNames {
+		menuLang := lang
+		if lang == defaultLanguage {
+			menuLang = ""
+		}
+		menus = append(menus, tgbotapi.NewSetMyCommandsWithScopeAndLanguage(scope, menuLang, menuCommands(lang, scopes)...))
+	}
+	return menus
+}
+
+// publishCommands sets the command menus users see in private chats, in groups and as group admins.
+// Telegram shows the menu in the language of the user's app, or the English one for other languages
+func publishCommands(bot *tgbotapi.BotAPI) {
+	var menus []tgbotapi.SetMyCommandsConfig
+	menus = append(menus, languageMenus(tgbotapi.NewBotCommandScopeAllPrivateChats(), scopePrivate)...)
+	menus = append(menus, languageMenus(tgbotapi.NewBotCommandScopeAllGroupChats(), scopeGroup)...)
+	menus = append(menus, languageMenus(tgbotapi.NewBotCommandScopeAllChatAdministrators(), scopeGroup|scopeGroupAdmin)...)
+
+	for _, menu := range menus {
+		if _, err := bot.Request(menu); err != nil {
+			log.Printf("An error has occurred trying to publish the command menu: %s", err)
+		}
+	}
+}
+
+// adminMenus are the groups where each bot admin has been given the group admin menu since the bot started
+var adminMenus = make(map[[2]int64]bool)
+
+// publishAdminCommands gives a bot admin the group admin menu in a group, as they can change the settings of any group.
+// The menu is set for them in each group the first time they write there, as a chat member's menu belongs to one group
+func publishAdminCommands(bot *tgbotapi.BotAPI, chatID int64, userID int64) {
+	key := [2]int64{chatID, userID}
+	if adminMenus[key] {
+		return
+	}
+	adminMenus[key] = true
+
+	for _, menu := range languageMenus(tgbotapi.NewBotCommandScopeChatMember(chatID, userID), scopeGroup|scopeGroupAdmin) {
+		queueMessage(bot, chatID, menu)
+	}
+}
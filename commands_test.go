@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestBotCommands(t *testing.T) {
+	// telegram refuses the whole menu if any command breaks its rules
+	validName := regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	seen := make(map[string]bool)
+	for _, command := range botCommands {
+		if !validName.MatchString(command.name) || seen[command.name] {
+			t.Errorf("Expected a unique command name of lowercase letters, digits and underscores but got: %q", command.name)
+		}
+		if len(command.description) < 3 || len(command.description) > 256 {
+			t.Errorf("Expected a description of 3 to 256 characters for /%s", command.name)
+		}
+		if command.scopes == 0 {
+			t.Errorf("Expected /%s to be offered somewhere", command.name)
+		}
+		seen[command.name] = true
+	}
+
+	if len(menuCommands(defaultLanguage, scopePrivate)) > 100 {
+		t.Error("Expected at most 100 commands in a menu")
+	}
+}
+
+func TestHelpText(t *testing.T) {
+	outputStr := helpText(defaultLanguage, scopePrivate)
+	if !strings.Contains(outputStr, "<strong>/add_qns <i>quiz_name</i></strong> - add questions to a selected quiz\n") {
+		t.Errorf("Expected commands with their usage but got: %q", outputStr)
+	}
+	if strings.Contains(outputStr, "/weekly_summary") {
+		t.Error("Expected group admin commands only in group help")
+	}
+
+	groupStr := helpText(defaultLanguage, scopeGroup|scopeGroupAdmin)
+	if !strings.Contains(groupStr, "/host_quiz") || !strings.Contains(groupStr, "/weekly_summary") || strings.Contains(groupStr, "/add_quiz") {
+		t.Errorf("Expected only group commands but got: %q", groupStr)
+	}
+
+	if usage := commandUsage("leaderboard"); usage != "/leaderboard weekly, monthly or all" {
+		t.Errorf("Expected the usage of /leaderboard but got: %q", usage)
+	}
+}
+
+func TestHandleCommand(t *testing.T) {
+	saved := botCommands
+	defer func() { botCommands = saved }()
+	botCommands = []botCommand{
+		{"help", "", "get list of commands", scopePrivate, false, nil},
+		{"get_my_id", "", "get your telegram ID number", scopePrivate, false, nil},
+	}
+
+	handled := false
+	handleCommand("help", func(update tgbotapi.Update) { handled = true })
+	if missing := unhandledCommands(); len(missing) != 1 || missing[0] != "/get_my_id" {
+		t.Errorf("Expected only /get_my_id to be unhandled but got: %v", missing)
+	}
+
+	command, found := findCommand("help")
+	if !found {
+		t.Fatalf("Expected /help to be found")
+	}
+	command.handler(tgbotapi.Update{})
+	if !handled {
+		t.Errorf("Expected the handler of /help to be called")
+	}
+
+	if _, found := findCommand("start"); found {
+		t.Errorf("Expected /start not to be listed")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a handler for an unlisted command to panic")
+		}
+	}()
+	handleCommand("start", func(update tgbotapi.Update) {})
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// config is the bot's configuration. Each setting is read from the config file, then from the
+// environment and then from the command line, each overriding the one before
+type config struct {
+	Token          string
+	StorageBackend string
+	StorageDSN     string // for firestore, the service account credentials file
+	MediaBucket    string
+
+	Mode          string // polling or webhook
+	WebhookURL    string
+	WebhookSecret string // sent by telegram with each update, so that nobody else can post updates
+	ListenAddr    string
+
+	Debug    bool
+	AdminIDs []int64 // telegram user IDs of the bot's admins
+
+	GlobalSendRate  float64 // messages a second
+	PrivateSendRate float64 // messages a second to one private chat
+	GroupSendRate   float64 // messages a minute to one group
+	MaxSendAttempts int
+	ListPageSize    int
+}
+
+// configSettings are the settings by their key in the config file and on the command line, with their environment variable
+var configSettings = []struct {
+	key   string
+	env   string
+	usage string
+}{
+	{"token", "TELEGRAM_APITOKEN", "telegram bot token"},
+	{"storage", "QUIZBOT_STORAGE", "storage backend, only firestore for now"},
+	{"storage_dsn", "QUIZBOT_STORAGE_DSN", "storage to connect to, for firestore the service account credentials file"},
+	{"media_bucket", "MEDIA_BUCKET", "firebase storage bucket that keeps copies of question media"},
+	{"mode", "QUIZBOT_MODE", "how updates are received, polling or webhook"},
+	{"webhook_url", "QUIZBOT_WEBHOOK_URL", "public https URL telegram sends updates to in webhook mode"},
+	{"webhook_secret", "QUIZBOT_WEBHOOK_SECRET", "secret token telegram sends with each update in webhook mode"},
+	{"listen_addr", "QUIZBOT_LISTEN_ADDR", "address the webhook server listens on"},
+	{"debug", "QUIZBOT_DEBUG", "log every request to telegram"},
+	{"admin_ids", "QUIZBOT_ADMIN_IDS", "comma separated telegram user IDs of the bot's admins"},
+	{"global_send_rate", "QUIZBOT_GLOBAL_SEND_RATE", "messages sent a second in all"},
+	{"private_send_rate", "QUIZBOT_PRIVATE_SEND_RATE", "messages sent a second to one private chat"},
+	{"group_send_rate", "QUIZBOT_GROUP_SEND_RATE", "messages sent a minute to one group"},
+	{"max_send_attempts", "QUIZBOT_MAX_SEND_ATTEMPTS", "attempts at a request to telegram before giving up"},
+	{"list_page_size", "QUIZBOT_LIST_PAGE_SIZE", "lines on each page of a listing"},
+}
+
+func defaultConfig() *config {
+	return &config{
+		StorageBackend:  "firestore",
+		StorageDSN:      "firebase_service_acct.json",
+		Mode:            "polling",
+		ListenAddr:      ":8443",
+		GlobalSendRate:  globalSendRate,
+		PrivateSendRate: privateSendRate,
+		GroupSendRate:   groupSendRate * 60,
+		MaxSendAttempts: maxSendAttempts,
+		ListPageSize:    listPageSize,
+	}
+}
+
+// set parses the value of the setting with the key
+func (cfg *config) set(key string, value string) error {
+	var err error
+	switch key {
+	case "token":
+		cfg.Token = value
+	case "storage":
+		cfg.StorageBackend = value
+	case "storage_dsn":
+		cfg.StorageDSN = value
+	case "media_bucket":
+		cfg.MediaBucket = value
+	case "mode":
+		cfg.Mode = value
+	case "webhook_url":
+		cfg.WebhookURL = value
+	case "webhook_secret":
+		cfg.WebhookSecret = value
+	case "listen_addr":
+		cfg.ListenAddr = value
+	case "debug":
+		cfg.Debug, err = strconv.ParseBool(value)
+	case "admin_ids":
+		cfg.AdminIDs = nil
+		for _, field := range strings.Split(strings.Trim(value, "[]"), ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			id, parseErr := strconv.ParseInt(field, 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("%q is not a user ID", field)
+			}
+			cfg.AdminIDs = append(cfg.AdminIDs, id)
+		}
+	case "global_send_rate":
+		cfg.GlobalSendRate, err = strconv.ParseFloat(value, 64)
+	case "private_send_rate":
+		cfg.PrivateSendRate, err = strconv.ParseFloat(value, 64)
+	case "group_send_rate":
+		cfg.GroupSendRate, err = strconv.ParseFloat(value, 64)
+	case "max_send_attempts":
+		cfg.MaxSendAttempts, err = strconv.Atoi(value)
+	case "list_page_size":
+		cfg.ListPageSize, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, key)
+	}
+	return nil
+}
+
+// parseConfigFile reads settings written as `key = value` (TOML) or `key: value` (YAML), one a line. Only this
+// flat subset of the two is read: # starts a comment, values may be quoted, and a list is written as [1, 2],
+// over several lines in TOML, or in YAML as `- item` lines under its key. Tables and nested keys are refused
+func parseConfigFile(content string) (map[string]string, error) {
+	settings := make(map[string]string)
+	listKey := ""  // key of the YAML list being read
+	arrayKey := "" // key of the TOML array being read, until it is closed
+	for i, rawLine := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(stripComment(rawLine))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		if arrayKey != "" {
+			settings[arrayKey] += " " + line
+			if strings.Contains(line, "]") {
+				arrayKey = ""
+			}
+			continue
+		}
+
+		if line == "-" || strings.HasPrefix(line, "- ") {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", i+1)
+			}
+			if settings[listKey] != "" {
+				settings[listKey] += ", "
+			}
+			settings[listKey] += unquoteValue(strings.TrimSpace(line[1:]))
+			continue
+		}
+		listKey = ""
+
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, write each setting on a line of its own", i+1)
+		}
+		if rawLine[0] == ' ' || rawLine[0] == '\t' {
+			return nil, fmt.Errorf("line %d: nested settings are not supported", i+1)
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator < 1 {
+			return nil, fmt.Errorf("line %d: expected key = value or key: value", i+1)
+		}
+		key := strings.TrimSpace(line[:separator])
+		value := strings.TrimSpace(line[separator+1:])
+		switch {
+		case value == "" && line[separator] == ':':
+			listKey = key
+		case strings.HasPrefix(value, "[") && !strings.Contains(value, "]"):
+			arrayKey = key
+		}
+		settings[key] = unquoteValue(value)
+	}
+
+	if arrayKey != "" {
+		return nil, fmt.Errorf("the list of %s is not closed with ]", arrayKey)
+	}
+	return settings, nil
+}
+
+// stripComment removes a comment from the end of the line. A # only starts one at the start of the line
+// or after a space, and not in quotes
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteValue removes the double or single quotes around a value
+func unquoteValue(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// loadConfig reads the config file named by -config or QUIZBOT_CONFIG, then the environment and then the flags in args,
+// and checks the result
+func loadConfig(args []string, getenv func(string) string, readFile func(string) ([]byte, error)) (*config, error) {
+	flags := flag.NewFlagSet("quizBot", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file, in TOML or YAML key/value form")
+	for _, setting := range configSettings {
+		flags.String(setting.key, "", setting.usage+" ($"+setting.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+
+	if *configPath == "" {
+		*configPath = getenv("QUIZBOT_CONFIG")
+	}
+	if *configPath != "" {
+		content, err := readFile(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+		settings, err := parseConfigFile(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", *configPath, err)
+		}
+		for key, value := range settings {
+			if err := cfg.set(key, value); err != nil {
+				return nil, fmt.Errorf("config file %s: %w", *configPath, err)
+			}
+		}
+	}
+
+	for _, setting := range configSettings {
+		if value := getenv(setting.env); value != "" {
+			if err := cfg.set(setting.key, value); err != nil {
+				return nil, fmt.Errorf("environment variable %s: %w", setting.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && flagErr == nil {
+			if err := cfg.set(f.Name, f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("flag -%s: %w", f.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	return cfg, cfg.validate()
+}
+
+// validate reports every problem with the configuration at once
+func (cfg *config) validate() error {
+	var problems []string
+
+	if cfg.Token == "" {
+		problems = append(problems, "the telegram bot token is missing, set TELEGRAM_APITOKEN, -token or token in the config file")
+	}
+
+	if cfg.StorageBackend != "firestore" {
+		problems = append(problems, fmt.Sprintf("storage backend %q is not supported, use firestore", cfg.StorageBackend))
+	} else if _, err := os.Stat(cfg.StorageDSN); err != nil {
+		problems = append(problems, fmt.Sprintf("the firestore credentials file %q cannot be read: %s", cfg.StorageDSN, err))
+	}
+
+	switch cfg.Mode {
+	case "polling":
+	case "webhook":
+		if webhookURL, err := url.Parse(cfg.WebhookURL); err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+			problems = append(problems, fmt.Sprintf("webhook mode needs an https webhook_url, got %q", cfg.WebhookURL))
+		}
+		if !validWebhookSecret(cfg.WebhookSecret) {
+			problems = append(problems, "webhook mode needs a webhook_secret of 1 to 256 letters, digits, _ and -")
+		}
+		if cfg.ListenAddr == "" {
+			problems = append(problems, "webhook mode needs a listen_addr")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mode %q is not supported, use polling or webhook", cfg.Mode))
+	}
+
+	if cfg.GlobalSendRate <= 0 || cfg.PrivateSendRate <= 0 || cfg.GroupSendRate <= 0 {
+		problems = append(problems, "send rates must be more than 0")
+	}
+	if cfg.MaxSendAttempts < 1 {
+		problems = append(problems, "max_send_attempts must be at least 1")
+	}
+	if cfg.ListPageSize < 1 || cfg.ListPageSize > 100 {
+		problems = append(problems, "list_page_size must be from 1 to 100")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validWebhookSecret reports whether telegram accepts the secret token, which must be 1 to 256 letters, digits, _ and -
+func validWebhookSecret(secret string) bool {
+	if secret == "" || len(secret) > 256 {
+		return false
+	}
+	for _, r := range secret {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// isAdmin reports whether the user is one of the bot's admins
+func (cfg *config) isAdmin(userID int64) bool {
+	for _, adminID := range cfg.AdminIDs {
+		if adminID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// sendLimits are the configured limits of the outbox
+func (cfg *config) sendLimits() sendLimits {
+	return sendLimits{
+		global:   cfg.GlobalSendRate,
+		private:  cfg.PrivateSendRate,
+		group:    cfg.GroupSendRate / 60,
+		attempts: cfg.MaxSendAttempts,
+	}
+}
+
+// receiveUpdates starts receiving updates by long polling or, in webhook mode, by serving the webhook
+func receiveUpdates(bot *tgbotapi.BotAPI, cfg *config) (tgbotapi.UpdatesChannel, error) {
+	if cfg.Mode != "webhook" {
+		// a webhook left set stops polling from getting updates
+		if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			return nil, fmt.Errorf("could not remove the webhook: %w", err)
+		}
+
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		return bot.GetUpdatesChan(u), nil
+	}
+
+	webhookURL, err := url.Parse(cfg.WebhookURL)
+	if err != nil {
+		return nil, err
+	}
+	// the library's webhook config has no secret token, so the webhook is set with its parameters directly
+	params := tgbotapi.Params{"url": webhookURL.String(), "secret_token": cfg.WebhookSecret}
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		return nil, fmt.Errorf("could not set the webhook: %w", err)
+	}
+
+	pattern := webhookURL.Path
+	if pattern == "" {
+		pattern = "/"
+	}
+	updates := make(chan tgbotapi.Update, bot.Buffer)
+	http.HandleFunc(pattern, webhookHandler(bot, cfg.WebhookSecret, updates))
+	go func() {
+		log.Fatalln(http.ListenAndServe(cfg.ListenAddr, nil))
+	}()
+	return updates, nil
+}
+
+// webhookHandler passes on the updates posted to the webhook with the secret token, refusing any others
+func webhookHandler(bot *tgbotapi.BotAPI, secret string, updates chan<- tgbotapi.Update) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "wrong secret token", http.StatusUnauthorized)
+			return
+		}
+
+		update, err := bot.HandleUpdate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updates <- *update
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	toml := "# bot settings\ntoken = \"123:abc\"\nadmin_ids = [1, 2]\n"
+	settings, err := parseConfigFile(toml)
+	if err != nil || settings["token"] != "123:abc" || settings["admin_ids"] != "[1, 2]" {
+		t.Errorf("Expected TOML settings but got: %v %v", settings, err)
+	}
+
+	yaml := "---\nmode: webhook\nwebhook_url: 'https://example.com/hook'\n"
+	settings, err = parseConfigFile(yaml)
+	if err != nil || settings["mode"] != "webhook" || settings["webhook_url"] != "https://example.com/hook" {
+		t.Errorf("Expected YAML settings but got: %v %v", settings, err)
+	}
+
+	if _, err := parseConfigFile("token"); err == nil {
+		t.Error("Expected an error for a line without a value")
+	}
+
+	commented := "token = \"123:abc\" # from botfather\nmedia_bucket = 'quiz#media' # copies\nstorage_dsn = creds.json#1\n"
+	settings, err = parseConfigFile(commented)
+	if err != nil || settings["token"] != "123:abc" || settings["media_bucket"] != "quiz#media" || settings["storage_dsn"] != "creds.json#1" {
+		t.Errorf("Expected trailing comments to be removed but got: %v %v", settings, err)
+	}
+
+	lists := "admin_ids:\n  - 1\n  - 2 # the owner\nmode: polling\n"
+	settings, err = parseConfigFile(lists)
+	if err != nil || settings["admin_ids"] != "1, 2" || settings["mode"] != "polling" {
+		t.Errorf("Expected a YAML list but got: %v %v", settings, err)
+	}
+
+	array := "admin_ids = [\n  1, # the owner\n  2,\n]\n"
+	settings, err = parseConfigFile(array)
+	if err != nil || settings["admin_ids"] != "[ 1, 2, ]" {
+		t.Errorf("Expected a TOML array over several lines but got: %v %v", settings, err)
+	}
+
+	for _, unsupported := range []string{"[bot]\ntoken = x\n", "bot:\n  token: x\n", "admin_ids = [1,\n"} {
+		if _, err := parseConfigFile(unsupported); err == nil {
+			t.Errorf("Expected an error for %q", unsupported)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	credentials := filepath.Join(t.TempDir(), "credentials.json")
+	os.WriteFile(credentials, []byte("{}"), 0600)
+
+	file := "token = from-file\nstorage_dsn = " + credentials + "\nlist_page_size = 10\nadmin_ids = 7, 8\n"
+	env := map[string]string{
+		"QUIZBOT_CONFIG":    "bot.toml",
+		"TELEGRAM_APITOKEN": "from-env",
+	}
+	readFile := func(path string) ([]byte, error) { return []byte(file), nil }
+
+	// flags win over the environment, which wins over the file
+	cfg, err := loadConfig([]string{"-list_page_size", "30"}, func(key string) string { return env[key] }, readFile)
+	if err != nil {
+		t.Fatalf("Expected a valid config but got: %s", err)
+	}
+	if cfg.Token != "from-env" || cfg.ListPageSize != 30 || cfg.Mode != "polling" || !cfg.isAdmin(8) || cfg.isAdmin(9) {
+		t.Errorf("Expected settings from each source in turn but got: %+v", cfg)
+	}
+
+	_, err = loadConfig([]string{"-mode", "webhook", "-max_send_attempts", "0"}, func(key string) string { return env[key] }, readFile)
+	if err == nil || !strings.Contains(err.Error(), "https webhook_url") || !strings.Contains(err.Error(), "webhook_secret") || !strings.Contains(err.Error(), "max_send_attempts") {
+		t.Errorf("Expected every problem to be reported but got: %v", err)
+	}
+
+	_, err = loadConfig([]string{"-mode", "webhook", "-webhook_url", "https://example.com/hook", "-webhook_secret", "s3cret-token"}, func(key string) string { return env[key] }, readFile)
+	if err != nil {
+		t.Errorf("Expected a webhook with a secret to be valid but got: %s", err)
+	}
+
+	_, err = loadConfig([]string{"-debug", "sometimes"}, func(key string) string { return env[key] }, readFile)
+	if err == nil || !strings.Contains(err.Error(), "flag -debug") {
+		t.Errorf("Expected the flag to be named in the error but got: %v", err)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	updates := make(chan tgbotapi.Update, 1)
+	handler := webhookHandler(&tgbotapi.BotAPI{}, "s3cret", updates)
+
+	post := func(token string) int {
+		req := httptest.NewRequest("POST", "/hook", strings.NewReader(`{"update_id":5}`))
+		if token != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if code := post(""); code != http.StatusUnauthorized || len(updates) != 0 {
+		t.Errorf("Expected an update without the secret to be refused but got: %d", code)
+	}
+	if code := post("guess"); code != http.StatusUnauthorized || len(updates) != 0 {
+		t.Errorf("Expected an update with the wrong secret to be refused but got: %d", code)
+	}
+	if code := post("s3cret"); code != http.StatusOK || len(updates) != 1 || (<-updates).UpdateID != 5 {
+		t.Errorf("Expected the update with the secret to be passed on but got: %d", code)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones work without a time zone database on the host
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errInvalidDailyTime = errors.New("time should be HH:MM")
+
+// dailySubscription sends one question of a quiz every day at the same time
+type dailySubscription struct {
+	quizName   string
+	hour       int
+	minute     int
+	timezone   string
+	nextSendAt time.Time
+}
+
+func dailyCollection(client *firestore.Client, userID string) *firestore.CollectionRef {
+	return client.Collection("USERS").Doc(userID).Collection("DAILY")
+}
+
+// daily questions that have been sent are kept by message ID, so their buttons work outside a quiz session
+func dailySentCollection(client *firestore.Client, userID string) *firestore.CollectionRef {
+	return client.Collection("USERS").Doc(userID).Collection("DAILY_SENT")
+}
+
+// parseDailyArgs splits "quiz name HH:MM" with an optional trailing time zone such as "Asia/Singapore" or "UTC+8"
+func parseDailyArgs(args string) (string, int, int, string, error) {
+	fields := strings.Fields(args)
+
+	timezone := ""
+	if len(fields) > 0 {
+		last := fields[len(fields)-1]
+		if _, _, err := parseDailyTime(last); err != nil {
+			if _, err := loadTimezone(last); err != nil {
+				return "", 0, 0, "", err
+			}
+			timezone = last
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	if len(fields) < 2 {
+		return "", 0, 0, "", errInvalidDailyTime
+	}
+
+	hour, minute, err := parseDailyTime(fields[len(fields)-1])
+	if err != nil {
+		return "", 0, 0, "", err
+	}
+	return strings.Join(fields[:len(fields)-1], " "), hour, minute, timezone, nil
+}
+
+func parseDailyTime(input string) (int, int, error) {
+	timeParts := strings.SplitN(input, ":", 2)
+	if len(timeParts) != 2 {
+		return 0, 0, errInvalidDailyTime
+	}
+
+	hour, err1 := strconv.Atoi(timeParts[0])
+	minute, err2 := strconv.Atoi(timeParts[1])
+	if err1 != nil || err2 != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, errInvalidDailyTime
+	}
+	return hour, minute, nil
+}
+
+// loadTimezone accepts IANA names such as "Europe/Berlin", and fixed offsets such as "UTC+8" or "UTC-03:30"
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || strings.EqualFold(name, "UTC") {
+		return time.UTC, nil
+	}
+
+	upperName := strings.ToUpper(name)
+	if strings.HasPrefix(upperName, "UTC+") || strings.HasPrefix(upperName, "UTC-") {
+		sign := 1
+		if upperName[3] == '-' {
+			sign = -1
+		}
+
+		hours, minutes, err := parseDailyTime(upperName[4:])
+		if err != nil {
+			hours, err = strconv.Atoi(upperName[4:])
+			minutes = 0
+		}
+		if err != nil || hours > 14 {
+			return nil, fmt.Errorf("unknown time zone %s", name)
+		}
+		return time.FixedZone("UTC"+upperName[3:], sign*(hours*3600+minutes*60)), nil
+	}
+
+	return time.LoadLocation(name)
+}
+
+// nextDailySend returns the first time after now that is hour:minute in the location
+func nextDailySend(now time.Time, hour int, minute int, location *time.Location) time.Time {
+	localNow := now.In(location)
+	next := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), hour, minute, 0, 0, location)
+	if !next.After(now) {
+		next = time.Date(localNow.Year(), localNow.Month(), localNow.Day()+1, hour, minute, 0, 0, location)
+	}
+	return next
+}
+
+// subscribeDaily saves the user's daily question for the quiz, replacing any earlier time for it
+func subscribeDaily(ctx context.Context, client *firestore.Client, userID string, quizName string, hour int, minute int, timezone string) (time.Time, error) {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	nextSendAt := nextDailySend(time.Now(), hour, minute, location)
+	_, err = dailyCollection(client, userID).Doc(quizName).Set(ctx, map[string]interface{}{
+		"quizName":   quizName,
+		"hour":       hour,
+		"minute":     minute,
+		"timezone":   timezone,
+		"nextSendAt": nextSendAt,
+	})
+	return nextSendAt, err
+}
+
+func unsubscribeDaily(ctx context.Context, client *firestore.Client, userID string, quizName string) error {
+	_, err := dailyCollection(client, userID).Doc(quizName).Delete(ctx, firestore.Exists)
+	return err
+}
+
+// userTimezone returns the time zone the user last chose for a daily question, UTC if none
+func userTimezone(ctx context.Context, client *firestore.Client, userID string) string {
+	doc, err := client.Collection("USERS").Doc(userID).Get(ctx)
+	if err != nil {
+		return "UTC"
+	}
+	if timezone, ok := doc.Data()["timezone"].(string); ok && timezone != "" {
+		return timezone
+	}
+	return "UTC"
+}
+
+func setUserTimezone(ctx context.Context, client *firestore.Client, userID string, timezone string) error {
+	_, err := client.Collection("USERS").Doc(userID).Update(ctx, []firestore.Update{{Path: "timezone", Value: timezone}})
+	return err
+}
+
+func loadDailySubscriptions(ctx context.Context, client *firestore.Client, userID string) ([]dailySubscription, error) {
+	docs, err := dailyCollection(client, userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []dailySubscription
+	for _, doc := range docs {
+		subscriptions = append(subscriptions, newDailySubscription(doc.Data()))
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].quizName < subscriptions[j].quizName
+	})
+	return subscriptions, nil
+}
+
+func newDailySubscription(data map[string]interface{}) dailySubscription {
+	var subscription dailySubscription
+	subscription.quizName, _ = data["quizName"].(string)
+	if hour, ok := data["hour"].(int64); ok {
+		subscription.hour = int(hour)
+	}
+	if minute, ok := data["minute"].(int64); ok {
+		subscription.minute = int(minute)
+	}
+	subscription.timezone, _ = data["timezone"].(string)
+	subscription.nextSendAt, _ = data["nextSendAt"].(time.Time)
+	return subscription
+}
+
+func formatDailySubscriptions(chatID int64, subscriptions []dailySubscription) string {
+	if len(subscriptions) == 0 {
+		return tr(chatID, "You have no daily questions. Subscribe with /daily quiz_name HH:MM")
+	}
+
+	text := tr(chatID, "Your daily questions:\n")
+	for _, subscription := range subscriptions {
+		text += tr(chatID, "<strong>%s</strong> at %02d:%02d %s\n", escapeHTML(subscription.quizName), subscription.hour, subscription.minute, subscription.timezone)
+	}
+	return text + tr(chatID, "\nStop one with /daily_off quiz_name")
+}
+
+// claimDailySend moves the subscription's next send to the following day, returning false if it is not due
+// or another instance of the bot already sent it
+func claimDailySend(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, now time.Time) (dailySubscription, bool, error) {
+	var subscription dailySubscription
+	claimed := false
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		subscription = newDailySubscription(doc.Data())
+		if subscription.nextSendAt.After(now) {
+			claimed = false
+			return nil
+		}
+
+		location, err := loadTimezone(subscription.timezone)
+		if err != nil {
+			location = time.UTC
+		}
+
+		// a send missed while the bot was down is only made up once
+		claimed = true
+		return tx.Update(ref, []firestore.Update{
+			{Path: "nextSendAt", Value: nextDailySend(now, subscription.hour, subscription.minute, location)},
+			{Path: "lastSentAt", Value: now},
+		})
+	})
+	return subscription, claimed, err
+}
+
+// sendDueDailyQuestions sends every daily question that is due
+func sendDueDailyQuestions(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, now time.Time) {
+	docs, err := client.CollectionGroup("DAILY").Where("nextSendAt", "<=", now).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load daily questions: %s", err)
+		return
+	}
+
+	for _, doc := range docs {
+		subscription, claimed, err := claimDailySend(ctx, client, doc.Ref, now)
+		if err != nil {
+			log.Printf("An error has occurred trying to claim daily question: %s", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		userID := doc.Ref.Parent.Parent.ID
+		if userInactive(ctx, client, userID) {
+			// the user blocked the bot, so the question is skipped until they unblock it
+			continue
+		}
+		if err := sendDailyQuestion(ctx, client, bot, userID, subscription.quizName); err != nil {
+			log.Printf("An error has occurred trying to send daily question to %s: %s", userID, err)
+		}
+	}
+}
+
+func dailyRevealKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(chatID, buttonLabels[btnRevealAns]), "daily:"+string(btnRevealAns)),
+		),
+	)
+}
+
+func dailyResultKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tr(chatID, buttonLabels[btnCorrect]), "daily:"+string(btnCorrect)),
+			tgbotapi.NewInlineKeyboardButtonData(tr(chatID, buttonLabels[btnWrong]), "daily:"+string(btnWrong)),
+		),
+	)
+}
+
+// sendDailyQuestion sends a random question of the quiz to the user's private chat
+func sendDailyQuestion(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, userID string, quizName string) error {
+	chatID, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return err
+	}
+	loadChatLanguage(ctx, client, chatID, "")
+
+	doc, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		// the quiz has been deleted, so stop sending it
+		return unsubscribeDaily(ctx, client, userID, quizName)
+	}
+	if err != nil {
+		return err
+	}
+
+	questions := quizQuestions(doc.Data())
+	if len(questions) == 0 {
+		return nil
+	}
+
+	var questionList []string
+	for question := range questions {
+		questionList = append(questionList, question)
+	}
+	sort.Strings(questionList)
+	question := questionList[rand.Intn(len(questionList))]
+
+	formatting := quizFormatting(doc.Data())[question]
+
+	sendMedia(chatID, quizAttachments(doc.Data())[question].question, bot)
+	sendFormulas(chatID, question, bot)
+
+	// a long question is sent in parts, with the buttons on the last one
+	chunks := splitMessage(tr(chatID, "<strong>Daily question from %s</strong>\n", escapeHTML(quizName))+formattedText(question, formatting.question), messageLimit)
+	var sentMsg tgbotapi.Message
+	for i, chunk := range chunks {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = "HTML"
+		if i == len(chunks)-1 {
+			msg.ReplyMarkup = dailyRevealKeyboard(chatID)
+		}
+		if sentMsg, err = bot.Send(msg); err != nil {
+			return err
+		}
+	}
+
+	_, err = dailySentCollection(client, userID).Doc(fmt.Sprint(sentMsg.MessageID)).Set(ctx, map[string]interface{}{
+		"quizName":   quizName,
+		"question":   question,
+		"answer":     questions[question],
+		"formatting": formatting.data(),
+		"sentAt":     time.Now(),
+	})
+	return err
+}
+
+// handleDailyCallback reveals the answer to a daily question, or records whether the user got it right
+func handleDailyCallback(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) string {
+	_, value := parseCallbackData(query.Data)
+	userID := fmt.Sprint(query.From.ID)
+
+	sentRef := dailySentCollection(client, userID).Doc(fmt.Sprint(query.Message.MessageID))
+	doc, err := sentRef.Get(ctx)
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			log.Printf("An error has occurred trying to load daily question: %s", err)
+		}
+		removeInlineKeyboard(query.Message.Chat.ID, query.Message.MessageID, bot)
+		return tr(query.Message.Chat.ID, "This question is no longer active")
+	}
+
+	quizName, _ := doc.Data()["quizName"].(string)
+	question, _ := doc.Data()["question"].(string)
+	answer, _ := doc.Data()["answer"].(string)
+	formatting := newQuestionFormatting(doc.Data()["formatting"])
+	text := tr(query.Message.Chat.ID, "<strong>Daily question from %s</strong>\n", escapeHTML(quizName)) + questionAndAnswerText(query.Message.Chat.ID, question, answer, formatting)
+
+	var edit tgbotapi.EditMessageTextConfig
+	switch buttonAction(value) {
+	case btnRevealAns:
+		sendFormulas(query.Message.Chat.ID, answer, bot)
+
+		// an answer that does not fit moves the buttons to a new message, and the sent question moves with them
+		keyboard := dailyResultKeyboard(query.Message.Chat.ID)
+		if messageID := editLongMessage(query.Message.Chat.ID, query.Message.MessageID, text, &keyboard, bot); messageID != query.Message.MessageID {
+			if _, err := dailySentCollection(client, userID).Doc(fmt.Sprint(messageID)).Set(ctx, doc.Data()); err != nil {
+				log.Printf("An error has occurred trying to save daily question: %s", err)
+			} else if _, err := sentRef.Delete(ctx); err != nil {
+				log.Printf("An error has occurred trying to delete daily question: %s", err)
+			}
+		}
+		return ""
+
+	case btnCorrect, btnWrong:
+		result := string(buttonAction(value))
+		if _, err := sentRef.Update(ctx, []firestore.Update{
+			{Path: "result", Value: result},
+			{Path: "answeredAt", Value: time.Now()},
+		}); err != nil {
+			log.Printf("An error has occurred trying to save daily question result: %s", err)
+		}
+		text += "\n\n" + tr(query.Message.Chat.ID, "<i>Marked %s</i>", tr(query.Message.Chat.ID, buttonLabels[buttonAction(value)]))
+
+		// a reviewed daily question counts towards the user's streak
+		if streakText := recordStudyDayText(ctx, client, query.Message.Chat.ID, userID); streakText != "" {
+			text += "\n" + streakText
+		}
+		chunks := splitMessage(text, messageLimit)
+		edit = tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, chunks[len(chunks)-1])
+
+	default:
+		return ""
+	}
+
+	edit.ParseMode = "HTML"
+	queueMessage(bot, edit.ChatID, edit)
+	return ""
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDailyArgs(t *testing.T) {
+	quizName, hour, minute, timezone, err := parseDailyArgs("demo quiz 08:30 Europe/London")
+	if err != nil || quizName != "demo quiz" || hour != 8 || minute != 30 || timezone != "Europe/London" {
+		t.Errorf("Expected demo quiz at 08:30 Europe/London but got: %q %d:%d %q %v", quizName, hour, minute, timezone, err)
+	}
+
+	quizName, _, _, timezone, err = parseDailyArgs("demo 7:05")
+	if err != nil || quizName != "demo" || timezone != "" {
+		t.Errorf("Expected demo without a time zone but got: %q %q %v", quizName, timezone, err)
+	}
+
+	for _, args := range []string{"demo", "08:30", "demo 25:00", "demo 08:30 Not/AZone"} {
+		if _, _, _, _, err := parseDailyArgs(args); err == nil {
+			t.Errorf("Expected an error for %q", args)
+		}
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	location, err := loadTimezone("UTC+8")
+	if err != nil {
+		t.Fatalf("Expected UTC+8 to load but got: %s", err)
+	}
+	if _, offset := time.Date(2024, 1, 1, 0, 0, 0, 0, location).Zone(); offset != 8*3600 {
+		t.Errorf("Expected an offset of 8 hours but got: %d", offset)
+	}
+
+	location, err = loadTimezone("UTC-03:30")
+	if err != nil {
+		t.Fatalf("Expected UTC-03:30 to load but got: %s", err)
+	}
+	if _, offset := time.Date(2024, 1, 1, 0, 0, 0, 0, location).Zone(); offset != -(3*3600 + 30*60) {
+		t.Errorf("Expected an offset of -3.5 hours but got: %d", offset)
+	}
+}
+
+func TestNextDailySend(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 3, 30, 9, 0, 0, 0, london)
+	if next := nextDailySend(now, 8, 30, london); !next.Equal(time.Date(2024, 3, 31, 8, 30, 0, 0, london)) {
+		t.Errorf("Expected tomorrow at 08:30 across the change to summer time but got: %s", next)
+	}
+	if next := nextDailySend(now, 9, 0, london); !next.After(now) {
+		t.Errorf("Expected a time after now but got: %s", next)
+	}
+	if next := nextDailySend(now, 21, 0, london); !next.Equal(time.Date(2024, 3, 30, 21, 0, 0, 0, london)) {
+		t.Errorf("Expected today at 21:00 but got: %s", next)
+	}
+}
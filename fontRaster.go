@@ -0,0 +1,155 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// fontFace draws the glyphs of a TrueType font for formulas
+type fontFace struct {
+	font       *sfnt.Font
+	unitsPerEm float64
+}
+
+// fpoint is a point in pixels, y pointing down
+type fpoint struct {
+	x, y float64
+}
+
+// glyphMetrics are in font units, y pointing up
+type glyphMetrics struct {
+	advance    float64
+	xMin, xMax float64
+	yMin, yMax float64
+}
+
+func parseFont(data []byte) (*fontFace, error) {
+	parsed, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &fontFace{font: parsed, unitsPerEm: float64(parsed.UnitsPerEm())}, nil
+}
+
+func fromFixed(value fixed.Int26_6) float64 {
+	return float64(value) / 64
+}
+
+// glyphIndex returns the font's glyph for the character, 0 if it has none
+func (face *fontFace) glyphIndex(r rune) sfnt.GlyphIndex {
+	// buffers are not shared, so that formulas can be drawn side by side
+	var buf sfnt.Buffer
+	glyph, err := face.font.GlyphIndex(&buf, r)
+	if err != nil {
+		return 0
+	}
+	return glyph
+}
+
+func (face *fontFace) metrics(glyph sfnt.GlyphIndex) glyphMetrics {
+	var buf sfnt.Buffer
+	// at one pixel per font unit, the bounds are in font units
+	bounds, advance, err := face.font.GlyphBounds(&buf, glyph, fixed.I(int(face.unitsPerEm)), font.HintingNone)
+	if err != nil {
+		return glyphMetrics{}
+	}
+
+	return glyphMetrics{
+		advance: fromFixed(advance),
+		xMin:    fromFixed(bounds.Min.X),
+		xMax:    fromFixed(bounds.Max.X),
+		yMin:    -fromFixed(bounds.Max.Y),
+		yMax:    -fromFixed(bounds.Min.Y),
+	}
+}
+
+// drawGlyph draws the glyph of the size at x and the baseline
+func (face *fontFace) drawGlyph(mask *image.Alpha, glyph sfnt.GlyphIndex, size float64, x float64, baseline float64) {
+	var buf sfnt.Buffer
+	segments, err := face.font.LoadGlyph(&buf, glyph, fixed.Int26_6(math.Round(size*64)), nil)
+	if err != nil || len(segments) == 0 {
+		return
+	}
+
+	bounds := segments.Bounds()
+	area := image.Rect(
+		int(math.Floor(x+fromFixed(bounds.Min.X))), int(math.Floor(baseline+fromFixed(bounds.Min.Y))),
+		int(math.Ceil(x+fromFixed(bounds.Max.X))), int(math.Ceil(baseline+fromFixed(bounds.Max.Y))),
+	)
+	fillArea(mask, area, func(raster *vector.Rasterizer, origin fpoint) {
+		point := func(p fixed.Point26_6) (float32, float32) {
+			return float32(x + fromFixed(p.X) - origin.x), float32(baseline + fromFixed(p.Y) - origin.y)
+		}
+		for i, segment := range segments {
+			switch segment.Op {
+			case sfnt.SegmentOpMoveTo:
+				// each contour is closed before the next one starts
+				if i > 0 {
+					raster.ClosePath()
+				}
+				raster.MoveTo(point(segment.Args[0]))
+			case sfnt.SegmentOpLineTo:
+				raster.LineTo(point(segment.Args[0]))
+			case sfnt.SegmentOpQuadTo:
+				controlX, controlY := point(segment.Args[0])
+				toX, toY := point(segment.Args[1])
+				raster.QuadTo(controlX, controlY, toX, toY)
+			case sfnt.SegmentOpCubeTo:
+				control1X, control1Y := point(segment.Args[0])
+				control2X, control2Y := point(segment.Args[1])
+				toX, toY := point(segment.Args[2])
+				raster.CubeTo(control1X, control1Y, control2X, control2Y, toX, toY)
+			}
+		}
+		raster.ClosePath()
+	})
+}
+
+// fillPolygons fills the polygons into the alpha mask with the nonzero winding rule, antialiased
+func fillPolygons(mask *image.Alpha, polygons [][]fpoint) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, point := range polygon {
+			minX, minY = math.Min(minX, point.x), math.Min(minY, point.y)
+			maxX, maxY = math.Max(maxX, point.x), math.Max(maxY, point.y)
+		}
+	}
+	if minX > maxX {
+		return
+	}
+
+	area := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+	fillArea(mask, area, func(raster *vector.Rasterizer, origin fpoint) {
+		for _, polygon := range polygons {
+			if len(polygon) < 3 {
+				continue
+			}
+			raster.MoveTo(float32(polygon[0].x-origin.x), float32(polygon[0].y-origin.y))
+			for _, point := range polygon[1:] {
+				raster.LineTo(float32(point.x-origin.x), float32(point.y-origin.y))
+			}
+			raster.ClosePath()
+		}
+	})
+}
+
+// fillArea rasterizes the paths drawn by fill into the part of the alpha mask in the area, adding to what
+// is already drawn. Only the area is rasterized, with the origin of the paths at its top left
+func fillArea(mask *image.Alpha, area image.Rectangle, fill func(raster *vector.Rasterizer, origin fpoint)) {
+	area = area.Intersect(mask.Bounds())
+	if area.Empty() {
+		return
+	}
+
+	raster := vector.NewRasterizer(area.Dx(), area.Dy())
+	raster.DrawOp = draw.Over
+	fill(raster, fpoint{x: float64(area.Min.X), y: float64(area.Min.Y)})
+	raster.Draw(mask, area, image.Opaque, image.Point{})
+}
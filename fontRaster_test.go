@@ -0,0 +1,32 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestGlyphIndex(t *testing.T) {
+	for _, r := range "Aa1+=αΣ∫√" {
+		if formulaFont.glyphIndex(r) == 0 {
+			t.Errorf("Expected a glyph for %q", r)
+		}
+	}
+	if formulaFont.glyphIndex('\U0001F600') != 0 {
+		t.Errorf("Expected no glyph outside the basic multilingual plane")
+	}
+}
+
+func TestFillPolygons(t *testing.T) {
+	mask := image.NewAlpha(image.Rect(0, 0, 10, 10))
+	fillPolygons(mask, [][]fpoint{rectPolygon(2, 2, 6, 4.5)})
+
+	if mask.AlphaAt(3, 3).A != 255 {
+		t.Errorf("Expected a pixel inside the rectangle to be filled but got: %d", mask.AlphaAt(3, 3).A)
+	}
+	if mask.AlphaAt(3, 4).A < 100 || mask.AlphaAt(3, 4).A > 155 {
+		t.Errorf("Expected a half covered pixel to be half filled but got: %d", mask.AlphaAt(3, 4).A)
+	}
+	if mask.AlphaAt(8, 8).A != 0 {
+		t.Errorf("Expected a pixel outside the rectangle to be empty")
+	}
+}
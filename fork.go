@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errNotAFork = errors.New("quiz was not copied from another quiz")
+
+// errSourceUnreadable is returned when the owner of a copied quiz's source no longer lets the user read it
+var errSourceUnreadable = status.Error(codes.NotFound, "source quiz not found")
+
+// syncChange is a difference between a copied quiz's source and the source as it was when last synced
+type syncChange struct {
+	kind      string // "new", "changed" or "removed"
+	question  string
+	answer    string // answer in the source, empty if removed
+	oldAnswer string // answer when last synced, empty if new
+}
+
+// quizFork is where a copied quiz came from, and the source's questions when the copy was last synced
+type quizFork struct {
+	ownerID   string
+	quizName  string
+	questions map[string]string
+}
+
+func quizForkSource(quizData map[string]interface{}) (quizFork, bool) {
+	copiedFrom, ok := quizData["copiedFrom"].(map[string]interface{})
+	if !ok {
+		return quizFork{}, false
+	}
+
+	fork := quizFork{questions: make(map[string]string)}
+	fork.ownerID, _ = copiedFrom["ownerID"].(string)
+	fork.quizName, _ = copiedFrom["quizName"].(string)
+	if questions, ok := copiedFrom["questions"].(map[string]interface{}); ok {
+		for question, answer := range questions {
+			if answerStr, ok := answer.(string); ok {
+				fork.questions[question] = answerStr
+			}
+		}
+	}
+	return fork, fork.ownerID != "" && fork.quizName != ""
+}
+
+// copies of a quiz are listed under it, so that they can be told about its updates
+func quizForksCollection(client *firestore.Client, ownerID string, quizName string) *firestore.CollectionRef {
+	return client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName).Collection("FORKS")
+}
+
+// copyQuiz copies a quiz the user may try into the user's quizzes as a private quiz, remembering where it was copied from
+func copyQuiz(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, ownerID string, quizName string, userID string, chatID int64) error {
+	sourceRef := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName)
+	copyRef := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName)
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		sourceDoc, err := tx.Get(sourceRef)
+		if err != nil {
+			return err
+		}
+		if !canReadQuiz(bot, sourceDoc.Data(), ownerID, userID, chatID) {
+			return status.Error(codes.NotFound, "quiz not found")
+		}
+
+		copyDoc, err := tx.Get(copyRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if copyDoc.Exists() {
+			return errQuizExists
+		}
+
+		questions := quizQuestions(sourceDoc.Data())
+		quizData := map[string]interface{}{
+			"numQns":     len(questions),
+			"score":      "none",
+			"visibility": visibilityPrivate,
+			"copiedFrom": map[string]interface{}{
+				"ownerID":   ownerID,
+				"quizName":  quizName,
+				"questions": questions,
+			},
+		}
+		if tags, ok := sourceDoc.Data()["tags"]; ok {
+			quizData["tags"] = tags
+		}
+		// telegram file IDs work for every user of the bot, so media is copied as it is
+		if media, ok := sourceDoc.Data()["media"]; ok {
+			quizData["media"] = media
+		}
+		if formatting, ok := sourceDoc.Data()["formatting"]; ok {
+			quizData["formatting"] = formatting
+		}
+		for question, answer := range questions {
+			quizData[question] = answer
+		}
+
+		if err := tx.Create(copyRef, quizData); err != nil {
+			return err
+		}
+		return tx.Set(quizForksCollection(client, ownerID, quizName).Doc(userID), map[string]interface{}{
+			"quizName": quizName,
+			"notified": false,
+		})
+	})
+}
+
+// diffSource lists what changed in the source since the copy was last synced
+func diffSource(synced map[string]string, source map[string]string) []syncChange {
+	var changes []syncChange
+
+	for question, answer := range source {
+		if oldAnswer, ok := synced[question]; !ok {
+			changes = append(changes, syncChange{kind: "new", question: question, answer: answer})
+		} else if oldAnswer != answer {
+			changes = append(changes, syncChange{kind: "changed", question: question, answer: answer, oldAnswer: oldAnswer})
+		}
+	}
+	for question, oldAnswer := range synced {
+		if _, ok := source[question]; !ok {
+			changes = append(changes, syncChange{kind: "removed", question: question, oldAnswer: oldAnswer})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].question < changes[j].question
+	})
+	return changes
+}
+
+// sourceQuestions returns the questions of the source of a copied quiz, which the user must still be allowed to read.
+// A source that was made private or is no longer shared with the user is not found, as for copyQuiz
+func sourceQuestions(bot *tgbotapi.BotAPI, sourceData map[string]interface{}, fork quizFork, userID string, chatID int64) (map[string]string, error) {
+	if !canReadQuiz(bot, sourceData, fork.ownerID, userID, chatID) {
+		return nil, errSourceUnreadable
+	}
+	return quizQuestions(sourceData), nil
+}
+
+// loadSourceChanges returns the changes to the source of the user's copied quiz since it was last synced
+func loadSourceChanges(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, userID string, chatID int64, quizName string) (quizFork, []syncChange, error) {
+	doc, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+	if err != nil {
+		return quizFork{}, nil, err
+	}
+
+	fork, ok := quizForkSource(doc.Data())
+	if !ok {
+		return quizFork{}, nil, errNotAFork
+	}
+
+	sourceDoc, err := client.Collection("USERS").Doc(fork.ownerID).Collection("QUIZZES").Doc(fork.quizName).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		// a deleted source removed all of its questions
+		return fork, diffSource(fork.questions, map[string]string{}), nil
+	}
+	if err != nil {
+		return quizFork{}, nil, err
+	}
+
+	source, err := sourceQuestions(bot, sourceDoc.Data(), fork, userID, chatID)
+	if err != nil {
+		return quizFork{}, nil, err
+	}
+	return fork, diffSource(fork.questions, source), nil
+}
+
+// pullSourceChanges applies the selected changes to the user's copy and marks them as synced
+func pullSourceChanges(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, userID string, chatID int64, quizName string, fork quizFork, changes []syncChange) error {
+	quizRef := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName)
+	sourceRef := client.Collection("USERS").Doc(fork.ownerID).Collection("QUIZZES").Doc(fork.quizName)
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(quizRef)
+		if err != nil {
+			return err
+		}
+
+		// the source may have been made private since the changes were listed. A deleted one only removes questions
+		sourceDoc, err := tx.Get(sourceRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if sourceDoc.Exists() {
+			if _, err := sourceQuestions(bot, sourceDoc.Data(), fork, userID, chatID); err != nil {
+				return err
+			}
+		}
+
+		questions := quizQuestions(doc.Data())
+		var updates []firestore.Update
+		for _, change := range changes {
+			syncedPath := []string{"copiedFrom", "questions", change.question}
+
+			if change.kind == "removed" {
+				if _, ok := questions[change.question]; ok {
+					delete(questions, change.question)
+					updates = append(updates, firestore.Update{FieldPath: []string{change.question}, Value: firestore.Delete})
+				}
+				updates = append(updates, firestore.Update{FieldPath: syncedPath, Value: firestore.Delete})
+				continue
+			}
+
+			if quizInfoFields[change.question] {
+				continue
+			}
+			questions[change.question] = change.answer
+			updates = append(updates,
+				firestore.Update{FieldPath: []string{change.question}, Value: change.answer},
+				firestore.Update{FieldPath: syncedPath, Value: change.answer},
+			)
+		}
+
+		updates = append(updates,
+			firestore.Update{Path: "numQns", Value: len(questions)},
+			firestore.Update{Path: "score", Value: "none"},
+		)
+		return tx.Update(quizRef, updates)
+	})
+	if err != nil {
+		return err
+	}
+	updateCatalogEntry(ctx, client, userID, quizName)
+
+	// notify the user again on the source's next update
+	_, err = quizForksCollection(client, fork.ownerID, fork.quizName).Doc(userID).Set(ctx, map[string]interface{}{
+		"quizName": quizName,
+		"notified": false,
+	})
+	if err != nil {
+		log.Printf("An error has occurred trying to reset fork notification: %s", err)
+	}
+	return nil
+}
+
+// notifyForks tells users who copied the quiz that it has changed, once until they next sync
+func notifyForks(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, ownerID string, quizName string) {
+	docs, err := quizForksCollection(client, ownerID, quizName).Where("notified", "==", false).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load quiz copies: %s", err)
+		return
+	}
+
+	for _, doc := range docs {
+		forkerID := doc.Ref.ID
+		forkName, _ := doc.Data()["quizName"].(string)
+
+		// copies that have been deleted no longer need updates
+		if _, err := client.Collection("USERS").Doc(forkerID).Collection("QUIZZES").Doc(forkName).Get(ctx); status.Code(err) == codes.NotFound {
+			if _, err := doc.Ref.Delete(ctx); err != nil {
+				log.Printf("An error has occurred trying to remove quiz copy: %s", err)
+			}
+			continue
+		}
+
+		notifyUser(ctx, client, forkerID, bot, "Quiz %s, which you copied as %s, has been updated. Use /sync %s to see the changes.",
+			quizName, forkName, forkName)
+
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "notified", Value: true}}); err != nil {
+			log.Printf("An error has occurred trying to update quiz copy: %s", err)
+		}
+	}
+}
+
+// sourceChangesList lists the changes to the source of a copied quiz by number, with buttons to pull them all or cancel
+func sourceChangesList(chatID int64, quizName string, changes []syncChange) *pagedList {
+	var lines []string
+	for i, change := range changes {
+		switch change.kind {
+		case "new":
+			lines = append(lines, tr(chatID, "%d. <strong>New:</strong> %s → %s", i+1, escapeHTML(change.question), escapeHTML(change.answer)))
+		case "changed":
+			lines = append(lines, tr(chatID, "%d. <strong>Changed:</strong> %s → %s (was %s)", i+1, escapeHTML(change.question), escapeHTML(change.answer), escapeHTML(change.oldAnswer)))
+		case "removed":
+			lines = append(lines, tr(chatID, "%d. <strong>Removed:</strong> %s", i+1, escapeHTML(change.question)))
+		}
+	}
+
+	list := newPagedList(chatID, tr(chatID, "Changes to the source of quiz <strong>%s</strong>:\n", escapeHTML(quizName)), lines,
+		tr(chatID, "\nInput the numbers of the changes to pull, e.g. <strong>1, 3-4</strong>, or press <strong>Yes</strong> to pull them all."))
+	list.buttons = createTwoBtnRowKeyboard(chatID, btnYes, btnCancel).InlineKeyboard
+	return list
+}
+
+// parseNumberSelection parses numbers and ranges such as "1, 3-4" between 1 and max
+func parseNumberSelection(input string, max int) ([]int, error) {
+	var numbers []int
+	selected := make(map[int]bool)
+
+	parts := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, part := range parts {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		if first < 1 || last > max || first > last {
+			return nil, fmt.Errorf("%s is not between 1 and %d", part, max)
+		}
+
+		for n := first; n <= last; n++ {
+			if !selected[n] {
+				selected[n] = true
+				numbers = append(numbers, n)
+			}
+		}
+	}
+
+	if len(numbers) == 0 {
+		return nil, errors.New("no numbers selected")
+	}
+	return numbers, nil
+}
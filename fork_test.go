@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDiffSource(t *testing.T) {
+	synced := map[string]string{"a?": "1", "b?": "2", "c?": "3"}
+	source := map[string]string{"a?": "1", "b?": "two", "d?": "4"}
+
+	changes := diffSource(synced, source)
+	expected := []syncChange{
+		{kind: "changed", question: "b?", answer: "two", oldAnswer: "2"},
+		{kind: "removed", question: "c?", oldAnswer: "3"},
+		{kind: "new", question: "d?", answer: "4"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %v but got: %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Expected %v but got: %v", expected[i], changes[i])
+		}
+	}
+}
+
+func TestParseNumberSelection(t *testing.T) {
+	numbers, err := parseNumberSelection("1, 3-4 3", 5)
+	if err != nil || len(numbers) != 3 || numbers[0] != 1 || numbers[1] != 3 || numbers[2] != 4 {
+		t.Errorf("Expected [1 3 4] but got: %v %v", numbers, err)
+	}
+
+	for _, input := range []string{"", "0", "6", "4-2", "one"} {
+		if _, err := parseNumberSelection(input, 5); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+func TestSourceQuestions(t *testing.T) {
+	fork := quizFork{ownerID: "111", quizName: "capitals"}
+	source := map[string]interface{}{"visibility": visibilityPublic, "numQns": int64(1), "France?": "Paris"}
+
+	questions, err := sourceQuestions(nil, source, fork, "222", 222)
+	if err != nil || len(questions) != 1 || questions["France?"] != "Paris" {
+		t.Errorf("Expected the questions of a public source but got: %v %v", questions, err)
+	}
+
+	// the owner made the source private after it was copied
+	source["visibility"] = visibilityPrivate
+	if _, err := sourceQuestions(nil, source, fork, "222", 222); !errors.Is(err, errSourceUnreadable) || status.Code(err) != codes.NotFound {
+		t.Errorf("Expected a private source not to be found but got: %v", err)
+	}
+}
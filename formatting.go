@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// escapeHTML makes user content safe to put in messages sent with the HTML parse mode
+func escapeHTML(text string) string {
+	return html.EscapeString(text)
+}
+
+// questionFormatting is a question and its answer as HTML, kept with the formatting the user typed them with.
+// Either is empty when it was typed without formatting
+type questionFormatting struct {
+	question string
+	answer   string
+}
+
+func (formatting questionFormatting) data() map[string]interface{} {
+	data := make(map[string]interface{})
+	if formatting.question != "" {
+		data["question"] = formatting.question
+	}
+	if formatting.answer != "" {
+		data["answer"] = formatting.answer
+	}
+	return data
+}
+
+func newQuestionFormatting(data interface{}) questionFormatting {
+	var formatting questionFormatting
+	if fields, ok := data.(map[string]interface{}); ok {
+		formatting.question, _ = fields["question"].(string)
+		formatting.answer, _ = fields["answer"].(string)
+	}
+	return formatting
+}
+
+func (formatting questionFormatting) empty() bool {
+	return formatting.question == "" && formatting.answer == ""
+}
+
+// quizFormatting returns the formatting of a quiz's questions, kept in its "formatting" field by question
+func quizFormatting(quizData map[string]interface{}) map[string]questionFormatting {
+	formatting := make(map[string]questionFormatting)
+	formattingData, _ := quizData["formatting"].(map[string]interface{})
+	for question, data := range formattingData {
+		formatting[question] = newQuestionFormatting(data)
+	}
+	return formatting
+}
+
+// formattedText returns the HTML to show for a question or answer, escaped unless the user's formatting was kept
+func formattedText(text string, formattedHTML string) string {
+	if formattedHTML != "" {
+		return formattedHTML
+	}
+	return escapeHTML(text)
+}
+
+// entityTags are the HTML tags of the formatting entities that are kept
+var entityTags = map[string]string{
+	"bold":          "b",
+	"italic":        "i",
+	"underline":     "u",
+	"strikethrough": "s",
+	"spoiler":       "tg-spoiler",
+	"code":          "code",
+	"pre":           "pre",
+}
+
+func entityOpenTag(entity tgbotapi.MessageEntity) string {
+	if entity.Type == "text_link" {
+		return `<a href="` + escapeHTML(entity.URL) + `">`
+	}
+	return "<" + entityTags[entity.Type] + ">"
+}
+
+func entityCloseTag(entity tgbotapi.MessageEntity) string {
+	if entity.Type == "text_link" {
+		return "</a>"
+	}
+	return "</" + entityTags[entity.Type] + ">"
+}
+
+// entitiesToHTML returns the text as HTML with its formatting entities, or "" if it has none that are kept.
+// Entity offsets count UTF-16 code units, and telegram only nests entities fully inside each other
+func entitiesToHTML(text string, entities []tgbotapi.MessageEntity) string {
+	var kept []tgbotapi.MessageEntity
+	for _, entity := range entities {
+		if _, ok := entityTags[entity.Type]; ok || entity.Type == "text_link" {
+			kept = append(kept, entity)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	// outer entities open first
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].Offset != kept[j].Offset {
+			return kept[i].Offset < kept[j].Offset
+		}
+		return kept[i].Length > kept[j].Length
+	})
+
+	units := utf16.Encode([]rune(text))
+	end := func(entity tgbotapi.MessageEntity) int {
+		if entity.Offset+entity.Length > len(units) {
+			return len(units)
+		}
+		return entity.Offset + entity.Length
+	}
+
+	var builder strings.Builder
+	var open []tgbotapi.MessageEntity
+	next := 0
+	for pos := 0; ; {
+		for len(open) > 0 && end(open[len(open)-1]) <= pos {
+			builder.WriteString(entityCloseTag(open[len(open)-1]))
+			open = open[:len(open)-1]
+		}
+		for next < len(kept) && kept[next].Offset <= pos {
+			builder.WriteString(entityOpenTag(kept[next]))
+			open = append(open, kept[next])
+			next++
+		}
+		if pos >= len(units) {
+			break
+		}
+
+		// write the text up to where the next entity opens or closes
+		stop := len(units)
+		if next < len(kept) && kept[next].Offset < stop {
+			stop = kept[next].Offset
+		}
+		for _, entity := range open {
+			if end(entity) < stop {
+				stop = end(entity)
+			}
+		}
+		if stop <= pos {
+			stop = pos + 1
+		}
+		builder.WriteString(escapeHTML(string(utf16.Decode(units[pos:stop]))))
+		pos = stop
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		builder.WriteString(entityCloseTag(open[i]))
+	}
+
+	return builder.String()
+}
+
+// messageFormatting returns the message's text or caption as HTML with its formatting, or "" if it has none
+func messageFormatting(message *tgbotapi.Message) string {
+	if message.Caption != "" {
+		return entitiesToHTML(message.Caption, message.CaptionEntities)
+	}
+	return entitiesToHTML(message.Text, message.Entities)
+}
+
+// keepsFormatting reports whether the user chose to keep the formatting of the questions they type
+func keepsFormatting(ctx context.Context, client *firestore.Client, userID string) bool {
+	doc, err := client.Collection("USERS").Doc(userID).Get(ctx)
+	if err != nil {
+		return false
+	}
+	keep, _ := doc.Data()["keepFormatting"].(bool)
+	return keep
+}
+
+func setKeepFormatting(ctx context.Context, client *firestore.Client, userID string, keep bool) error {
+	_, err := client.Collection("USERS").Doc(userID).Set(ctx, map[string]interface{}{
+		"keepFormatting": keep,
+	}, firestore.MergeAll)
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestEntitiesToHTML(t *testing.T) {
+	if outputStr := entitiesToHTML("a < b", nil); outputStr != "" {
+		t.Errorf("Expected no HTML without entities but got: %q", outputStr)
+	}
+
+	mentionOnly := []tgbotapi.MessageEntity{{Type: "mention", Offset: 0, Length: 4}}
+	if outputStr := entitiesToHTML("@bob & co", mentionOnly); outputStr != "" {
+		t.Errorf("Expected no HTML without kept entities but got: %q", outputStr)
+	}
+
+	nested := []tgbotapi.MessageEntity{
+		{Type: "italic", Offset: 5, Length: 3},
+		{Type: "bold", Offset: 0, Length: 8},
+		{Type: "spoiler", Offset: 9, Length: 5},
+	}
+	outputStr := entitiesToHTML("x<y: 1&2 paris", nested)
+	if outputStr != "<b>x&lt;y: <i>1&amp;2</i></b> <tg-spoiler>paris</tg-spoiler>" {
+		t.Errorf("Expected nested, escaped formatting but got: %q", outputStr)
+	}
+
+	// the emoji takes two UTF-16 code units
+	emoji := []tgbotapi.MessageEntity{{Type: "code", Offset: 3, Length: 2}}
+	if outputStr := entitiesToHTML("🐍 go", emoji); outputStr != "🐍 <code>go</code>" {
+		t.Errorf("Expected offsets in UTF-16 code units but got: %q", outputStr)
+	}
+
+	link := []tgbotapi.MessageEntity{{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com/?a=1&b=2"}}
+	if outputStr := entitiesToHTML("docs", link); outputStr != `<a href="https://example.com/?a=1&amp;b=2">docs</a>` {
+		t.Errorf("Expected an escaped link but got: %q", outputStr)
+	}
+}
+
+func TestFormattedText(t *testing.T) {
+	if outputStr := formattedText("<b>not bold</b>", ""); outputStr != "&lt;b&gt;not bold&lt;/b&gt;" {
+		t.Errorf("Expected escaped text but got: %q", outputStr)
+	}
+	if outputStr := formattedText("bold", "<b>bold</b>"); outputStr != "<b>bold</b>" {
+		t.Errorf("Expected the kept formatting but got: %q", outputStr)
+	}
+
+	quizData := map[string]interface{}{
+		"formatting": map[string]interface{}{
+			"capital?": questionFormatting{answer: "<i>Paris</i>"}.data(),
+		},
+	}
+	formatting := quizFormatting(quizData)["capital?"]
+	if formatting.question != "" || formatting.answer != "<i>Paris</i>" {
+		t.Errorf("Expected only the answer's formatting but got: %+v", formatting)
+	}
+}
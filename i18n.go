@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultLanguage is used for chats whose language is not known, and for texts missing in a language
+const defaultLanguage = "en"
+
+// languageNames are the languages the bot speaks, by telegram language code
+var languageNames = map[string]string{
+	"en": "English",
+	"es": "Español",
+}
+
+// translations hold the texts of the bot in languages other than English, by their English text.
+// Texts without a translation are sent in English
+var translations = map[string]map[string]string{
+	"es": spanishTexts,
+}
+
+// pluralTranslations hold texts that depend on a number, by their English plural text, with a text for each
+// plural form of the language
+var pluralTranslations = map[string]map[string][]string{
+	"es": spanishPlurals,
+}
+
+// pluralIndex returns which plural form of the language is used for the number
+func pluralIndex(lang string, n int) int {
+	switch lang {
+	default:
+		// English and Spanish only tell one apart from other numbers
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}
+}
+
+// translate returns the text in the language, formatted with the args like fmt.Sprintf
+func translate(lang string, text string, args ...interface{}) string {
+	if translated, found := translations[lang][text]; found {
+		text = translated
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// translatePlural returns the singular or plural text for n in the language, formatted with the args
+func translatePlural(lang string, n int, singular string, plural string, args ...interface{}) string {
+	forms, found := pluralTranslations[lang][plural]
+	if !found {
+		lang, forms = defaultLanguage, []string{singular, plural}
+	}
+	text := forms[len(forms)-1]
+	if index := pluralIndex(lang, n); index < len(forms) {
+		text = forms[index]
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// tr returns the text in the language of the chat it is sent to
+func tr(chatID int64, text string, args ...interface{}) string {
+	return translate(chatLanguage(chatID), text, args...)
+}
+
+// trn returns the singular or plural text for n in the language of the chat it is sent to
+func trn(chatID int64, n int, singular string, plural string, args ...interface{}) string {
+	return translatePlural(chatLanguage(chatID), n, singular, plural, args...)
+}
+
+// chatLanguages caches the language of each chat, so that texts are looked up by the chat they are sent to.
+// A private chat has its user's language, and a group the language set for it. Until one is chosen, the chat
+// has the language of the telegram app of the first user seen in it
+var chatLanguages = struct {
+	sync.Mutex
+	chosen  map[int64]string // empty once it is known that no language was chosen
+	guessed map[int64]string
+}{chosen: make(map[int64]string), guessed: make(map[int64]string)}
+
+func chatLanguage(chatID int64) string {
+	chatLanguages.Lock()
+	defer chatLanguages.Unlock()
+
+	if lang := chatLanguages.chosen[chatID]; lang != "" {
+		return lang
+	}
+	if lang, found := chatLanguages.guessed[chatID]; found {
+		return lang
+	}
+	return defaultLanguage
+}
+
+// supportedLanguage returns the language the bot speaks for a telegram language code such as "es-ES",
+// and whether it speaks it
+func supportedLanguage(code string) (string, bool) {
+	lang := strings.ToLower(strings.SplitN(strings.ReplaceAll(code, "_", "-"), "-", 2)[0])
+	if _, found := languageNames[lang]; found {
+		return lang, true
+	}
+	return defaultLanguage, false
+}
+
+// languageList lists the languages the bot speaks, e.g. "en (English), es (Español)"
+func languageList() string {
+	var codes []string
+	for code := range languageNames {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var list []string
+	for _, code := range codes {
+		list = append(list, code+" ("+languageNames[code]+")")
+	}
+	return strings.Join(list, ", ")
+}
+
+// languageDoc is where the language of a chat is kept: on the user for private chats, or else on the group
+func languageDoc(client *firestore.Client, chatID int64) *firestore.DocumentRef {
+	if chatID > 0 {
+		return client.Collection("USERS").Doc(fmt.Sprint(chatID))
+	}
+	return groupDoc(client, chatID)
+}
+
+// loadChatLanguage caches the language chosen for the chat, and the language of the user's telegram app in case
+// none was. Messages sent without a user, such as reminders, give no language code
+func loadChatLanguage(ctx context.Context, client *firestore.Client, chatID int64, userLanguageCode string) {
+	chatLanguages.Lock()
+	if _, found := chatLanguages.guessed[chatID]; !found && userLanguageCode != "" {
+		chatLanguages.guessed[chatID], _ = supportedLanguage(userLanguageCode)
+	}
+	_, loaded := chatLanguages.chosen[chatID]
+	chatLanguages.Unlock()
+	if loaded {
+		return
+	}
+
+	chosen := ""
+	doc, err := languageDoc(client, chatID).Get(ctx)
+	if err == nil {
+		chosen, _ = doc.Data()["language"].(string)
+	} else if status.Code(err) != codes.NotFound {
+		// the language is looked up again with the next message
+		log.Printf("An error has occurred trying to load the language: %s", err)
+		return
+	}
+
+	chatLanguages.Lock()
+	chatLanguages.chosen[chatID] = chosen
+	chatLanguages.Unlock()
+}
+
+// setChatLanguage saves the language chosen for the chat. Users choose theirs once they have started the bot
+func setChatLanguage(ctx context.Context, client *firestore.Client, chatID int64, lang string) error {
+	var err error
+	if chatID > 0 {
+		_, err = languageDoc(client, chatID).Update(ctx, []firestore.Update{{Path: "language", Value: lang}})
+	} else {
+		_, err = languageDoc(client, chatID).Set(ctx, map[string]interface{}{
+			"language": lang,
+		}, firestore.MergeAll)
+	}
+	if err != nil {
+		return err
+	}
+
+	chatLanguages.Lock()
+	chatLanguages.chosen[chatID] = lang
+	chatLanguages.Unlock()
+	return nil
+}
@@ -0,0 +1,427 @@
+package main
+
+// spanishTexts are the Spanish texts of the bot, by their English text
+var spanishTexts = map[string]string{
+	// buttons
+	"Yes":             "Sí",
+	"No":              "No",
+	"Exit":            "Salir",
+	"Cancel":          "Cancelar",
+	"Keep":            "Conservar",
+	"Toss":            "Descartar",
+	"Reveal Ans":      "Mostrar resp.",
+	"Correct":         "Correcta",
+	"Wrong":           "Incorrecta",
+	"End Quiz":        "Terminar quiz",
+	"My own quiz":     "Mi propio quiz",
+	"A friend's quiz": "Quiz de un amigo",
+	"Private":         "Privado",
+	"Shared":          "Compartido",
+	"Public":          "Público",
+	"Add editors":     "Añadir editores",
+	"Remove editors":  "Quitar editores",
+	"Keep current":    "Dejar como está",
+	"1 ★":             "1 ★",
+	"2 ★":             "2 ★",
+	"3 ★":             "3 ★",
+	"4 ★":             "4 ★",
+	"5 ★":             "5 ★",
+	"Skip":            "Omitir",
+	"Search":          "Buscar",
+	"Accept":          "Aceptar",
+	"Decline":         "Rechazar",
+	"« Prev":          "« Anterior",
+	"Next »":          "Siguiente »",
+	"None of these":   "Ninguna de estas",
+
+	// catalog
+	"All quizzes":                     "Todos los quizzes",
+	"No public quizzes found for %s.": "No se encontraron quizzes públicos para %s.",
+	"Public quizzes for %s:\n":        "Quizzes públicos para %s:\n",
+	"<strong>%d. %s</strong> by %s\n": "<strong>%d. %s</strong> de %s\n",
+	", rated %.1f/5 by %d":            ", valorado %.1f/5 por %d",
+	"Try %d":                          "Probar %d",
+	"Copy %d to my quizzes":           "Copiar %d a mis quizzes",
+	"all categories":                  "todas las categorías",
+	"Choose a category to browse public quizzes, or use /search keywords:": "Elige una categoría para explorar los quizzes públicos, o usa /search palabras clave:",
+
+	// challenges
+	"<strong>Challenge question %d/%d</strong>\n%s\n\n<i>Tap an answer or type it.</i>": "<strong>Pregunta del desafío %d/%d</strong>\n%s\n\n<i>Toca una respuesta o escríbela.</i>",
+	"❌ Wrong, the answer was %s":                       "❌ Incorrecto, la respuesta era %s",
+	"✅ Correct!":                                       "✅ ¡Correcto!",
+	"Challenge question %d/%d\n%s\n\n%s":               "Pregunta del desafío %d/%d\n%s\n\n%s",
+	"This question is closed":                          "Esta pregunta está cerrada",
+	"<strong>Challenge results</strong>\n":             "<strong>Resultados del desafío</strong>\n",
+	"🏆 %s wins!\n":                                     "🏆 ¡Gana %s!\n",
+	"It's a draw!\n":                                   "¡Es un empate!\n",
+	"%s: %d/%d correct in %.1fs\n":                     "%s: %d/%d correctas en %.1fs\n",
+	"Sorry, your challenge result could not be saved.": "Lo siento, no se pudo guardar tu resultado del desafío.",
+	"You got %d/%d correct in %.1fs! I will send you the results once the other player finishes.": "¡Acertaste %d/%d en %.1fs! Te enviaré los resultados cuando el otro jugador termine.",
+
+	// collaboration
+	"Sorry, the history of quiz %s could not be loaded. Please try again.": "Lo siento, no se pudo cargar el historial del quiz %s. Inténtalo de nuevo.",
+	"No changes have been recorded for quiz %s yet.":                       "Aún no se han registrado cambios en el quiz %s.",
+	"added":                        "añadida",
+	"changed":                      "cambiada",
+	"removed":                      "eliminada",
+	"Latest changes to quiz %s:\n": "Últimos cambios en el quiz %s:\n",
+	"QUESTIONS:\n":                 "PREGUNTAS:\n",
+
+	// command parsing
+	"a quote is not closed":                 "hay unas comillas sin cerrar",
+	"unknown option %s":                     "la opción %s no existe",
+	"%s must be a number from 1 up, not %q": "%s debe ser un número desde 1, no %q",
+	"%s must be one of %s, not %q":          "%s debe ser uno de %s, no %q",
+	"Sorry, %s.\nUsage: %s":                 "Lo siento, %s.\nUso: %s",
+	"Usage: %s":                             "Uso: %s",
+
+	// commands
+	"get list of commands":                  "ver la lista de comandos",
+	"add a new quiz":                        "añadir un quiz nuevo",
+	"add questions to a selected quiz":      "añadir preguntas a un quiz",
+	"edit questions of a selected quiz":     "editar preguntas de un quiz",
+	"remove questions from a selected quiz": "quitar preguntas de un quiz",
+	"try a selected quiz, optionally only some questions or with typed answers": "probar un quiz, opcionalmente solo algunas preguntas o escribiendo las respuestas",
+	"delete a selected quiz":                                         "borrar un quiz",
+	"list your quizzes, optionally filtered":                         "ver tus quizzes, opcionalmente filtrados",
+	"add tags to a selected quiz":                                    "añadir etiquetas a un quiz",
+	"remove tags from a selected quiz":                               "quitar etiquetas de un quiz",
+	"move a selected quiz into a folder":                             "mover un quiz a una carpeta",
+	"list your deleted quizzes and questions":                        "ver tus quizzes y preguntas borrados",
+	"restore an item from your trash":                                "restaurar algo de tu papelera",
+	"undo your last deletion":                                        "deshacer tu último borrado",
+	"get a link for friends to try a selected quiz":                  "obtener un enlace para que tus amigos prueben un quiz",
+	"stop sharing a selected quiz":                                   "dejar de compartir un quiz",
+	"choose who can try a selected quiz":                             "elegir quién puede probar un quiz",
+	"copy a friend's quiz to your quizzes":                           "copiar el quiz de un amigo a tus quizzes",
+	"pull changes from the source of a copied quiz":                  "traer los cambios del original de un quiz copiado",
+	"host a live quiz for everyone in a group chat":                  "presentar un quiz en vivo para todo un grupo",
+	"play a quiz as telegram quiz polls, alone or in a group":        "jugar un quiz como encuestas de telegram, solo o en grupo",
+	"end the live quiz you are hosting":                              "terminar el quiz en vivo que presentas",
+	"show the group's leaderboard":                                   "ver la clasificación del grupo",
+	"post the group's leaderboard every week":                        "publicar la clasificación del grupo cada semana",
+	"challenge a friend to one of your quizzes":                      "desafiar a un amigo con uno de tus quizzes",
+	"get a question from a quiz every day":                           "recibir una pregunta de un quiz cada día",
+	"stop the daily questions from a quiz":                           "dejar de recibir las preguntas diarias de un quiz",
+	"see your quizzes, study streak and reminders":                   "ver tus quizzes, tu racha de estudio y tus recordatorios",
+	"get an evening reminder when your streak is about to break":     "recibir un recordatorio por la tarde cuando tu racha esté por romperse",
+	"keep the bold, italics, code and spoilers of questions you add": "conservar la negrita, cursiva, código y spoilers de las preguntas que añadas",
+	"browse public quizzes by category":                              "explorar quizzes públicos por categoría",
+	"search public quizzes":                                          "buscar quizzes públicos",
+	"invite or remove co-editors of a selected quiz":                 "invitar o quitar coeditores de un quiz",
+	"see the latest changes to a selected quiz":                      "ver los últimos cambios de un quiz",
+	"choose the language I reply in":                                 "elegir el idioma en que respondo",
+	"get your telegram ID number":                                    "obtener tu número de ID de telegram",
+	"I understand the following commands: \n":                        "Entiendo los siguientes comandos: \n",
+
+	// daily questions
+	"You have no daily questions. Subscribe with /daily quiz_name HH:MM": "No tienes preguntas diarias. Suscríbete con /daily nombre_quiz HH:MM",
+	"Your daily questions:\n":                   "Tus preguntas diarias:\n",
+	"<strong>%s</strong> at %02d:%02d %s\n":     "<strong>%s</strong> a las %02d:%02d %s\n",
+	"\nStop one with /daily_off quiz_name":      "\nDetén una con /daily_off nombre_quiz",
+	"<strong>Daily question from %s</strong>\n": "<strong>Pregunta diaria de %s</strong>\n",
+	"This question is no longer active":         "Esta pregunta ya no está activa",
+	"<i>Marked %s</i>":                          "<i>Marcada como %s</i>",
+
+	// copied quizzes
+	"Quiz %s, which you copied as %s, has been updated. Use /sync %s to see the changes.":                                       "El quiz %s, que copiaste como %s, se ha actualizado. Usa /sync %s para ver los cambios.",
+	"%d. <strong>New:</strong> %s → %s":                                                                                         "%d. <strong>Nueva:</strong> %s → %s",
+	"%d. <strong>Changed:</strong> %s → %s (was %s)":                                                                            "%d. <strong>Cambiada:</strong> %s → %s (antes %s)",
+	"%d. <strong>Removed:</strong> %s":                                                                                          "%d. <strong>Eliminada:</strong> %s",
+	"Changes to the source of quiz <strong>%s</strong>:\n":                                                                      "Cambios en el original del quiz <strong>%s</strong>:\n",
+	"\nInput the numbers of the changes to pull, e.g. <strong>1, 3-4</strong>, or press <strong>Yes</strong> to pull them all.": "\nEscribe los números de los cambios que quieres traer, p. ej. <strong>1, 3-4</strong>, o pulsa <strong>Sí</strong> para traerlos todos.",
+
+	// leaderboards
+	"%d. <strong>%s</strong> - ": "%d. <strong>%s</strong> - ",
+	"Please choose <strong>weekly</strong>, <strong>monthly</strong> or <strong>all</strong>, e.g. <strong>/leaderboard monthly</strong>": "Elige <strong>weekly</strong> (semanal), <strong>monthly</strong> (mensual) o <strong>all</strong> (siempre), p. ej. <strong>/leaderboard monthly</strong>",
+	"Sorry, the leaderboard could not be loaded. Please try again.":                                                                       "Lo siento, no se pudo cargar la clasificación. Inténtalo de nuevo.",
+	"No quizzes have been played in this chat %s. Start one with /host_quiz quiz_name":                                                    "No se han jugado quizzes en este chat %s. Empieza uno con /host_quiz nombre_quiz",
+	"<strong>Leaderboard for %s</strong>\n":                                                                                               "<strong>Clasificación de %s</strong>\n",
+	"<strong>Weekly summary</strong>\n":                                                                                                   "<strong>Resumen semanal</strong>\n",
+	"this week":                                                                                                                           "esta semana",
+	"this month":                                                                                                                          "este mes",
+	"all time":                                                                                                                            "siempre",
+
+	// live quizzes
+	"<strong>Question %d/%d</strong>\n%s\n\n<i>Tap an answer or reply with it within %d seconds!</i>": "<strong>Pregunta %d/%d</strong>\n%s\n\n<i>¡Toca una respuesta o respóndela en menos de %d segundos!</i>",
+	"You have already answered this question":                                                         "Ya has respondido esta pregunta",
+	"Answer recorded!":                 "¡Respuesta registrada!",
+	"No one has answered yet.":         "Nadie ha respondido todavía.",
+	"Question %d/%d\n%s\n\nAnswer: %s": "Pregunta %d/%d\n%s\n\nRespuesta: %s",
+	"Answer: %s":                       "Respuesta: %s",
+	"<strong>Live quiz %s is over!</strong>\n\n<strong>Final scoreboard</strong>\n%s": "<strong>¡El quiz en vivo %s ha terminado!</strong>\n\n<strong>Marcador final</strong>\n%s",
+
+	// lists
+	"<i>Page %d/%d</i>\n": "<i>Página %d/%d</i>\n",
+	"Select a quiz:":      "Selecciona un quiz:",
+	"Selected quiz: %s":   "Quiz seleccionado: %s",
+	", not attempted yet": ", aún sin intentar",
+	", last attempt %s":   ", último intento %s",
+	", best %s":           ", mejor %s",
+
+	// quizzes
+	"Quiz titled %s found!\n":                                            "¡Quiz %s encontrado!\n",
+	"For each question:\n":                                               "Para cada pregunta:\n",
+	"Press <strong>%s</strong> to reveal the answer.\n":                  "Pulsa <strong>%s</strong> para ver la respuesta.\n",
+	"After that, press <strong>%s</strong> if you answered correctly,\n": "Después, pulsa <strong>%s</strong> si acertaste,\n",
+	"or press <strong>%s</strong> if you answered wrongly\n":             "o pulsa <strong>%s</strong> si fallaste\n",
+	"Or type your answer, and it is marked for you.\n":                   "O escribe tu respuesta y se corregirá por ti.\n",
+	"Your score will be computed at the end of the quiz.\n":              "Tu puntuación se calculará al final del quiz.\n",
+	"You may also <strong>%s</strong> at any time\n":                     "También puedes pulsar <strong>%s</strong> en cualquier momento\n",
+	"<strong>Q:</strong> %s\n":                                           "<strong>P:</strong> %s\n",
+	"<strong>A:</strong> %s\n":                                           "<strong>R:</strong> %s\n",
+	"QUESTIONS TO REMOVE:\n":                                             "PREGUNTAS A QUITAR:\n",
+	"Are you sure you want to remove all the above questions?":           "¿Seguro que quieres quitar todas las preguntas anteriores?",
+	"No questions selected for removal":                                  "No se seleccionaron preguntas para quitar",
+	"Please finish your current challenge first":                         "Termina primero tu desafío actual",
+	"This challenge is no longer open":                                   "Este desafío ya no está abierto",
+	"Sorry, please try again":                                            "Lo siento, inténtalo de nuevo",
+	"%s accepted your challenge on quiz %s!":                             "¡%s aceptó tu desafío con el quiz %s!",
+	"%s declined your challenge on quiz %s.":                             "%s rechazó tu desafío con el quiz %s.",
+	"Challenge declined.":                                                "Desafío rechazado.",
+	"Only the current user can use these buttons":                        "Solo el usuario actual puede usar estos botones",
+	"This list has expired, please run the command again":                "Esta lista ha caducado, vuelve a ejecutar el comando",
+	"Please finish what you are doing first":                             "Termina primero lo que estás haciendo",
+	"You already have a quiz named %s.":                                  "Ya tienes un quiz llamado %s.",
+	"Quiz %s is no longer public.":                                       "El quiz %s ya no es público.",
+	"Sorry, quiz %s could not be copied. Please try again.":              "Lo siento, no se pudo copiar el quiz %s. Inténtalo de nuevo.",
+	"Copied quiz %s to your quizzes. It is private until you change its /visibility, and /sync pulls in later changes to the original.": "Quiz %s copiado a tus quizzes. Es privado hasta que cambies su /visibility, y /sync trae los cambios posteriores del original.",
+	"This button is no longer active":                                                  "Este botón ya no está activo",
+	"There is no live quiz to stop.":                                                   "No hay ningún quiz en vivo que detener.",
+	"Only the host can stop the live quiz.":                                            "Solo el presentador puede detener el quiz en vivo.",
+	"A live quiz is already running in this chat. The host can end it with /stop_quiz": "Ya hay un quiz en vivo en este chat. El presentador puede terminarlo con /stop_quiz",
+	"Live quizzes are played in group chats. Add me to a group and use /host_quiz quiz_name there, or play alone with /poll_quiz quiz_name": "Los quizzes en vivo se juegan en grupos. Añádeme a un grupo y usa /host_quiz nombre_quiz allí, o juega solo con /poll_quiz nombre_quiz",
+	"Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`":                             "Incluye el nombre de un quiz con este comando.\nSe permiten espacios en el nombre.\np. ej. `/%s demo quiz`",
+	"Quiz with name %s not found.":                                        "No se encontró ningún quiz llamado %s.",
+	"This quiz has no questions to try!":                                  "¡Este quiz no tiene preguntas para probar!",
+	"Leaderboards are kept for group chats. Use this command in a group.": "Las clasificaciones son de los grupos. Usa este comando en un grupo.",
+	"off": "desactivado",
+	"on":  "activado",
+	"The weekly summary is %s. Group admins can change it with /weekly_summary on or /weekly_summary off": "El resumen semanal está %s. Los administradores del grupo pueden cambiarlo con /weekly_summary on o /weekly_summary off",
+	"Only group admins can change the weekly summary.":                                                    "Solo los administradores del grupo pueden cambiar el resumen semanal.",
+	"Sorry, the weekly summary could not be changed. Please try again.":                                   "Lo siento, no se pudo cambiar el resumen semanal. Inténtalo de nuevo.",
+	"Every Monday I will post last week's leaderboard and crown the top scorer.":                          "Cada lunes publicaré la clasificación de la semana pasada y coronaré al mejor jugador.",
+	"The weekly summary is now off.":                                                                      "El resumen semanal está desactivado.",
+	"I am speaking %s. Change it with /language code, choosing from %s":                                   "Estoy hablando %s. Cámbialo con /language código, eligiendo entre %s",
+	"Sorry, I do not speak %s yet. Please choose from %s":                                                 "Lo siento, aún no hablo %s. Elige entre %s",
+	"Only group admins can change the language of the group.":                                             "Solo los administradores del grupo pueden cambiar el idioma del grupo.",
+	"Please run /start first, then choose your language.":                                                 "Ejecuta /start primero y luego elige tu idioma.",
+	"change the language":          "cambiar el idioma",
+	"From now on I will speak %s.": "A partir de ahora hablaré %s.",
+	"log you in":                   "iniciar tu sesión",
+	"create your account":          "crear tu cuenta",
+	"Hello %s!":                    "¡Hola %s!",
+	"This share link is no longer valid. Please ask your friend for a new one.": "Este enlace ya no es válido. Pide uno nuevo a tu amigo.",
+	"Sorry, the shared quiz could not be loaded. Please try again.":             "Lo siento, no se pudo cargar el quiz compartido. Inténtalo de nuevo.",
+	"Quiz title cannot be empty, please try again!":                             "¡El título del quiz no puede estar vacío, inténtalo de nuevo!",
+	"Quiz title exists": "Ese título de quiz ya existe",
+	"add the quiz":      "añadir el quiz",
+	"New Quiz Title: %s is added into your collection.": "Nuevo quiz: %s se ha añadido a tu colección.",
+	"Quiz titled %s found!\nPress <strong>Exit</strong> to save changes and end\nPress <strong>Cancel</strong> to quit without saving\nPlease input new question:": "¡Quiz %s encontrado!\nPulsa <strong>Salir</strong> para guardar los cambios y terminar\nPulsa <strong>Cancelar</strong> para salir sin guardar\nEscribe la nueva pregunta:",
+	"This quiz has no questions to remove!": "¡Este quiz no tiene preguntas para quitar!",
+	"Quiz titled %s found!\nFor each question:\nPress <strong>Keep</strong> to keep the question\nPress <strong>Toss</strong> to remove the question\nPress <strong>Cancel</strong> to revert changes\n": "¡Quiz %s encontrado!\nPara cada pregunta:\nPulsa <strong>Conservar</strong> para mantener la pregunta\nPulsa <strong>Descartar</strong> para quitar la pregunta\nPulsa <strong>Cancelar</strong> para deshacer los cambios\n",
+	"Are you sure you want to delete quiz <strong>%s</strong>?\n": "¿Seguro que quieres borrar el quiz <strong>%s</strong>?\n",
+	"Quiz could not be found. Error deleting quiz: %s":            "No se encontró el quiz. Error al borrar el quiz: %s",
+	"Sorry, your quizzes could not be loaded. Please try again.":  "Lo siento, no se pudieron cargar tus quizzes. Inténtalo de nuevo.",
+	"Here is the list of your quizzes: \n":                        "Esta es la lista de tus quizzes: \n",
+	"No quizzes found in %s.\nFilter by tag with <strong>/list_quizzes #<i>tag</i></strong> or by folder with <strong>/list_quizzes <i>folder</i></strong>": "No se encontraron quizzes en %s.\nFiltra por etiqueta con <strong>/list_quizzes #<i>etiqueta</i></strong> o por carpeta con <strong>/list_quizzes <i>carpeta</i></strong>",
+	"No quizzes found. Create one with /add_quiz quiz name":                                                                                   "No se encontraron quizzes. Crea uno con /add_quiz nombre del quiz",
+	"Quiz titled %s found!\nCurrent tags: %s\nPlease input the tags to add, separated by commas:\n(Press <strong>Cancel</strong> to exit)":    "¡Quiz %s encontrado!\nEtiquetas actuales: %s\nEscribe las etiquetas a añadir, separadas por comas:\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Quiz titled %s found!\nCurrent tags: %s\nPlease input the tags to remove, separated by commas:\n(Press <strong>Cancel</strong> to exit)": "¡Quiz %s encontrado!\nEtiquetas actuales: %s\nEscribe las etiquetas a quitar, separadas por comas:\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"none": "ninguna",
+	"Quiz titled %s found!\nCurrent folder: %s\nPlease input the folder to move this quiz to, e.g. <i>Biology/Chapter 3</i>\nInput <strong>/</strong> to take the quiz out of its folder.\n(Press <strong>Cancel</strong> to exit)": "¡Quiz %s encontrado!\nCarpeta actual: %s\nEscribe la carpeta a la que mover este quiz, p. ej. <i>Biología/Capítulo 3</i>\nEscribe <strong>/</strong> para sacar el quiz de su carpeta.\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Sorry, your trash could not be loaded. Please try again.":                                 "Lo siento, no se pudo cargar tu papelera. Inténtalo de nuevo.",
+	"Please include the number of the item to restore, as shown by /trash.\ne.g. `/restore 1`": "Incluye el número del elemento a restaurar, tal como lo muestra /trash.\np. ej. `/restore 1`",
+	"There is nothing to undo.":                             "No hay nada que deshacer.",
+	"\n\nThis quiz was private, so it is now %s.":           "\n\nEste quiz era privado, así que ahora es %s.",
+	"Sorry, quiz %s could not be shared. Please try again.": "Lo siento, no se pudo compartir el quiz %s. Inténtalo de nuevo.",
+	"Share quiz <strong>%s</strong> with this link:\n%s\n\nFriends can also send <strong>/start %s</strong> to try it.\nUse <strong>/unshare %s</strong> to stop sharing it.%s": "Comparte el quiz <strong>%s</strong> con este enlace:\n%s\n\nTus amigos también pueden enviar <strong>/start %s</strong> para probarlo.\nUsa <strong>/unshare %s</strong> para dejar de compartirlo.%s",
+	"Sorry, quiz %s could not be unshared. Please try again.": "Lo siento, no se pudo dejar de compartir el quiz %s. Inténtalo de nuevo.",
+	"Quiz %s is not shared.":                                  "El quiz %s no está compartido.",
+	"Share links for quiz %s no longer work.":                 "Los enlaces del quiz %s ya no funcionan.",
+	"Quiz titled %s is %s.\nChoose who can try it:\n<strong>Private</strong> - only you\n<strong>Shared</strong> - users and groups you choose, and anyone with its share link\n<strong>Public</strong> - anyone": "El quiz %s es %s.\nElige quién puede probarlo:\n<strong>Privado</strong> - solo tú\n<strong>Compartido</strong> - los usuarios y grupos que elijas, y quien tenga su enlace\n<strong>Público</strong> - cualquiera",
+	"This quiz has no questions to edit!": "¡Este quiz no tiene preguntas para editar!",
+	"Please input the number of the question to edit.\nPress <strong>Exit</strong> when you are done.": "Escribe el número de la pregunta a editar.\nPulsa <strong>Salir</strong> cuando termines.",
+	"Co-editors of quiz %s: %s\n":      "Coeditores del quiz %s: %s\n",
+	"Quiz %s has no co-editors yet.\n": "El quiz %s aún no tiene coeditores.\n",
+	"Co-editors can use <strong>/add_qns</strong>, <strong>/edit_qns</strong> and <strong>/remove_qns</strong> on this quiz.":  "Los coeditores pueden usar <strong>/add_qns</strong>, <strong>/edit_qns</strong> y <strong>/remove_qns</strong> en este quiz.",
+	"Quiz %s was not copied from another quiz, so there is nothing to sync.":                                                   "El quiz %s no se copió de otro quiz, así que no hay nada que sincronizar.",
+	"Sorry, the changes could not be loaded. Please try again.":                                                                "Lo siento, no se pudieron cargar los cambios. Inténtalo de nuevo.",
+	"Quiz %s is up to date with its source.":                                                                                   "El quiz %s está al día con su original.",
+	"The quiz %s was copied from is no longer shared with you, so it cannot be synced.":                                        "El quiz del que se copió %s ya no está compartido contigo, así que no se puede sincronizar.",
+	"Please include your friend's id or @username and their quiz name with this command.\ne.g. `/copy_quiz @friend demo quiz`": "Incluye el id o @usuario de tu amigo y el nombre de su quiz con este comando.\np. ej. `/copy_quiz @amigo demo quiz`",
+	"Sorry, the quiz could not be copied. Please try again.":                                                                   "Lo siento, no se pudo copiar el quiz. Inténtalo de nuevo.",
+	"Could not find %s.": "No se encontró a %s.",
+	"Copied quiz %s to your quizzes. Use /sync %s to pull in later changes from your friend.":                                         "Quiz %s copiado a tus quizzes. Usa /sync %s para traer los cambios posteriores de tu amigo.",
+	"Challenges are played in private chats with me. Please send /challenge to me directly.":                                          "Los desafíos se juegan en chats privados conmigo. Envíame /challenge directamente.",
+	"Please finish your current challenge first.":                                                                                     "Termina primero tu desafío actual.",
+	"Please include your friend's id or @username and one of your quiz names with this command.\ne.g. `/challenge @friend demo quiz`": "Incluye el id o @usuario de tu amigo y el nombre de uno de tus quizzes con este comando.\np. ej. `/challenge @amigo demo quiz`",
+	"Could not find %s. They need to /start the bot before you can challenge them.":                                                   "No se encontró a %s. Tiene que usar /start con el bot antes de que puedas desafiarle.",
+	"You cannot challenge yourself!": "¡No puedes desafiarte a ti mismo!",
+	"Sorry, the challenge could not be sent. Your friend needs to have started a chat with me.":                                                                        "Lo siento, no se pudo enviar el desafío. Tu amigo tiene que haber iniciado un chat conmigo.",
+	"Challenge sent to %s! Your questions start now, I will send you both the results once you have both finished.":                                                    "¡Desafío enviado a %s! Tus preguntas empiezan ahora, os enviaré los resultados cuando los dos hayáis terminado.",
+	"Sorry, the quiz catalog could not be loaded. Please try again.":                                                                                                   "Lo siento, no se pudo cargar el catálogo de quizzes. Inténtalo de nuevo.",
+	"There are no public quizzes yet. Make one of yours public with /visibility quiz_name":                                                                             "Aún no hay quizzes públicos. Haz público uno de los tuyos con /visibility nombre_quiz",
+	"Please include keywords to search for.\nQuiz titles, tags and questions are searched.\ne.g. `/search cell biology`":                                               "Incluye palabras clave para buscar.\nSe buscan títulos de quizzes, etiquetas y preguntas.\np. ej. `/search biología celular`",
+	"Sorry, your daily questions could not be loaded. Please try again.":                                                                                               "Lo siento, no se pudieron cargar tus preguntas diarias. Inténtalo de nuevo.",
+	"Please include a quiz name and a time with this command, and optionally your time zone.\ne.g. `/daily demo quiz 08:30` or `/daily demo quiz 08:30 Europe/London`": "Incluye el nombre de un quiz y una hora con este comando, y opcionalmente tu zona horaria.\np. ej. `/daily demo quiz 08:30` o `/daily demo quiz 08:30 Europe/Madrid`",
+	"Sorry, your daily question could not be saved. Please try again.":                                                                                                 "Lo siento, no se pudo guardar tu pregunta diaria. Inténtalo de nuevo.",
+	"You will get a question from %s every day at %02d:%02d %s in your private chat with me, starting %s.":                                                             "Recibirás una pregunta de %s cada día a las %02d:%02d %s en tu chat privado conmigo, a partir del %s.",
+	"Please include a quiz name with this command.\ne.g. `/daily_off demo quiz`":                                                                                       "Incluye el nombre de un quiz con este comando.\np. ej. `/daily_off demo quiz`",
+	"You have no daily question from %s.":                                                "No tienes ninguna pregunta diaria de %s.",
+	"Sorry, please try again.":                                                           "Lo siento, inténtalo de nuevo.",
+	"You will no longer get daily questions from %s.":                                    "Ya no recibirás preguntas diarias de %s.",
+	"I will remind you at %02d:00 in your time zone when your streak is about to break.": "Te lo recordaré a las %02d:00 en tu zona horaria cuando tu racha esté por romperse.",
+	"Streak reminders are off.":                                                          "Los recordatorios de racha están desactivados.",
+	"Please choose on or off.\ne.g. `/%s on`":                                            "Elige on u off.\np. ej. `/%s on`",
+	"Questions and answers you add from now on keep their bold, italics, underline, strikethrough, code, spoilers and links.": "Las preguntas y respuestas que añadas a partir de ahora conservan su negrita, cursiva, subrayado, tachado, código, spoilers y enlaces.",
+	"Questions and answers you add from now on are saved as plain text.":                                                      "Las preguntas y respuestas que añadas a partir de ahora se guardan como texto sin formato.",
+	"Here is your user info: \n<strong>id</strong>: %s\n<strong>firstname</strong> %s\n<strong>username</strong> %s\n":        "Esta es tu información de usuario: \n<strong>id</strong>: %s\n<strong>nombre</strong> %s\n<strong>usuario</strong> %s\n",
+	"<strong>group chat id</strong>: %d\n":                                                     "<strong>id del grupo</strong>: %d\n",
+	"Would you like to try your own quiz or a friend's quiz?":                                  "¿Quieres probar tu propio quiz o el quiz de un amigo?",
+	"Sorry I don't understand you! Type <strong>/help</strong> for a list of commands!":        "¡Lo siento, no te entiendo! ¡Escribe <strong>/help</strong> para ver la lista de comandos!",
+	"Please input the quiz name, or select it below:\n(Press <strong>Cancel</strong> to exit)": "Escribe el nombre del quiz, o selecciónalo abajo:\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Please input your friend's user id number.\nYour friend can get their id number using the <strong>/get_my_id</strong> command.\n(Press <strong>Cancel</strong> to exit)": "Escribe el número de id de usuario de tu amigo.\nTu amigo puede obtener su número de id con el comando <strong>/get_my_id</strong>.\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Cancelling quiz attempt":               "Cancelando el intento del quiz",
+	"load the quiz":                         "cargar el quiz",
+	"You previously got %s on this quiz.\n": "Antes obtuviste %s en este quiz.\n",
+	"This quiz has no questions to try! Please enter another quiz name.": "¡Este quiz no tiene preguntas para probar! Escribe el nombre de otro quiz.",
+	"Quiz with name %s not found. Please re-enter your quiz name":        "No se encontró ningún quiz llamado %s. Vuelve a escribir el nombre de tu quiz",
+	"look up your friend": "buscar a tu amigo",
+	"Friend with username %s found! Please input the quiz name:\n(Press <strong>Cancel</strong> to exit)": "¡Amigo con usuario %s encontrado! Escribe el nombre del quiz:\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"User with with ID %s not found in our database. Please re-enter friend ID":                           "No se encontró ningún usuario con ID %s. Vuelve a escribir el ID de tu amigo",
+	"Quiz with name %s not found. Please re-enter your friend's quiz name":                                "No se encontró ningún quiz llamado %s. Vuelve a escribir el nombre del quiz de tu amigo",
+	"Congrats perfect score!":            "¡Enhorabuena, puntuación perfecta!",
+	"Congrats you passed!":               "¡Enhorabuena, has aprobado!",
+	"You failed! Better luck next time.": "¡Has suspendido! Más suerte la próxima vez.",
+	"How would you rate quiz %s?":        "¿Cómo valorarías el quiz %s?",
+	"save your questions":                "guardar tus preguntas",
+	"Your questions are kept. Press <strong>Exit</strong> to try saving them again, or <strong>Cancel</strong> to discard them.": "Tus preguntas se conservan. Pulsa <strong>Salir</strong> para intentar guardarlas de nuevo, o <strong>Cancelar</strong> para descartarlas.",
+	"Questions with answer inputs added to quiz!":                        "¡Preguntas con respuestas añadidas al quiz!",
+	"Are you sure you want to <strong>Cancel</strong> update?":           "¿Seguro que quieres <strong>Cancelar</strong> la actualización?",
+	"Please input text, or send a photo, document, audio or voice note:": "Escribe un texto, o envía una foto, documento, audio o nota de voz:",
+	"Please input the answer:":                                           "Escribe la respuesta:",
+	"Please input the next question:":                                    "Escribe la siguiente pregunta:",
+	"Changes to quiz cancelled.":                                         "Cambios en el quiz cancelados.",
+	"Please input next question":                                         "Escribe la siguiente pregunta",
+	"Please input the answer":                                            "Escribe la respuesta",
+	"Kept":                                                               "Conservada",
+	"Tossed":                                                             "Descartada",
+	"Continuing quiz review. Toss or keep previous question?":            "Continuando la revisión del quiz. ¿Descartar o conservar la pregunta anterior?",
+	"remove the questions":                                               "quitar las preguntas",
+	"Removed selected questions. Use <strong>/undo</strong> to bring them back.":       "Preguntas seleccionadas quitadas. Usa <strong>/undo</strong> para recuperarlas.",
+	"Removed selected questions. The quiz owner can bring them back from their trash.": "Preguntas seleccionadas quitadas. El dueño del quiz puede recuperarlas de su papelera.",
+	"No changes made to quiz %s.":                            "No se hicieron cambios en el quiz %s.",
+	"Added tags %s to quiz %s.":                              "Etiquetas %s añadidas al quiz %s.",
+	"Removed tags %s from quiz %s.":                          "Etiquetas %s quitadas del quiz %s.",
+	"Quiz %s is no longer in a folder.":                      "El quiz %s ya no está en una carpeta.",
+	"Moved quiz %s to folder %s.":                            "Quiz %s movido a la carpeta %s.",
+	"Sorry, quiz %s could not be updated. Please try again.": "Lo siento, no se pudo actualizar el quiz %s. Inténtalo de nuevo.",
+	"Quiz %s is now %s.":                                     "El quiz %s ahora es %s.",
+	"Please input the user ids or @usernames to share this quiz with, separated by commas.\nTo share it with a group, input the group chat id shown by <strong>/get_my_id</strong> in that group.\nInput <strong>-</strong> to share it only with people who have its share link.\n(Press <strong>Cancel</strong> to exit)": "Escribe los ids o @usuarios con quienes compartir este quiz, separados por comas.\nPara compartirlo con un grupo, escribe el id del grupo que muestra <strong>/get_my_id</strong> en ese grupo.\nEscribe <strong>-</strong> para compartirlo solo con quien tenga su enlace.\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Could not find %s. They need to /start the bot before you can share with them. Please re-enter the users to share with.":            "No se encontró a %s. Tiene que usar /start con el bot antes de que puedas compartir con él. Vuelve a escribir los usuarios con quienes compartir.",
+	"Please input the user ids or @usernames of the co-editors to add, separated by commas.\n(Press <strong>Cancel</strong> to exit)":    "Escribe los ids o @usuarios de los coeditores a añadir, separados por comas.\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Please input the user ids or @usernames of the co-editors to remove, separated by commas.\n(Press <strong>Cancel</strong> to exit)": "Escribe los ids o @usuarios de los coeditores a quitar, separados por comas.\n(Pulsa <strong>Cancelar</strong> para salir)",
+	"Could not find %s. They need to /start the bot before they can edit your quizzes. Please re-enter the co-editors.":                  "No se encontró a %s. Tiene que usar /start con el bot antes de poder editar tus quizzes. Vuelve a escribir los coeditores.",
+	"Please input at least one user id or @username.":                                                    "Escribe al menos un id o @usuario.",
+	"@%s added you as a co-editor of quiz %s. Use /add_qns, /edit_qns and /remove_qns to help build it.": "@%s te añadió como coeditor del quiz %s. Usa /add_qns, /edit_qns y /remove_qns para ayudar a crearlo.",
+	"Added %s as co-editors of quiz %s.":                                                                 "%s añadidos como coeditores del quiz %s.",
+	"Removed %s from the co-editors of quiz %s.":                                                         "%s quitados de los coeditores del quiz %s.",
+	"You can edit more than one quiz named %s. Please choose whose quiz you mean:":                       "Puedes editar más de un quiz llamado %s. Elige de quién es el que quieres:",
+	"Finished editing quiz %s.":                                                                          "Edición del quiz %s terminada.",
+	"Please input a question number from 1 to %d.":                                                       "Escribe un número de pregunta del 1 al %d.",
+	"Please input the new question, or press <strong>Keep current</strong>:":                             "Escribe la nueva pregunta, o pulsa <strong>Dejar como está</strong>:",
+	"Question not changed. Please input the number of the next question to edit:":                        "Pregunta sin cambios. Escribe el número de la siguiente pregunta a editar:",
+	"Please input the new answer, or press <strong>Keep current</strong>:":                               "Escribe la nueva respuesta, o pulsa <strong>Dejar como está</strong>:",
+	"Question updated!": "¡Pregunta actualizada!",
+	"This question was changed by another editor, or the new question already exists. Please check the latest questions with /edit_qns %s.": "Otro editor cambió esta pregunta, o la nueva pregunta ya existe. Revisa las últimas preguntas con /edit_qns %s.",
+	"update the question": "actualizar la pregunta",
+	"%s\nPlease input the number of the next question to edit:": "%s\nEscribe el número de la siguiente pregunta a editar:",
+	"save your rating":           "guardar tu valoración",
+	"Thanks for rating quiz %s!": "¡Gracias por valorar el quiz %s!",
+	"Rating skipped.":            "Valoración omitida.",
+	"Please input change numbers from 1 to %d, e.g. 1, 3-4": "Escribe números de cambios del 1 al %d, p. ej. 1, 3-4",
+	"pull the changes": "traer los cambios",
+	"delete the quiz":  "borrar el quiz",
+	"Successfully deleted quiz: %s\nUse <strong>/undo</strong> to bring it back.": "Quiz borrado: %s\nUsa <strong>/undo</strong> para recuperarlo.",
+	"Quiz deletion cancelled.": "Borrado del quiz cancelado.",
+	"User not logged in. Please run <strong>/start</strong> to log in user": "No has iniciado sesión. Ejecuta <strong>/start</strong> para iniciarla",
+
+	// storage errors
+	"Sorry, I could not %s because it no longer exists.":                                            "Lo siento, no pude %s porque ya no existe.",
+	"Sorry, I could not %s because it was changed at the same time. Please try again.":              "Lo siento, no pude %s porque se cambió al mismo tiempo. Inténtalo de nuevo.",
+	"Sorry, I could not %s because storage is unavailable right now. Please try again in a minute.": "Lo siento, no pude %s porque el almacenamiento no está disponible ahora. Inténtalo de nuevo en un minuto.",
+	"Sorry, I could not %s because you can no longer edit this quiz.":                               "Lo siento, no pude %s porque ya no puedes editar este quiz.",
+	"Sorry, I could not %s. Please try again.":                                                      "Lo siento, no pude %s. Inténtalo de nuevo.",
+	" (error ref %s)": " (ref. del error %s)",
+
+	// streaks
+	"Sorry, your profile could not be loaded. Please try again.": "Lo siento, no se pudo cargar tu perfil. Inténtalo de nuevo.",
+	"on, at %02d:00":                                                      "activados, a las %02d:00",
+	"<strong>Profile of %s</strong>\n":                                    "<strong>Perfil de %s</strong>\n",
+	"<strong>Quizzes:</strong> %d\n":                                      "<strong>Quizzes:</strong> %d\n",
+	"<strong>Daily questions:</strong> %d\n":                              "<strong>Preguntas diarias:</strong> %d\n",
+	"<strong>Time zone:</strong> %s\n":                                    "<strong>Zona horaria:</strong> %s\n",
+	"<strong>Streak reminders:</strong> %s\n\n":                           "<strong>Recordatorios de racha:</strong> %s\n\n",
+	"<i>Turn streak reminders on or off with /nudge on or /nudge off</i>": "<i>Activa o desactiva los recordatorios de racha con /nudge on o /nudge off</i>",
+
+	// trash
+	"quiz <strong>%s</strong>": "el quiz <strong>%s</strong>",
+	"Your trash is empty.":     "Tu papelera está vacía.",
+	"Here is your trash: \n":   "Esta es tu papelera: \n",
+	"\nUse <strong>/restore <i>number</i></strong> to restore an item, or <strong>/undo</strong> to restore the most recent one.": "\nUsa <strong>/restore <i>número</i></strong> para restaurar un elemento, o <strong>/undo</strong> para restaurar el más reciente.",
+	"Restored %s.": "Restaurado: %s.",
+	"A quiz named <strong>%s</strong> already exists. Delete or rename it before restoring.": "Ya existe un quiz llamado <strong>%s</strong>. Bórralo o cámbiale el nombre antes de restaurar.",
+	"Sorry, the item could not be restored. Please try again.":                               "Lo siento, no se pudo restaurar el elemento. Inténtalo de nuevo.",
+
+	// visibility
+	"public, anyone can try it":                        "público, cualquiera puede probarlo",
+	"shared with anyone who has its share link":        "compartido con quien tenga su enlace",
+	"shared with %s and anyone who has its share link": "compartido con %s y con quien tenga su enlace",
+	"private, only you can try it":                     "privado, solo tú puedes probarlo",
+}
+
+// spanishPlurals are the Spanish texts that depend on a number, by their English plural text
+var spanishPlurals = map[string][]string{
+	"Your %d day streak ends tonight! Try a quiz with /try_quiz to keep it going.": {
+		"¡Tu racha de %d día termina esta noche! Prueba un quiz con /try_quiz para mantenerla.",
+		"¡Tu racha de %d días termina esta noche! Prueba un quiz con /try_quiz para mantenerla.",
+	},
+	"%d questions":                   {"%d pregunta", "%d preguntas"},
+	", tried %d times":               {", probado %d vez", ", probado %d veces"},
+	"%d qns":                         {"%d preg.", "%d pregs."},
+	"%d points":                      {"%d punto", "%d puntos"},
+	" (%d/%d correct, %d quizzes)\n": {" (%d/%d correctas, %d quiz)\n", " (%d/%d correctas, %d quizzes)\n"},
+	"🏆 %s is last week's top scorer with %d points!\n\n": {
+		"🏆 ¡%s es el mejor jugador de la semana pasada con %d punto!\n\n",
+		"🏆 ¡%s es el mejor jugador de la semana pasada con %d puntos!\n\n",
+	},
+	"%s challenges you to quiz %s with %d questions! You both get the same questions, and the most correct answers wins, then the fastest.": {
+		"¡%s te desafía con el quiz %s de %d pregunta! Los dos recibís las mismas preguntas, y gana quien más acierte, y después el más rápido.",
+		"¡%s te desafía con el quiz %s de %d preguntas! Los dos recibís las mismas preguntas, y gana quien más acierte, y después el más rápido.",
+	},
+	"Time's up! The answer was <strong>%s</strong>, %d players got it right.\n\n<strong>Scoreboard</strong>\n%s": {
+		"¡Se acabó el tiempo! La respuesta era <strong>%s</strong>, %d jugador acertó.\n\n<strong>Marcador</strong>\n%s",
+		"¡Se acabó el tiempo! La respuesta era <strong>%s</strong>, %d jugadores acertaron.\n\n<strong>Marcador</strong>\n%s",
+	},
+	"%s is hosting live quiz %s with %d questions! Everyone can play: answer each question within %d seconds, faster correct answers score more points.": {
+		"¡%s presenta el quiz en vivo %s con %d pregunta! Todos pueden jugar: responde cada pregunta en menos de %d segundos, las respuestas correctas más rápidas suman más puntos.",
+		"¡%s presenta el quiz en vivo %s con %d preguntas! Todos pueden jugar: responde cada pregunta en menos de %d segundos, las respuestas correctas más rápidas suman más puntos.",
+	},
+	"Starting quiz %s with %d questions as quiz polls. Answer each one within %d seconds, or end early with /stop_quiz": {
+		"Empezando el quiz %s con %d pregunta como encuesta. Respóndela en menos de %d segundos, o termina antes con /stop_quiz",
+		"Empezando el quiz %s con %d preguntas como encuestas. Responde cada una en menos de %d segundos, o termina antes con /stop_quiz",
+	},
+	"It will be kept in your <strong>/trash</strong> for %d days.": {
+		"Se guardará en tu <strong>/trash</strong> durante %d día.",
+		"Se guardará en tu <strong>/trash</strong> durante %d días.",
+	},
+	"You scored %d/%d points\n%s":                {"Obtuviste %d/%d punto\n%s", "Obtuviste %d/%d puntos\n%s"},
+	"Pulled %d changes into quiz %s.":            {"%d cambio traído al quiz %s.", "%d cambios traídos al quiz %s."},
+	"🔥 Streak: %d days":                          {"🔥 Racha: %d día", "🔥 Racha: %d días"},
+	", best %d days":                             {", mejor %d día", ", mejor %d días"},
+	"<strong>Current streak:</strong> %d days\n": {"<strong>Racha actual:</strong> %d día\n", "<strong>Racha actual:</strong> %d días\n"},
+	"<strong>Best streak:</strong> %d days\n":    {"<strong>Mejor racha:</strong> %d día\n", "<strong>Mejor racha:</strong> %d días\n"},
+	"%d questions from <strong>%s</strong>":      {"%d pregunta de <strong>%s</strong>", "%d preguntas de <strong>%s</strong>"},
+	" (deleted %s, %d days left)":                {" (borrado %s, queda %d día)", " (borrado %s, quedan %d días)"},
+}
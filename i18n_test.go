@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func sameVerbs(a string, b string) bool {
+	verbsA, verbsB := formatVerb.FindAllString(a, -1), formatVerb.FindAllString(b, -1)
+	if len(verbsA) != len(verbsB) {
+		return false
+	}
+	for i := range verbsA {
+		if verbsA[i] != verbsB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTranslationVerbs(t *testing.T) {
+	for lang, texts := range translations {
+		for text, translated := range texts {
+			if !sameVerbs(text, translated) {
+				t.Errorf("Expected the %s translation of %q to have the same verbs but got: %q", lang, text, translated)
+			}
+		}
+	}
+	for lang, texts := range pluralTranslations {
+		for text, forms := range texts {
+			for _, form := range forms {
+				if !sameVerbs(text, form) {
+					t.Errorf("Expected the %s translation of %q to have the same verbs but got: %q", lang, text, form)
+				}
+			}
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	outputStr := translate("es", "Hello %s!", "Ana")
+	if outputStr != "¡Hola Ana!" {
+		t.Error("Expected: ¡Hola Ana! but got: " + outputStr)
+	}
+
+	outputStr = translate("fr", "Hello %s!", "Ana")
+	if outputStr != "Hello Ana!" {
+		t.Error("Expected: Hello Ana! but got: " + outputStr)
+	}
+
+	outputStr = translate("es", "not translated")
+	if outputStr != "not translated" {
+		t.Error("Expected: not translated but got: " + outputStr)
+	}
+}
+
+func TestTranslatePlural(t *testing.T) {
+	outputStr := translatePlural("es", 1, "%d point", "%d points", 1)
+	if outputStr != "1 punto" {
+		t.Error("Expected: 1 punto but got: " + outputStr)
+	}
+
+	outputStr = translatePlural("es", 0, "%d point", "%d points", 0)
+	if outputStr != "0 puntos" {
+		t.Error("Expected: 0 puntos but got: " + outputStr)
+	}
+
+	outputStr = translatePlural("fr", 2, "%d point", "%d points", 2)
+	if outputStr != "2 points" {
+		t.Error("Expected: 2 points but got: " + outputStr)
+	}
+}
+
+func TestSupportedLanguage(t *testing.T) {
+	if lang, ok := supportedLanguage("es-ES"); lang != "es" || !ok {
+		t.Errorf("Expected: es, true but got: %s, %t", lang, ok)
+	}
+	if lang, ok := supportedLanguage("fr"); lang != defaultLanguage || ok {
+		t.Errorf("Expected: %s, false but got: %s, %t", defaultLanguage, lang, ok)
+	}
+}
+
+func TestChatLanguage(t *testing.T) {
+	chatLanguages.Lock()
+	chatLanguages.guessed[101] = "es"
+	chatLanguages.chosen[102] = ""
+	chatLanguages.guessed[102] = "es"
+	chatLanguages.chosen[103] = "en"
+	chatLanguages.guessed[103] = "es"
+	chatLanguages.Unlock()
+
+	// a guess from the user's app is used until a language is chosen
+	for chatID, want := range map[int64]string{100: defaultLanguage, 101: "es", 102: "es", 103: "en"} {
+		if lang := chatLanguage(chatID); lang != want {
+			t.Errorf("Expected chat %d to speak %s but got: %s", chatID, want, lang)
+		}
+	}
+}
@@ -0,0 +1,776 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"image"
+	"image/png"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// DejaVu Serif, see fonts/LICENSE
+//
+//go:embed fonts/DejaVuSerif.ttf
+var formulaFontData []byte
+
+var formulaFont *fontFace
+
+func init() {
+	var err error
+	if formulaFont, err = parseFont(formulaFontData); err != nil {
+		log.Fatalf("error loading formula font: %v", err)
+	}
+}
+
+// font size of formulas in pixels, large enough to stay sharp when telegram scales the photo
+const formulaFontSize = 48
+
+// padding around rendered formulas in pixels
+const formulaPadding = 16
+
+// telegram rejects photos more than 20 times as wide as they are high
+const photoMaxAspectRatio = 20
+
+// telegram rejects photos whose width and height add up to more than this, in pixels
+const photoMaxDimensions = 10000
+
+// formulas longer than this, in characters, or more formulas than this in one text are not drawn, so that
+// drawing them stays quick. They are left as the $...$ text of the message
+const (
+	maxFormulaLength    = 300
+	maxFormulasPerImage = 5
+)
+
+// most formulas drawn at the same time, away from the updates loop
+const formulaRenderers = 2
+
+// most formulas kept rendered in memory
+const formulaCacheSize = 200
+
+var errFormulaTooLarge = errors.New("formula too large to draw")
+
+// mathSegments returns the formulas between $...$ or $$...$$ in the text. So that prices like $5 are not taken
+// for formulas, an opening $ is not followed by a space, and a closing $ is not after a space or before a digit
+func mathSegments(text string) []string {
+	var formulas []string
+	for {
+		start := strings.Index(text, "$")
+		if start < 0 {
+			return formulas
+		}
+
+		if strings.HasPrefix(text[start:], "$$") {
+			text = text[start+2:]
+			end := strings.Index(text, "$$")
+			if end < 0 {
+				return formulas
+			}
+			if formula := strings.TrimSpace(text[:end]); formula != "" {
+				formulas = append(formulas, formula)
+			}
+			text = text[end+2:]
+			continue
+		}
+
+		text = text[start+1:]
+		if next, _ := utf8.DecodeRuneInString(text); text == "" || unicode.IsSpace(next) {
+			continue
+		}
+		if end := closingDollar(text); end > 0 {
+			formulas = append(formulas, text[:end])
+			text = text[end+1:]
+		}
+	}
+}
+
+// closingDollar returns the index of the $ closing a formula that starts the text, or -1 if there is none
+func closingDollar(text string) int {
+	for end := 0; end < len(text); end++ {
+		if text[end] != '$' {
+			continue
+		}
+		before, _ := utf8.DecodeLastRuneInString(text[:end])
+		after, _ := utf8.DecodeRuneInString(text[end+1:])
+		if end > 0 && !unicode.IsSpace(before) && !unicode.IsDigit(after) {
+			return end
+		}
+	}
+	return -1
+}
+
+// mathBox is a laid out part of a formula, drawn with its baseline at y
+type mathBox interface {
+	width() float64
+	ascent() float64
+	descent() float64
+	draw(canvas *image.Alpha, x float64, y float64)
+}
+
+type glyphBox struct {
+	r       rune
+	size    float64
+	metrics glyphMetrics
+}
+
+func newGlyphBox(r rune, size float64) *glyphBox {
+	return &glyphBox{r: r, size: size, metrics: formulaFont.metrics(formulaFont.glyphIndex(r))}
+}
+
+func (box *glyphBox) scale() float64 {
+	return box.size / formulaFont.unitsPerEm
+}
+
+func (box *glyphBox) width() float64 {
+	return box.metrics.advance * box.scale()
+}
+
+func (box *glyphBox) ascent() float64 {
+	return math.Max(box.metrics.yMax*box.scale(), 0)
+}
+
+func (box *glyphBox) descent() float64 {
+	return math.Max(-box.metrics.yMin*box.scale(), 0)
+}
+
+func (box *glyphBox) draw(canvas *image.Alpha, x float64, y float64) {
+	formulaFont.drawGlyph(canvas, formulaFont.glyphIndex(box.r), box.size, x, y)
+}
+
+type spaceBox struct {
+	space float64
+}
+
+func (box *spaceBox) width() float64                                 { return box.space }
+func (box *spaceBox) ascent() float64                                { return 0 }
+func (box *spaceBox) descent() float64                               { return 0 }
+func (box *spaceBox) draw(canvas *image.Alpha, x float64, y float64) {}
+
+// rowBox places boxes side by side on the same baseline
+type rowBox struct {
+	boxes []mathBox
+}
+
+func (box *rowBox) width() float64 {
+	total := 0.0
+	for _, child := range box.boxes {
+		total += child.width()
+	}
+	return total
+}
+
+func (box *rowBox) ascent() float64 {
+	highest := 0.0
+	for _, child := range box.boxes {
+		highest = math.Max(highest, child.ascent())
+	}
+	return highest
+}
+
+func (box *rowBox) descent() float64 {
+	lowest := 0.0
+	for _, child := range box.boxes {
+		lowest = math.Max(lowest, child.descent())
+	}
+	return lowest
+}
+
+func (box *rowBox) draw(canvas *image.Alpha, x float64, y float64) {
+	for _, child := range box.boxes {
+		child.draw(canvas, x, y)
+		x += child.width()
+	}
+}
+
+// scriptBox is a base with a superscript, a subscript or both
+type scriptBox struct {
+	base     mathBox
+	sup, sub mathBox
+	size     float64
+}
+
+func (box *scriptBox) supShift() float64 {
+	return math.Max(box.base.ascent()-0.3*box.size, 0.4*box.size)
+}
+
+func (box *scriptBox) subShift() float64 {
+	return math.Max(box.base.descent(), 0.15*box.size)
+}
+
+func (box *scriptBox) width() float64 {
+	scripts := 0.0
+	if box.sup != nil {
+		scripts = box.sup.width()
+	}
+	if box.sub != nil {
+		scripts = math.Max(scripts, box.sub.width())
+	}
+	return box.base.width() + scripts + 0.05*box.size
+}
+
+func (box *scriptBox) ascent() float64 {
+	if box.sup == nil {
+		return box.base.ascent()
+	}
+	return math.Max(box.base.ascent(), box.supShift()+box.sup.ascent())
+}
+
+func (box *scriptBox) descent() float64 {
+	if box.sub == nil {
+		return box.base.descent()
+	}
+	return math.Max(box.base.descent(), box.subShift()+box.sub.descent())
+}
+
+func (box *scriptBox) draw(canvas *image.Alpha, x float64, y float64) {
+	box.base.draw(canvas, x, y)
+	x += box.base.width()
+	if box.sup != nil {
+		box.sup.draw(canvas, x, y-box.supShift())
+	}
+	if box.sub != nil {
+		box.sub.draw(canvas, x, y+box.subShift())
+	}
+}
+
+// fracBox stacks a numerator over a denominator around the math axis
+type fracBox struct {
+	num, den mathBox
+	size     float64
+}
+
+func (box *fracBox) axis() float64      { return 0.27 * box.size }
+func (box *fracBox) gap() float64       { return 0.12 * box.size }
+func (box *fracBox) thickness() float64 { return math.Max(0.05*box.size, 1) }
+
+func (box *fracBox) width() float64 {
+	return math.Max(box.num.width(), box.den.width()) + 0.3*box.size
+}
+
+func (box *fracBox) ascent() float64 {
+	return box.axis() + box.thickness()/2 + box.gap() + box.num.descent() + box.num.ascent()
+}
+
+func (box *fracBox) descent() float64 {
+	return math.Max(box.thickness()/2+box.gap()+box.den.ascent()+box.den.descent()-box.axis(), 0)
+}
+
+func (box *fracBox) draw(canvas *image.Alpha, x float64, y float64) {
+	axisY := y - box.axis()
+	width := box.width()
+
+	box.num.draw(canvas, x+(width-box.num.width())/2, axisY-box.thickness()/2-box.gap()-box.num.descent())
+	box.den.draw(canvas, x+(width-box.den.width())/2, axisY+box.thickness()/2+box.gap()+box.den.ascent())
+	fillPolygons(canvas, [][]fpoint{rectPolygon(x+0.1*box.size, axisY-box.thickness()/2, x+width-0.1*box.size, axisY+box.thickness()/2)})
+}
+
+// sqrtBox draws a radical sign over its body, with an optional index
+type sqrtBox struct {
+	body  mathBox
+	index mathBox
+	size  float64
+}
+
+func (box *sqrtBox) thickness() float64 { return math.Max(0.05*box.size, 1) }
+func (box *sqrtBox) gap() float64       { return 0.12 * box.size }
+func (box *sqrtBox) signWidth() float64 { return 0.55 * box.size }
+
+func (box *sqrtBox) indexWidth() float64 {
+	if box.index == nil {
+		return 0
+	}
+	return math.Max(box.index.width()-0.3*box.size, 0)
+}
+
+func (box *sqrtBox) width() float64 {
+	return box.indexWidth() + box.signWidth() + box.body.width() + 0.1*box.size
+}
+
+func (box *sqrtBox) ascent() float64 {
+	ascent := math.Max(box.body.ascent(), 0.7*box.size) + box.gap() + box.thickness()
+	if box.index != nil {
+		ascent = math.Max(ascent, 0.6*ascent+box.index.ascent()+box.index.descent())
+	}
+	return ascent
+}
+
+func (box *sqrtBox) descent() float64 {
+	return math.Max(box.body.descent(), 0.1*box.size) + box.thickness()
+}
+
+func (box *sqrtBox) draw(canvas *image.Alpha, x float64, y float64) {
+	top := y - math.Max(box.body.ascent(), 0.7*box.size) - box.gap() - box.thickness()/2
+	bottom := y + math.Max(box.body.descent(), 0.1*box.size)
+	x += box.indexWidth()
+	bodyX := x + box.signWidth()
+
+	if box.index != nil {
+		box.index.draw(canvas, x+0.25*box.size-box.index.width(), y-0.4*(y-top)-box.index.descent())
+	}
+
+	thickness := box.thickness()
+	fillPolygons(canvas, [][]fpoint{
+		linePolygon(fpoint{x + 0.05*box.size, y - 0.35*box.size}, fpoint{x + 0.2*box.size, y - 0.42*box.size}, thickness),
+		linePolygon(fpoint{x + 0.2*box.size, y - 0.42*box.size}, fpoint{x + 0.3*box.size, bottom}, thickness*1.8),
+		linePolygon(fpoint{x + 0.3*box.size, bottom}, fpoint{bodyX - 0.02*box.size, top}, thickness),
+		rectPolygon(bodyX-0.02*box.size, top-thickness/2, bodyX+box.body.width()+0.1*box.size, top+thickness/2),
+	})
+	box.body.draw(canvas, bodyX, y)
+}
+
+func rectPolygon(x0 float64, y0 float64, x1 float64, y1 float64) []fpoint {
+	return []fpoint{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+// linePolygon is a straight line of the thickness from one point to another
+func linePolygon(from fpoint, to fpoint, thickness float64) []fpoint {
+	length := math.Hypot(to.x-from.x, to.y-from.y)
+	if length == 0 {
+		return nil
+	}
+	nx, ny := -(to.y-from.y)/length*thickness/2, (to.x-from.x)/length*thickness/2
+	return []fpoint{{from.x + nx, from.y + ny}, {to.x + nx, to.y + ny}, {to.x - nx, to.y - ny}, {from.x - nx, from.y - ny}}
+}
+
+// mathSymbols are the commands drawn as a single character
+var mathSymbols = map[string]rune{
+	"alpha": 'α', "beta": 'β', "gamma": 'γ', "delta": 'δ', "epsilon": 'ε', "varepsilon": 'ε', "zeta": 'ζ',
+	"eta": 'η', "theta": 'θ', "vartheta": 'ϑ', "iota": 'ι', "kappa": 'κ', "lambda": 'λ', "mu": 'μ', "nu": 'ν',
+	"xi": 'ξ', "pi": 'π', "rho": 'ρ', "sigma": 'σ', "tau": 'τ', "upsilon": 'υ', "phi": 'φ', "varphi": 'φ',
+	"chi": 'χ', "psi": 'ψ', "omega": 'ω',
+	"Gamma": 'Γ', "Delta": 'Δ', "Theta": 'Θ', "Lambda": 'Λ', "Xi": 'Ξ', "Pi": 'Π', "Sigma": 'Σ',
+	"Upsilon": 'Υ', "Phi": 'Φ', "Psi": 'Ψ', "Omega": 'Ω',
+	"times": '×', "cdot": '·', "div": '÷', "pm": '±', "mp": '∓', "ast": '∗',
+	"leq": '≤', "le": '≤', "geq": '≥', "ge": '≥', "neq": '≠', "ne": '≠', "approx": '≈', "equiv": '≡',
+	"sim": '∼', "propto": '∝', "infty": '∞', "partial": '∂', "nabla": '∇', "degree": '°', "circ": '∘',
+	"to": '→', "rightarrow": '→', "leftarrow": '←', "Rightarrow": '⇒', "Leftarrow": '⇐',
+	"leftrightarrow": '↔', "Leftrightarrow": '⇔', "in": '∈', "notin": '∉', "subset": '⊂', "supset": '⊃',
+	"cup": '∪', "cap": '∩', "forall": '∀', "exists": '∃', "neg": '¬', "wedge": '∧', "vee": '∨',
+	"int": '∫', "oint": '∮', "sum": '∑', "prod": '∏', "hbar": 'ℏ', "ell": 'ℓ', "angle": '∠', "perp": '⊥',
+	"ldots": '…', "dots": '…', "cdots": '⋯', "prime": '′', "langle": '⟨', "rangle": '⟩',
+	"{": '{', "}": '}', "%": '%', "$": '$', "&": '&', "#": '#', "_": '_', "|": '‖',
+}
+
+// binary operators and relations are spaced out from their neighbours
+var mathOperators = map[rune]bool{
+	'+': true, '−': true, '=': true, '<': true, '>': true, '×': true, '·': true, '÷': true, '±': true, '∓': true,
+	'∗': true, '≤': true, '≥': true, '≠': true, '≈': true, '≡': true, '∼': true, '∝': true, '→': true,
+	'←': true, '⇒': true, '⇐': true, '↔': true, '⇔': true, '∈': true, '∉': true, '⊂': true, '⊃': true,
+	'∪': true, '∩': true, '∧': true, '∨': true,
+}
+
+// functions written upright as words
+var mathFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true, "arcsin": true,
+	"arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true, "log": true, "ln": true,
+	"exp": true, "lim": true, "max": true, "min": true, "det": true, "gcd": true,
+}
+
+var mathSpaces = map[string]float64{
+	",": 0.17, ":": 0.22, ";": 0.28, " ": 0.33, "quad": 1, "qquad": 2, "!": -0.17,
+}
+
+// mathParser lays out a formula in a subset of LaTeX
+type mathParser struct {
+	input []rune
+	pos   int
+}
+
+func (parser *mathParser) done() bool {
+	return parser.pos >= len(parser.input)
+}
+
+func (parser *mathParser) peek() rune {
+	if parser.done() {
+		return 0
+	}
+	return parser.input[parser.pos]
+}
+
+func (parser *mathParser) skipSpaces() {
+	for !parser.done() && unicode.IsSpace(parser.peek()) {
+		parser.pos++
+	}
+}
+
+// command reads the name of a command after its backslash, a word or a single other character
+func (parser *mathParser) command() string {
+	start := parser.pos
+	for !parser.done() && unicode.IsLetter(parser.peek()) {
+		parser.pos++
+	}
+	if parser.pos == start && !parser.done() {
+		parser.pos++
+	}
+	return string(parser.input[start:parser.pos])
+}
+
+// parseRow lays out everything up to the end of the current group, or up to the closing character
+func (parser *mathParser) parseRow(size float64, closing rune) *rowBox {
+	row := &rowBox{}
+	for {
+		parser.skipSpaces()
+		if parser.done() || parser.peek() == '}' || parser.peek() == closing {
+			return row
+		}
+
+		box := parser.parseAtom(size)
+		if box == nil {
+			continue
+		}
+		box = parser.parseScripts(box, size)
+
+		// operators are spaced out unless they are unary, or in a script
+		if isMathOperator(box) && row.endsWithOperand() && size > formulaFontSize*0.8 {
+			space := &spaceBox{space: 0.22 * size}
+			row.boxes = append(row.boxes, space, box, space)
+		} else {
+			row.boxes = append(row.boxes, box)
+		}
+	}
+}
+
+func isMathOperator(box mathBox) bool {
+	glyph, ok := box.(*glyphBox)
+	return ok && mathOperators[glyph.r]
+}
+
+// endsWithOperand reports whether the row's last box, ignoring spaces, is something an operator can apply to
+func (box *rowBox) endsWithOperand() bool {
+	for i := len(box.boxes) - 1; i >= 0; i-- {
+		if _, ok := box.boxes[i].(*spaceBox); !ok {
+			return !isMathOperator(box.boxes[i])
+		}
+	}
+	return false
+}
+
+func (parser *mathParser) parseScripts(base mathBox, size float64) mathBox {
+	var box *scriptBox
+	for {
+		parser.skipSpaces()
+		next := parser.peek()
+		if next != '^' && next != '_' {
+			if box == nil {
+				return base
+			}
+			return box
+		}
+		parser.pos++
+
+		if box == nil {
+			box = &scriptBox{base: base, size: size}
+		}
+		script := parser.parseArgument(scriptSize(size))
+		if next == '^' {
+			box.sup = script
+		} else {
+			box.sub = script
+		}
+	}
+}
+
+func scriptSize(size float64) float64 {
+	return math.Max(size*0.7, formulaFontSize*0.45)
+}
+
+// parseArgument reads a group in braces, or a single atom
+func (parser *mathParser) parseArgument(size float64) mathBox {
+	parser.skipSpaces()
+	if parser.peek() == '{' {
+		parser.pos++
+		row := parser.parseRow(size, '}')
+		if parser.peek() == '}' {
+			parser.pos++
+		}
+		return row
+	}
+	if box := parser.parseAtom(size); box != nil {
+		return box
+	}
+	return &rowBox{}
+}
+
+// parseText reads a group in braces as plain text, keeping its spaces
+func (parser *mathParser) parseText(size float64) mathBox {
+	parser.skipSpaces()
+	row := &rowBox{}
+	if parser.peek() != '{' {
+		return row
+	}
+	parser.pos++
+
+	for depth := 1; !parser.done(); parser.pos++ {
+		r := parser.peek()
+		if r == '{' {
+			depth++
+		} else if r == '}' {
+			if depth--; depth == 0 {
+				parser.pos++
+				break
+			}
+		}
+		row.boxes = append(row.boxes, newGlyphBox(r, size))
+	}
+	return row
+}
+
+func textRow(text string, size float64) *rowBox {
+	row := &rowBox{}
+	for _, r := range text {
+		row.boxes = append(row.boxes, newGlyphBox(r, size))
+	}
+	return row
+}
+
+func (parser *mathParser) parseAtom(size float64) mathBox {
+	r := parser.peek()
+	parser.pos++
+
+	switch r {
+	case '{':
+		row := parser.parseRow(size, '}')
+		if parser.peek() == '}' {
+			parser.pos++
+		}
+		return row
+	case '-':
+		return newGlyphBox('−', size)
+	case '*':
+		return newGlyphBox('∗', size)
+	case '\'':
+		return newGlyphBox('′', size)
+	case '~':
+		return &spaceBox{space: 0.33 * size}
+	case '^', '_':
+		// a script without a base is attached to an empty box
+		parser.pos--
+		return parser.parseScripts(&rowBox{}, size)
+	case '\\':
+	default:
+		return newGlyphBox(r, size)
+	}
+
+	name := parser.command()
+	if symbol, ok := mathSymbols[name]; ok {
+		return newGlyphBox(symbol, size)
+	}
+	if space, ok := mathSpaces[name]; ok {
+		return &spaceBox{space: space * size}
+	}
+	if mathFunctions[name] {
+		return &rowBox{boxes: []mathBox{textRow(name, size), &spaceBox{space: 0.17 * size}}}
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		num := parser.parseArgument(size * 0.85)
+		den := parser.parseArgument(size * 0.85)
+		return &fracBox{num: num, den: den, size: size}
+	case "sqrt":
+		box := &sqrtBox{size: size}
+		parser.skipSpaces()
+		if parser.peek() == '[' {
+			parser.pos++
+			box.index = parser.parseRow(scriptSize(scriptSize(size)), ']')
+			if parser.peek() == ']' {
+				parser.pos++
+			}
+		}
+		box.body = parser.parseArgument(size)
+		return box
+	case "text", "textrm", "mathrm", "mbox", "operatorname":
+		return parser.parseText(size)
+	case "mathbf", "mathit", "mathsf", "mathcal", "boldsymbol", "textbf", "textit", "vec", "hat", "bar", "overline":
+		// drawn without the style or accent, which the font cannot show
+		return parser.parseArgument(size)
+	case "left", "right", "big", "Big", "bigg", "Bigg":
+		// delimiters are drawn at their normal size, and "." is no delimiter
+		parser.skipSpaces()
+		if parser.peek() == '.' {
+			parser.pos++
+		}
+		return nil
+	case "\\":
+		return &spaceBox{space: size}
+	}
+
+	// unknown commands are shown as they were typed
+	return textRow("\\"+name, size)
+}
+
+// layoutFormula lays out a formula as a row of boxes
+func layoutFormula(formula string) mathBox {
+	parser := &mathParser{input: []rune(formula)}
+	row := &rowBox{}
+	for !parser.done() {
+		row.boxes = append(row.boxes, parser.parseRow(formulaFontSize, '}'))
+		// unbalanced closing braces are skipped
+		if !parser.done() {
+			parser.pos++
+		}
+	}
+	return row
+}
+
+// renderFormulas draws the formulas one under another as a PNG image, black on white
+func renderFormulas(formulas []string) ([]byte, error) {
+	if len(formulas) > maxFormulasPerImage {
+		return nil, errFormulaTooLarge
+	}
+	for _, formula := range formulas {
+		if len([]rune(formula)) > maxFormulaLength {
+			return nil, errFormulaTooLarge
+		}
+	}
+
+	var boxes []mathBox
+	width, height := 0.0, 0.0
+	for _, formula := range formulas {
+		box := layoutFormula(formula)
+		boxes = append(boxes, box)
+		width = math.Max(width, box.width())
+		height += box.ascent() + box.descent()
+	}
+	height += float64(len(boxes)-1) * formulaPadding
+
+	imgWidth := int(math.Ceil(width)) + 2*formulaPadding
+	imgHeight := int(math.Ceil(height)) + 2*formulaPadding
+	if minHeight := imgWidth/photoMaxAspectRatio + 1; imgHeight < minHeight {
+		imgHeight = minHeight
+	}
+	if imgWidth+imgHeight > photoMaxDimensions {
+		return nil, errFormulaTooLarge
+	}
+
+	canvas := image.NewAlpha(image.Rect(0, 0, imgWidth, imgHeight))
+	y := float64(imgHeight)/2 - height/2
+	for _, box := range boxes {
+		box.draw(canvas, formulaPadding, y+box.ascent())
+		y += box.ascent() + box.descent() + formulaPadding
+	}
+
+	img := image.NewGray(canvas.Bounds())
+	for i, alpha := range canvas.Pix {
+		img.Pix[i] = 255 - alpha
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formulaCache keeps rendered formulas, and the telegram file IDs of those already sent
+type formulaCache struct {
+	mu      sync.Mutex
+	images  map[string][]byte
+	fileIDs map[string]string
+}
+
+var renderedFormulas = &formulaCache{
+	images:  make(map[string][]byte),
+	fileIDs: make(map[string]string),
+}
+
+func (cache *formulaCache) image(key string, formulas []string) ([]byte, error) {
+	cache.mu.Lock()
+	img, ok := cache.images[key]
+	cache.mu.Unlock()
+	if ok {
+		return img, nil
+	}
+
+	// drawn without holding the cache, so that formulas are drawn side by side
+	img, err := renderFormulas(formulas)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	// any rendered formula is dropped once the cache is full
+	if len(cache.images) >= formulaCacheSize {
+		for oldKey := range cache.images {
+			delete(cache.images, oldKey)
+			break
+		}
+	}
+	cache.images[key] = img
+	return img, nil
+}
+
+func (cache *formulaCache) fileID(key string) (string, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	fileID, ok := cache.fileIDs[key]
+	return fileID, ok
+}
+
+func (cache *formulaCache) setFileID(key string, fileID string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.fileIDs) >= formulaCacheSize*10 {
+		for oldKey := range cache.fileIDs {
+			delete(cache.fileIDs, oldKey)
+			break
+		}
+	}
+	cache.fileIDs[key] = fileID
+}
+
+// formulaRenderSlots limits how many formulas are drawn at once
+var formulaRenderSlots = make(chan struct{}, formulaRenderers)
+
+// sendFormulas sends the formulas in the text rendered as an image, if it has any. They are drawn on the chat's
+// queue, so the image comes before the messages sent to the chat after it
+func sendFormulas(chatID int64, text string, bot *tgbotapi.BotAPI) {
+	formulas := mathSegments(text)
+	if len(formulas) == 0 {
+		return
+	}
+
+	queueJob(bot, chatID, func(worker *tgbotapi.BotAPI) {
+		formulaRenderSlots <- struct{}{}
+		defer func() { <-formulaRenderSlots }()
+		sendFormulaImage(chatID, formulas, worker)
+	})
+}
+
+func sendFormulaImage(chatID int64, formulas []string, bot *tgbotapi.BotAPI) {
+	key := strings.Join(formulas, "\n")
+
+	// a formula already uploaded is sent again by its file ID
+	if fileID, ok := renderedFormulas.fileID(key); ok {
+		if _, err := bot.Send(tgbotapi.NewPhoto(chatID, tgbotapi.FileID(fileID))); err == nil {
+			return
+		}
+	}
+
+	img, err := renderedFormulas.image(key, formulas)
+	if errors.Is(err, errFormulaTooLarge) {
+		// the formulas stay readable as the $...$ text they were typed as
+		return
+	}
+	if err != nil {
+		log.Printf("An error has occurred trying to render formula: %s", err)
+		return
+	}
+
+	sentMsg, err := bot.Send(tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "formula.png", Bytes: img}))
+	if err != nil {
+		log.Printf("An error has occurred trying to send formula: %s", err)
+		return
+	}
+	if len(sentMsg.Photo) > 0 {
+		renderedFormulas.setFileID(key, sentMsg.Photo[len(sentMsg.Photo)-1].FileID)
+	}
+}
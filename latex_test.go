@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMathSegments(t *testing.T) {
+	formulas := mathSegments("Speed of light is $3*10^8$ m/s, or $$c = \\frac{1}{\\sqrt{\\mu_0 \\epsilon_0}}$$ and $unclosed")
+	expected := []string{"3*10^8", "c = \\frac{1}{\\sqrt{\\mu_0 \\epsilon_0}}"}
+	if !reflect.DeepEqual(formulas, expected) {
+		t.Errorf("Expected %v but got: %v", expected, formulas)
+	}
+
+	if formulas := mathSegments("costs 5 dollars"); len(formulas) != 0 {
+		t.Errorf("Expected no formulas but got: %v", formulas)
+	}
+
+	if formulas := mathSegments("costs $5 or $10?"); len(formulas) != 0 {
+		t.Errorf("Expected prices not to be formulas but got: %v", formulas)
+	}
+	if formulas := mathSegments("from $ 5 to $x^2$ and $y$2"); !reflect.DeepEqual(formulas, []string{"x^2"}) {
+		t.Errorf("Expected only x^2 but got: %v", formulas)
+	}
+}
+
+func TestLayoutFormula(t *testing.T) {
+	plain := layoutFormula("x")
+	withSup := layoutFormula("x^2")
+	if withSup.width() <= plain.width() || withSup.ascent() <= plain.ascent() {
+		t.Errorf("Expected a superscript to make the formula wider and taller")
+	}
+
+	fraction := layoutFormula("\\frac{a}{b}")
+	if fraction.ascent() <= plain.ascent() || fraction.descent() <= plain.descent() {
+		t.Errorf("Expected a fraction to reach above and below the baseline")
+	}
+
+	// unbalanced braces and unknown commands still lay out
+	if layoutFormula("}{\\unknown x^").width() <= 0 {
+		t.Errorf("Expected a malformed formula to still have a width")
+	}
+}
+
+func TestRenderFormulas(t *testing.T) {
+	data, err := renderFormulas([]string{"E = mc^2", "\\sqrt{2}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG but got: %s", err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() > photoMaxAspectRatio*bounds.Dy() {
+		t.Errorf("Expected a photo telegram accepts but got: %v", bounds)
+	}
+
+	dark := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+				dark++
+			}
+		}
+	}
+	if dark == 0 {
+		t.Errorf("Expected the formulas to be drawn")
+	}
+}
+
+func TestRenderFormulasTooLarge(t *testing.T) {
+	if _, err := renderFormulas([]string{strings.Repeat("x", maxFormulaLength+1)}); !errors.Is(err, errFormulaTooLarge) {
+		t.Errorf("Expected a formula too long to be refused but got: %v", err)
+	}
+
+	// within the length but wider than telegram accepts
+	if _, err := renderFormulas([]string{strings.Repeat("W", maxFormulaLength)}); !errors.Is(err, errFormulaTooLarge) {
+		t.Errorf("Expected a formula too wide for a photo to be refused but got: %v", err)
+	}
+}
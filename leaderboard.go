@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// points for each correct answer of a quiz tried on your own in a group, the same as the slowest correct live answer
+const attemptPointsPerAnswer = liveMaxPoints / 2
+
+// number of players shown on a leaderboard
+const leaderboardSize = 10
+
+// groupResult is one player's result of a quiz played in a group chat
+type groupResult struct {
+	userID  string
+	name    string
+	points  int
+	correct int
+	total   int
+}
+
+type leaderboardRow struct {
+	userID  string
+	name    string
+	points  int
+	correct int
+	total   int
+	quizzes int
+}
+
+func groupDoc(client *firestore.Client, chatID int64) *firestore.DocumentRef {
+	return client.Collection("GROUPS").Doc(fmt.Sprint(chatID))
+}
+
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat.IsGroup() || chat.IsSuperGroup()
+}
+
+// periodTotals add up a player's results over a period, named by its key, e.g. "2022-03" for a month
+type periodTotals struct {
+	key     string
+	points  int
+	correct int
+	total   int
+	quizzes int
+}
+
+// playerTotals are a player's running totals in a group chat, kept up to date as results are recorded so that
+// leaderboards never read the group's whole history
+type playerTotals struct {
+	name     string
+	allTime  periodTotals
+	week     periodTotals
+	lastWeek periodTotals // the week before week, for the weekly summary
+	month    periodTotals
+}
+
+func newPeriodTotals(data interface{}) periodTotals {
+	var totals periodTotals
+	fields, _ := data.(map[string]interface{})
+	totals.key, _ = fields["key"].(string)
+	if points, ok := fields["points"].(int64); ok {
+		totals.points = int(points)
+	}
+	if correct, ok := fields["correct"].(int64); ok {
+		totals.correct = int(correct)
+	}
+	if total, ok := fields["total"].(int64); ok {
+		totals.total = int(total)
+	}
+	if quizzes, ok := fields["quizzes"].(int64); ok {
+		totals.quizzes = int(quizzes)
+	}
+	return totals
+}
+
+func (totals periodTotals) data() map[string]interface{} {
+	return map[string]interface{}{
+		"key":     totals.key,
+		"points":  totals.points,
+		"correct": totals.correct,
+		"total":   totals.total,
+		"quizzes": totals.quizzes,
+	}
+}
+
+// add adds the result to the totals of the period, starting them again if the period has changed
+func (totals periodTotals) add(key string, result groupResult) periodTotals {
+	if totals.key != key {
+		totals = periodTotals{key: key}
+	}
+	totals.points += result.points
+	totals.correct += result.correct
+	totals.total += result.total
+	totals.quizzes++
+	return totals
+}
+
+func newPlayerTotals(data map[string]interface{}) playerTotals {
+	var totals playerTotals
+	totals.name, _ = data["name"].(string)
+	totals.allTime = newPeriodTotals(data["allTime"])
+	totals.week = newPeriodTotals(data["week"])
+	totals.lastWeek = newPeriodTotals(data["lastWeek"])
+	totals.month = newPeriodTotals(data["month"])
+	return totals
+}
+
+func (totals playerTotals) data() map[string]interface{} {
+	return map[string]interface{}{
+		"name":     totals.name,
+		"allTime":  totals.allTime.data(),
+		"week":     totals.week.data(),
+		"lastWeek": totals.lastWeek.data(),
+		"month":    totals.month.data(),
+	}
+}
+
+// add adds a result recorded at the time to the player's totals
+func (totals playerTotals) add(result groupResult, at time.Time) playerTotals {
+	weekKey := weekStart(at).Format(studyDayLayout)
+	if totals.week.key != "" && totals.week.key != weekKey {
+		totals.lastWeek = totals.week
+	}
+
+	// the latest name of the player is shown
+	totals.name = result.name
+	totals.allTime = totals.allTime.add("all", result)
+	totals.week = totals.week.add(weekKey, result)
+	totals.month = totals.month.add(at.Format("2006-01"), result)
+	return totals
+}
+
+// period returns the totals of the period named by its field, "allTime", "week", "lastWeek" or "month"
+func (totals playerTotals) period(field string) periodTotals {
+	switch field {
+	case "week":
+		return totals.week
+	case "lastWeek":
+		return totals.lastWeek
+	case "month":
+		return totals.month
+	}
+	return totals.allTime
+}
+
+// the running totals of the players of a group chat, by user ID
+func leaderboardCollection(client *firestore.Client, chatID int64) *firestore.CollectionRef {
+	return groupDoc(client, chatID).Collection("LEADERBOARD")
+}
+
+func newGroupResult(doc *firestore.DocumentSnapshot) (groupResult, time.Time) {
+	var result groupResult
+	result.userID, _ = doc.Data()["userID"].(string)
+	result.name, _ = doc.Data()["name"].(string)
+	if points, ok := doc.Data()["points"].(int64); ok {
+		result.points = int(points)
+	}
+	if correct, ok := doc.Data()["correct"].(int64); ok {
+		result.correct = int(correct)
+	}
+	if total, ok := doc.Data()["total"].(int64); ok {
+		result.total = int(total)
+	}
+	at, _ := doc.Data()["at"].(time.Time)
+	return result, at
+}
+
+// updateLeaderboardTotals adds the results to the players' running totals
+func updateLeaderboardTotals(tx *firestore.Transaction, client *firestore.Client, chatID int64, results []groupResult, at time.Time) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	var refs []*firestore.DocumentRef
+	for _, result := range results {
+		refs = append(refs, leaderboardCollection(client, chatID).Doc(result.userID))
+	}
+	docs, err := tx.GetAll(refs)
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[string]playerTotals)
+	for _, doc := range docs {
+		totals[doc.Ref.ID] = newPlayerTotals(doc.Data())
+	}
+	for _, result := range results {
+		totals[result.userID] = totals[result.userID].add(result, at)
+	}
+	for userID, player := range totals {
+		if err := tx.Set(leaderboardCollection(client, chatID).Doc(userID), player.data()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordGroupResults saves the results of a quiz played in a group chat and adds them to its leaderboards
+func recordGroupResults(ctx context.Context, client *firestore.Client, chatID int64, quizName string, results []groupResult) error {
+	now := time.Now()
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := updateLeaderboardTotals(tx, client, chatID, results, now); err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			err := tx.Create(groupDoc(client, chatID).Collection("RESULTS").NewDoc(), map[string]interface{}{
+				"userID":   result.userID,
+				"name":     result.name,
+				"quizName": quizName,
+				"points":   result.points,
+				"correct":  result.correct,
+				"total":    result.total,
+				"at":       now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// leaderboardPeriod returns the totals field and period key of a leaderboard, and its title
+func leaderboardPeriod(period string, now time.Time) (string, string, string, bool) {
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "", "weekly", "week":
+		return "week", weekStart(now).Format(studyDayLayout), "this week", true
+	case "monthly", "month":
+		return "month", now.Format("2006-01"), "this month", true
+	case "all", "all-time", "alltime":
+		return "allTime", "all", "all time", true
+	}
+	return "", "", "", false
+}
+
+// weekStart returns midnight of the Monday starting the week of t
+func weekStart(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// loadLeaderboard returns the players of the group chat with results in the period, highest points first. Only
+// the players' running totals are read, one document each
+func loadLeaderboard(ctx context.Context, client *firestore.Client, chatID int64, field string, key string) ([]leaderboardRow, error) {
+	query := leaderboardCollection(client, chatID).Where(field+".key", "==", key)
+	if field == "allTime" {
+		query = leaderboardCollection(client, chatID).OrderBy("allTime.points", firestore.Desc).Limit(leaderboardSize)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []leaderboardRow
+	for _, doc := range docs {
+		player := newPlayerTotals(doc.Data())
+		rows = append(rows, newLeaderboardRow(doc.Ref.ID, player.name, player.period(field)))
+	}
+	sortLeaderboard(rows)
+	return rows, nil
+}
+
+func newLeaderboardRow(userID string, name string, totals periodTotals) leaderboardRow {
+	return leaderboardRow{
+		userID:  userID,
+		name:    name,
+		points:  totals.points,
+		correct: totals.correct,
+		total:   totals.total,
+		quizzes: totals.quizzes,
+	}
+}
+
+// sortLeaderboard puts the highest points first
+func sortLeaderboard(rows []leaderboardRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].points != rows[j].points {
+			return rows[i].points > rows[j].points
+		}
+		return rows[i].name < rows[j].name
+	})
+}
+
+func formatLeaderboard(chatID int64, rows []leaderboardRow) string {
+	var text string
+	for i, row := range rows {
+		if i == leaderboardSize {
+			break
+		}
+		text += tr(chatID, "%d. <strong>%s</strong> - ", i+1, escapeHTML(row.name)) +
+			trn(chatID, row.points, "%d point", "%d points", row.points) +
+			trn(chatID, row.quizzes, " (%d/%d correct, %d quiz)\n", " (%d/%d correct, %d quizzes)\n", row.correct, row.total, row.quizzes)
+	}
+	return text
+}
+
+func sendLeaderboard(ctx context.Context, client *firestore.Client, chatID int64, bot *tgbotapi.BotAPI, period string) {
+	msg := tgbotapi.NewMessage(chatID, "")
+	msg.ParseMode = "HTML"
+
+	now := time.Now()
+	field, key, title, ok := leaderboardPeriod(period, now)
+	if !ok {
+		msg.Text = tr(chatID, "Please choose <strong>weekly</strong>, <strong>monthly</strong> or <strong>all</strong>, e.g. <strong>/leaderboard monthly</strong>")
+	} else if rows, err := loadLeaderboard(ctx, client, chatID, field, key); err != nil {
+		log.Printf("An error has occurred trying to load leaderboard: %s", err)
+		msg.Text = tr(chatID, "Sorry, the leaderboard could not be loaded. Please try again.")
+	} else if len(rows) == 0 {
+		msg.Text = tr(chatID, "No quizzes have been played in this chat %s. Start one with /host_quiz quiz_name", tr(chatID, title))
+	} else {
+		msg.Text = tr(chatID, "<strong>Leaderboard for %s</strong>\n", escapeHTML(tr(chatID, title))) + formatLeaderboard(chatID, rows)
+	}
+
+	queueMessage(bot, msg.ChatID, msg)
+}
+
+// setWeeklySummary turns the group's weekly summary post on or off
+func setWeeklySummary(ctx context.Context, client *firestore.Client, chatID int64, enabled bool) error {
+	_, err := groupDoc(client, chatID).Set(ctx, map[string]interface{}{
+		"weeklySummary": enabled,
+		// a summary turned on now starts with the current week
+		"lastSummary": time.Now(),
+	}, firestore.MergeAll)
+	return err
+}
+
+// claimWeeklySummary marks the group's summary of last week as posted, returning false if it already was
+func claimWeeklySummary(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, week time.Time) (bool, error) {
+	claimed := false
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		lastSummary, _ := doc.Data()["lastSummary"].(time.Time)
+		if enabled, _ := doc.Data()["weeklySummary"].(bool); !enabled || !lastSummary.Before(week) {
+			claimed = false
+			return nil
+		}
+
+		claimed = true
+		return tx.Update(ref, []firestore.Update{{Path: "lastSummary", Value: time.Now()}})
+	})
+	return claimed, err
+}
+
+// loadLastWeekLeaderboard returns the players of the group chat with results in the week before week, which
+// players who have played since keep as their last week
+func loadLastWeekLeaderboard(ctx context.Context, client *firestore.Client, chatID int64, week time.Time) ([]leaderboardRow, error) {
+	lastWeek, err := loadLeaderboard(ctx, client, chatID, "lastWeek", week.AddDate(0, 0, -7).Format(studyDayLayout))
+	if err != nil {
+		return nil, err
+	}
+	notPlayedSince, err := loadLeaderboard(ctx, client, chatID, "week", week.AddDate(0, 0, -7).Format(studyDayLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	rows := append(lastWeek, notPlayedSince...)
+	sortLeaderboard(rows)
+	return rows, nil
+}
+
+// postWeeklySummaries posts last week's leaderboard in every group that turned the summary on and has not had it yet
+func postWeeklySummaries(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, now time.Time) {
+	week := weekStart(now)
+
+	docs, err := client.Collection("GROUPS").Where("weeklySummary", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load weekly summaries: %s", err)
+		return
+	}
+
+	for _, doc := range docs {
+		if lastSummary, _ := doc.Data()["lastSummary"].(time.Time); !lastSummary.Before(week) {
+			continue
+		}
+		chatID, err := strconv.ParseInt(doc.Ref.ID, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// the summary is only claimed once its leaderboard has loaded, so a failed load is tried again next hour
+		rows, err := loadLastWeekLeaderboard(ctx, client, chatID, week)
+		if err != nil {
+			log.Printf("An error has occurred trying to load weekly summary: %s", err)
+			continue
+		}
+		claimed, err := claimWeeklySummary(ctx, client, doc.Ref, week)
+		if err != nil {
+			log.Printf("An error has occurred trying to claim weekly summary: %s", err)
+			continue
+		}
+		if !claimed || len(rows) == 0 {
+			continue
+		}
+		loadChatLanguage(ctx, client, chatID, "")
+
+		msg := tgbotapi.NewMessage(chatID, "")
+		msg.ParseMode = "HTML"
+		msg.Text = tr(chatID, "<strong>Weekly summary</strong>\n") +
+			trn(chatID, rows[0].points, "🏆 %s is last week's top scorer with %d point!\n\n", "🏆 %s is last week's top scorer with %d points!\n\n",
+				escapeHTML(rows[0].name), rows[0].points) +
+			formatLeaderboard(chatID, rows)
+
+		queueMessage(bot, msg.ChatID, msg)
+	}
+}
+
+// isGroupAdmin reports whether the user is an administrator of the group chat
+func isGroupAdmin(bot *tgbotapi.BotAPI, chatID int64, userID int64) bool {
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		log.Printf("An error has occurred trying to check group admin: %s", err)
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator()
+}
+
+// weeklySummaryEnabled reports whether the group has turned on its weekly summary
+func weeklySummaryEnabled(ctx context.Context, client *firestore.Client, chatID int64) (bool, error) {
+	doc, err := groupDoc(client, chatID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	enabled, _ := doc.Data()["weeklySummary"].(bool)
+	return enabled, nil
+}
+
+// finishLiveGame posts the final scoreboard of a live quiz, and adds its results to the group's leaderboards,
+// or to the host's quiz history when played alone in a private chat
+func finishLiveGame(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, game *liveGame) {
+	game.sendFinalScoreboard(bot)
+
+	results := game.results()
+	if game.chatID < 0 {
+		if len(results) > 0 {
+			if err := recordGroupResults(ctx, client, game.chatID, game.quizName, results); err != nil {
+				log.Printf("An error has occurred trying to record live quiz results: %s", err)
+			}
+		}
+
+		// playing in a group counts towards each player's streak, which is not posted to the group
+		for _, result := range results {
+			if result.total > 0 {
+				if _, err := recordStudyDay(ctx, client, result.userID); err != nil {
+					log.Printf("An error has occurred trying to update streak: %s", err)
+				}
+			}
+		}
+		return
+	}
+
+	for _, result := range results {
+		if result.userID == game.hostID && result.total > 0 {
+			if err := recordQuizAttempt(ctx, client, game.hostID, game.quizName, result.correct, result.total); err != nil {
+				log.Printf("An error has occurred trying to update score to firebase: %s", err)
+			}
+			if streakText := recordStudyDayText(ctx, client, game.chatID, game.hostID); streakText != "" {
+				sendSimpleMsg(game.chatID, streakText, bot)
+			}
+		}
+	}
+}
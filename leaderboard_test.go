@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekStart(t *testing.T) {
+	sunday := time.Date(2022, time.March, 13, 18, 30, 0, 0, time.UTC)
+	if start := weekStart(sunday); !start.Equal(time.Date(2022, time.March, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected Monday 7 March but got: " + start.String())
+	}
+
+	monday := time.Date(2022, time.March, 14, 0, 0, 0, 0, time.UTC)
+	if start := weekStart(monday); !start.Equal(monday) {
+		t.Error("Expected Monday 14 March but got: " + start.String())
+	}
+}
+
+func TestPlayerTotals(t *testing.T) {
+	monday := time.Date(2022, time.March, 14, 9, 0, 0, 0, time.UTC)
+	var totals playerTotals
+	totals = totals.add(groupResult{userID: "1", name: "@a", points: 500, correct: 1, total: 2}, monday.AddDate(0, 0, -1))
+	totals = totals.add(groupResult{userID: "1", name: "@a", points: 600, correct: 1, total: 1}, monday)
+
+	if totals.allTime.points != 1100 || totals.allTime.quizzes != 2 || totals.allTime.total != 3 {
+		t.Errorf("Expected 1100 points over 2 quizzes of all time but got: %v", totals.allTime)
+	}
+	if totals.week.key != "2022-03-14" || totals.week.points != 600 || totals.week.quizzes != 1 {
+		t.Errorf("Expected 600 points this week but got: %v", totals.week)
+	}
+	if totals.lastWeek.key != "2022-03-07" || totals.lastWeek.points != 500 {
+		t.Errorf("Expected 500 points last week but got: %v", totals.lastWeek)
+	}
+	if totals.month.key != "2022-03" || totals.month.points != 1100 {
+		t.Errorf("Expected 1100 points this month but got: %v", totals.month)
+	}
+
+	if saved := newPlayerTotals(firestoreData(totals.data())); saved != totals {
+		t.Errorf("Expected the totals to be saved and loaded unchanged but got: %v", saved)
+	}
+}
+
+func TestSortLeaderboard(t *testing.T) {
+	rows := []leaderboardRow{{name: "@b", points: 800}, {name: "@a", points: 1100}, {name: "@c", points: 800}}
+	sortLeaderboard(rows)
+
+	if rows[0].name != "@a" || rows[1].name != "@b" || rows[2].name != "@c" {
+		t.Errorf("Expected highest points first, then by name, but got: %v", rows)
+	}
+}
+
+// firestoreData stores ints as int64, the way firestore returns them
+func firestoreData(data map[string]interface{}) map[string]interface{} {
+	stored := make(map[string]interface{})
+	for key, value := range data {
+		switch value := value.(type) {
+		case int:
+			stored[key] = int64(value)
+		case map[string]interface{}:
+			stored[key] = firestoreData(value)
+		default:
+			stored[key] = value
+		}
+	}
+	return stored
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// time the players of a live quiz have to answer each question
+const liveAnswerTime = 20 * time.Second
+
+// most answer options shown as buttons for each question of a live quiz
+const liveMaxOptions = 4
+
+// points for a correct answer, half of them awarded for speed
+const liveMaxPoints = 1000
+
+// telegram's limits on the length of poll questions and options
+const pollQuestionMaxLen = 300
+const pollOptionMaxLen = 100
+
+// liveTimeout is sent when the time to answer a question of a live quiz is up
+type liveTimeout struct {
+	game    *liveGame
+	qnIndex int
+}
+
+type liveResponse struct {
+	correct bool
+	points  int
+}
+
+// liveGame is a quiz played by every member of a group chat at once
+type liveGame struct {
+	chatID    int64
+	hostID    string
+	quizName  string
+	questions []string
+	answers   map[string]string
+	media     map[string]questionAttachments
+
+	qnIndex    int // index of the question being answered, -1 before the first
+	qnMsgID    int
+	qnSentAt   time.Time
+	options    []string
+	responses  map[string]liveResponse
+	scores     map[string]int
+	correct    map[string]int
+	names      map[string]string
+	answerTime time.Duration
+
+	// questions are sent as native quiz polls, answered with PollAnswer updates
+	pollMode bool
+	pollID   string
+}
+
+func newLiveGame(chatID int64, hostID string, quizName string, quizData map[string]interface{}) *liveGame {
+	game := &liveGame{
+		chatID:     chatID,
+		hostID:     hostID,
+		quizName:   quizName,
+		answers:    quizQuestions(quizData),
+		media:      quizAttachments(quizData),
+		qnIndex:    -1,
+		scores:     make(map[string]int),
+		correct:    make(map[string]int),
+		names:      make(map[string]string),
+		answerTime: liveAnswerTime,
+	}
+
+	for question := range game.answers {
+		game.questions = append(game.questions, question)
+	}
+	rand.Shuffle(len(game.questions), func(i, j int) {
+		game.questions[i], game.questions[j] = game.questions[j], game.questions[i]
+	})
+
+	return game
+}
+
+func liveUserName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return user.FirstName
+}
+
+// liveOptions returns the correct answer shuffled among other answers of the quiz
+func liveOptions(correct string, answers map[string]string) []string {
+	options := []string{correct}
+	for _, answer := range answers {
+		if len(options) == liveMaxOptions {
+			break
+		}
+		if !containsString(options, answer) {
+			options = append(options, answer)
+		}
+	}
+
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+	return options
+}
+
+// normalizeAnswer ignores case, spacing and punctuation when comparing typed answers
+func normalizeAnswer(answer string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(answer), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// livePoints awards half the points for a correct answer, and the other half the faster it was
+func livePoints(elapsed time.Duration, answerTime time.Duration) int {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > answerTime {
+		return 0
+	}
+	return liveMaxPoints/2 + int(float64(liveMaxPoints/2)*float64(answerTime-elapsed)/float64(answerTime))
+}
+
+func (game *liveGame) question() string {
+	return game.questions[game.qnIndex]
+}
+
+func (game *liveGame) accepting() bool {
+	return game.qnIndex >= 0 && game.responses != nil
+}
+
+// nextQuestion posts the next question with its answer buttons, and starts the timer for answering it
+func (game *liveGame) nextQuestion(bot *tgbotapi.BotAPI, timeouts chan<- liveTimeout) bool {
+	game.qnIndex++
+	if game.qnIndex >= len(game.questions) {
+		return false
+	}
+
+	game.options = liveOptions(game.answers[game.question()], game.answers)
+	game.responses = make(map[string]liveResponse)
+
+	// the question's media comes first, as polls and buttons cannot carry it
+	sendMedia(game.chatID, game.media[game.question()].question, bot)
+	if game.pollMode {
+		game.sendQuestionPoll(bot)
+	} else {
+		game.sendQuestionMessage(bot)
+	}
+	game.qnSentAt = time.Now()
+
+	timeout := liveTimeout{game: game, qnIndex: game.qnIndex}
+	time.AfterFunc(game.answerTime, func() {
+		timeouts <- timeout
+	})
+
+	return true
+}
+
+func (game *liveGame) sendQuestionMessage(bot *tgbotapi.BotAPI) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, option := range game.options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option, "live:"+strconv.Itoa(game.qnIndex)+":"+strconv.Itoa(i)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(game.chatID, "")
+	msg.ParseMode = "HTML"
+	msg.Text = tr(game.chatID, "<strong>Question %d/%d</strong>\n%s\n\n<i>Tap an answer or reply with it within %d seconds!</i>",
+		game.qnIndex+1, len(game.questions), escapeHTML(game.question()), int(game.answerTime.Seconds()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	game.qnMsgID = sendPrompt(msg, bot)
+}
+
+// truncateText shortens text to at most maxLen characters for telegram's limits
+func truncateText(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen-1]) + "…"
+}
+
+// sendQuestionPoll sends the question as a native quiz poll that closes when the time to answer is up
+func (game *liveGame) sendQuestionPoll(bot *tgbotapi.BotAPI) {
+	// quiz polls need at least two options
+	if len(game.options) < 2 {
+		game.options = append(game.options, tr(game.chatID, "None of these"))
+	}
+
+	var options []string
+	correctOption := 0
+	for i, option := range game.options {
+		options = append(options, truncateText(option, pollOptionMaxLen))
+		if option == game.answers[game.question()] {
+			correctOption = i
+		}
+	}
+
+	poll := tgbotapi.NewPoll(game.chatID, truncateText(fmt.Sprintf("%d/%d. %s", game.qnIndex+1, len(game.questions), game.question()), pollQuestionMaxLen), options...)
+	poll.Type = "quiz"
+	// answers of anonymous polls are not sent to the bot
+	poll.IsAnonymous = false
+	poll.CorrectOptionID = int64(correctOption)
+	poll.Explanation = truncateText(tr(game.chatID, "Answer: %s", game.answers[game.question()]), 200)
+	poll.OpenPeriod = int(game.answerTime.Seconds())
+
+	sentMsg, err := bot.Send(poll)
+	if err != nil {
+		log.Printf("An error has occurred trying to send message: %s", err)
+	}
+	game.qnMsgID = sentMsg.MessageID
+	if sentMsg.Poll != nil {
+		game.pollID = sentMsg.Poll.ID
+	}
+}
+
+// recordAnswer keeps the first answer of each player to the current question
+func (game *liveGame) recordAnswer(user *tgbotapi.User, correct bool) bool {
+	userID := fmt.Sprint(user.ID)
+	if !game.accepting() {
+		return false
+	}
+	if _, answered := game.responses[userID]; answered {
+		return false
+	}
+
+	response := liveResponse{correct: correct}
+	if correct {
+		response.points = livePoints(time.Since(game.qnSentAt), game.answerTime)
+		game.correct[userID]++
+	}
+
+	game.responses[userID] = response
+	game.scores[userID] += response.points
+	game.names[userID] = liveUserName(user)
+	return true
+}
+
+// handleLiveCallback records an answer given with a button, returning the text shown to the player
+func (game *liveGame) handleLiveCallback(query *tgbotapi.CallbackQuery) string {
+	dataParts := strings.Split(query.Data, ":")
+	if len(dataParts) != 3 {
+		return ""
+	}
+	qnIndex, err1 := strconv.Atoi(dataParts[1])
+	option, err2 := strconv.Atoi(dataParts[2])
+	if err1 != nil || err2 != nil || qnIndex != game.qnIndex || option < 0 || option >= len(game.options) {
+		return tr(game.chatID, "This question is closed")
+	}
+
+	if !game.recordAnswer(query.From, game.options[option] == game.answers[game.question()]) {
+		return tr(game.chatID, "You have already answered this question")
+	}
+	return tr(game.chatID, "Answer recorded!")
+}
+
+// handlePollAnswer records an answer given in the question's quiz poll
+func (game *liveGame) handlePollAnswer(answer *tgbotapi.PollAnswer) bool {
+	if answer.PollID != game.pollID || len(answer.OptionIDs) != 1 {
+		return false
+	}
+
+	option := answer.OptionIDs[0]
+	if option < 0 || option >= len(game.options) {
+		return false
+	}
+	user := answer.User
+	return game.recordAnswer(&user, game.options[option] == game.answers[game.question()])
+}
+
+// handleLiveReply records a typed answer, returning whether the message was an answer. Only replies to the
+// question are answers, so that the group can chat meanwhile
+func (game *liveGame) handleLiveReply(message *tgbotapi.Message) bool {
+	if !game.accepting() || game.pollMode || message.IsCommand() || message.Text == "" {
+		return false
+	}
+	if message.ReplyToMessage == nil || message.ReplyToMessage.MessageID != game.qnMsgID {
+		return false
+	}
+
+	return game.recordAnswer(message.From, normalizeAnswer(message.Text) == normalizeAnswer(game.answers[game.question()]))
+}
+
+// scoreboard lists the players from highest to lowest score
+func (game *liveGame) scoreboard() string {
+	var userIDs []string
+	for userID := range game.scores {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		if game.scores[userIDs[i]] != game.scores[userIDs[j]] {
+			return game.scores[userIDs[i]] > game.scores[userIDs[j]]
+		}
+		return game.names[userIDs[i]] < game.names[userIDs[j]]
+	})
+
+	if len(userIDs) == 0 {
+		return tr(game.chatID, "No one has answered yet.")
+	}
+
+	var text string
+	for i, userID := range userIDs {
+		text += fmt.Sprintf("%d. %s - %d\n", i+1, escapeHTML(game.names[userID]), game.scores[userID])
+	}
+	return text
+}
+
+// closeQuestion reveals the answer to the current question and posts the scoreboard
+func (game *liveGame) closeQuestion(bot *tgbotapi.BotAPI) {
+	answer := game.answers[game.question()]
+
+	numCorrect := 0
+	for _, response := range game.responses {
+		if response.correct {
+			numCorrect++
+		}
+	}
+
+	if game.pollMode {
+		// the poll is usually closed by its open period already
+		queueMessage(bot, game.chatID, tgbotapi.NewStopPoll(game.chatID, game.qnMsgID))
+	} else {
+		edit := tgbotapi.NewEditMessageText(game.chatID, game.qnMsgID, tr(game.chatID, "Question %d/%d\n%s\n\nAnswer: %s",
+			game.qnIndex+1, len(game.questions), game.question(), answer))
+		queueMessage(bot, edit.ChatID, edit)
+	}
+	game.responses = nil
+
+	msg := tgbotapi.NewMessage(game.chatID, "")
+	msg.ParseMode = "HTML"
+	msg.Text = trn(game.chatID, numCorrect,
+		"Time's up! The answer was <strong>%s</strong>, %d player got it right.\n\n<strong>Scoreboard</strong>\n%s",
+		"Time's up! The answer was <strong>%s</strong>, %d players got it right.\n\n<strong>Scoreboard</strong>\n%s",
+		escapeHTML(answer), numCorrect, game.scoreboard())
+	queueMessage(bot, msg.ChatID, msg)
+}
+
+func (game *liveGame) sendFinalScoreboard(bot *tgbotapi.BotAPI) {
+	msg := tgbotapi.NewMessage(game.chatID, "")
+	msg.ParseMode = "HTML"
+	msg.Text = tr(game.chatID, "<strong>Live quiz %s is over!</strong>\n\n<strong>Final scoreboard</strong>\n%s", escapeHTML(game.quizName), game.scoreboard())
+
+	queueMessage(bot, msg.ChatID, msg)
+}
+
+// results returns each player's points and correct answers out of the questions asked, for the group's leaderboard
+func (game *liveGame) results() []groupResult {
+	numAsked := game.qnIndex + 1
+	if numAsked > len(game.questions) {
+		numAsked = len(game.questions)
+	}
+
+	var results []groupResult
+	for userID, points := range game.scores {
+		results = append(results, groupResult{
+			userID:  userID,
+			name:    game.names[userID],
+			points:  points,
+			correct: game.correct[userID],
+			total:   numAsked,
+		})
+	}
+	return results
+}
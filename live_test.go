@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestLivePoints(t *testing.T) {
+	if points := livePoints(0, 20*time.Second); points != liveMaxPoints {
+		t.Errorf("Expected %d for an instant answer but got: %d", liveMaxPoints, points)
+	}
+	if points := livePoints(20*time.Second, 20*time.Second); points != liveMaxPoints/2 {
+		t.Errorf("Expected %d for the slowest answer but got: %d", liveMaxPoints/2, points)
+	}
+	if points := livePoints(21*time.Second, 20*time.Second); points != 0 {
+		t.Errorf("Expected 0 for a late answer but got: %d", points)
+	}
+}
+
+func TestNormalizeAnswer(t *testing.T) {
+	if normalizeAnswer("  The Mitochondria! ") != normalizeAnswer("the mitochondria") {
+		t.Error("Expected case, spacing and punctuation to be ignored")
+	}
+}
+
+func TestLiveGameRecordsFirstAnswer(t *testing.T) {
+	game := newLiveGame(-1, "111", "demo", map[string]interface{}{"numQns": int64(1), "1+1?": "2"})
+	game.qnIndex = 0
+	game.qnSentAt = time.Now()
+	game.responses = make(map[string]liveResponse)
+
+	player := &tgbotapi.User{ID: 222, UserName: "player"}
+	if !game.recordAnswer(player, true) {
+		t.Error("Expected the first answer to be recorded")
+	}
+	if game.recordAnswer(player, false) {
+		t.Error("Expected a second answer to be ignored")
+	}
+	if game.scores["222"] <= liveMaxPoints/2 {
+		t.Errorf("Expected points for a fast correct answer but got: %d", game.scores["222"])
+	}
+}
+
+func TestHandleLiveReply(t *testing.T) {
+	game := newLiveGame(-1, "111", "demo", map[string]interface{}{"numQns": int64(1), "1+1?": "2"})
+	game.qnIndex = 0
+	game.qnMsgID = 50
+	game.qnSentAt = time.Now()
+	game.responses = make(map[string]liveResponse)
+
+	player := &tgbotapi.User{ID: 222, UserName: "player"}
+	chatter := &tgbotapi.Message{From: player, Text: "wait what", ReplyToMessage: &tgbotapi.Message{MessageID: 49}}
+	if game.handleLiveReply(chatter) || len(game.responses) != 0 {
+		t.Error("Expected a message that does not reply to the question to be ignored")
+	}
+	if game.handleLiveReply(&tgbotapi.Message{From: player, Text: "lol"}) || len(game.responses) != 0 {
+		t.Error("Expected a message that replies to nothing to be ignored")
+	}
+
+	answer := &tgbotapi.Message{From: player, Text: "2", ReplyToMessage: &tgbotapi.Message{MessageID: 50}}
+	if !game.handleLiveReply(answer) || !game.responses["222"].correct {
+		t.Error("Expected a reply to the question to be recorded as the answer")
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	if text := truncateText("short", 10); text != "short" {
+		t.Error("Expected short text to be unchanged but got: " + text)
+	}
+	if text := truncateText("ünïcödé text", 5); text != "ünïc…" {
+		t.Error("Expected ünïc… but got: " + text)
+	}
+}
+
+func TestHandlePollAnswer(t *testing.T) {
+	game := newLiveGame(111, "111", "demo", map[string]interface{}{"1+1?": "2"})
+	game.pollMode = true
+	game.pollID = "poll"
+	game.qnIndex = 0
+	game.qnSentAt = time.Now()
+	game.options = []string{"3", "2"}
+	game.responses = make(map[string]liveResponse)
+
+	if game.handlePollAnswer(&tgbotapi.PollAnswer{PollID: "other", User: tgbotapi.User{ID: 111}, OptionIDs: []int{1}}) {
+		t.Error("Expected answers to other polls to be ignored")
+	}
+	if !game.handlePollAnswer(&tgbotapi.PollAnswer{PollID: "poll", User: tgbotapi.User{ID: 111}, OptionIDs: []int{1}}) {
+		t.Error("Expected the answer to be recorded")
+	}
+	if game.correct["111"] != 1 {
+		t.Errorf("Expected 1 correct answer but got: %d", game.correct["111"])
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// questionMedia is a photo, document, audio or voice note attached to a question or answer,
+// sent again by its telegram file ID
+type questionMedia struct {
+	kind     string // "photo", "document", "audio" or "voice"
+	fileID   string
+	uniqueID string
+	blobPath string // copy in the media bucket, empty if there is none
+}
+
+// questionAttachments are the media of a question and of its answer, either of which may be empty
+type questionAttachments struct {
+	question questionMedia
+	answer   questionMedia
+}
+
+var mediaLabels = map[string]string{
+	"photo":    "📷 Photo",
+	"document": "📄 Document",
+	"audio":    "🎵 Audio",
+	"voice":    "🎤 Voice note",
+}
+
+// messageMedia returns the media sent in a message, the largest size of a photo
+func messageMedia(message *tgbotapi.Message) (questionMedia, bool) {
+	switch {
+	case len(message.Photo) > 0:
+		photo := message.Photo[len(message.Photo)-1]
+		return questionMedia{kind: "photo", fileID: photo.FileID, uniqueID: photo.FileUniqueID}, true
+	case message.Document != nil:
+		return questionMedia{kind: "document", fileID: message.Document.FileID, uniqueID: message.Document.FileUniqueID}, true
+	case message.Audio != nil:
+		return questionMedia{kind: "audio", fileID: message.Audio.FileID, uniqueID: message.Audio.FileUniqueID}, true
+	case message.Voice != nil:
+		return questionMedia{kind: "voice", fileID: message.Voice.FileID, uniqueID: message.Voice.FileUniqueID}, true
+	}
+	return questionMedia{}, false
+}
+
+// messageContent returns the text of a question or answer, which is the caption of media, or a label if it has none
+func messageContent(message *tgbotapi.Message) (string, questionMedia) {
+	media, hasMedia := messageMedia(message)
+	if !hasMedia {
+		return message.Text, media
+	}
+	if message.Caption != "" {
+		return message.Caption, media
+	}
+
+	// questions are stored by their text, so media without a caption is told apart by its file
+	uniqueID := media.uniqueID
+	if len(uniqueID) > 6 {
+		uniqueID = uniqueID[len(uniqueID)-6:]
+	}
+	return mediaLabels[media.kind] + " " + uniqueID, media
+}
+
+func (media questionMedia) data() map[string]interface{} {
+	return map[string]interface{}{
+		"kind":     media.kind,
+		"fileID":   media.fileID,
+		"uniqueID": media.uniqueID,
+		"blobPath": media.blobPath,
+	}
+}
+
+func newQuestionMedia(data interface{}) questionMedia {
+	var media questionMedia
+	if fields, ok := data.(map[string]interface{}); ok {
+		media.kind, _ = fields["kind"].(string)
+		media.fileID, _ = fields["fileID"].(string)
+		media.uniqueID, _ = fields["uniqueID"].(string)
+		media.blobPath, _ = fields["blobPath"].(string)
+	}
+	return media
+}
+
+func (attachments questionAttachments) data() map[string]interface{} {
+	data := make(map[string]interface{})
+	if attachments.question.fileID != "" {
+		data["question"] = attachments.question.data()
+	}
+	if attachments.answer.fileID != "" {
+		data["answer"] = attachments.answer.data()
+	}
+	return data
+}
+
+func (attachments questionAttachments) empty() bool {
+	return attachments.question.fileID == "" && attachments.answer.fileID == ""
+}
+
+// quizAttachments returns the media of a quiz's questions, kept in its "media" field by question
+func quizAttachments(quizData map[string]interface{}) map[string]questionAttachments {
+	attachments := make(map[string]questionAttachments)
+	mediaData, _ := quizData["media"].(map[string]interface{})
+	for question, data := range mediaData {
+		fields, _ := data.(map[string]interface{})
+		attachments[question] = questionAttachments{
+			question: newQuestionMedia(fields["question"]),
+			answer:   newQuestionMedia(fields["answer"]),
+		}
+	}
+	return attachments
+}
+
+// sendMedia sends a question's or answer's media on its own, before or after the question's text
+func sendMedia(chatID int64, media questionMedia, bot *tgbotapi.BotAPI) {
+	if media.fileID == "" {
+		return
+	}
+
+	file := tgbotapi.FileID(media.fileID)
+	var msg tgbotapi.Chattable
+	switch media.kind {
+	case "photo":
+		msg = tgbotapi.NewPhoto(chatID, file)
+	case "document":
+		msg = tgbotapi.NewDocument(chatID, file)
+	case "audio":
+		msg = tgbotapi.NewAudio(chatID, file)
+	case "voice":
+		msg = tgbotapi.NewVoice(chatID, file)
+	default:
+		return
+	}
+
+	queueMessage(bot, chatID, msg)
+}
+
+// copyMediaToBucket keeps a copy of each new attachment in the media bucket, in case telegram no longer has the file
+func copyMediaToBucket(ctx context.Context, bot *tgbotapi.BotAPI, bucket *storage.BucketHandle, ownerID string, attachments map[string]questionAttachments) {
+	if bucket == nil {
+		return
+	}
+
+	for question, attachment := range attachments {
+		for _, media := range []*questionMedia{&attachment.question, &attachment.answer} {
+			if media.fileID == "" || media.blobPath != "" {
+				continue
+			}
+
+			blobPath := "media/" + ownerID + "/" + media.uniqueID
+			if err := copyFileToBucket(ctx, bot, bucket, media.fileID, blobPath); err != nil {
+				log.Printf("An error has occurred trying to copy question media: %s", err)
+				continue
+			}
+			media.blobPath = blobPath
+		}
+		attachments[question] = attachment
+	}
+}
+
+func copyFileToBucket(ctx context.Context, bot *tgbotapi.BotAPI, bucket *storage.BucketHandle, fileID string, blobPath string) error {
+	fileURL, err := bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading file: %s", resp.Status)
+	}
+
+	writer := bucket.Object(blobPath).NewWriter(ctx)
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMessageContent(t *testing.T) {
+	text, media := messageContent(&tgbotapi.Message{Text: "capital of France?"})
+	if text != "capital of France?" || media.fileID != "" {
+		t.Errorf("Expected plain text without media but got: %q %+v", text, media)
+	}
+
+	photo := &tgbotapi.Message{
+		Photo: []tgbotapi.PhotoSize{
+			{FileID: "small", FileUniqueID: "AQADsmall"},
+			{FileID: "large", FileUniqueID: "AQADlarge1"},
+		},
+		Caption: "whose flag is this?",
+	}
+	text, media = messageContent(photo)
+	if text != "whose flag is this?" || media.kind != "photo" || media.fileID != "large" {
+		t.Errorf("Expected the caption and the largest photo but got: %q %+v", text, media)
+	}
+
+	photo.Caption = ""
+	if text, _ := messageContent(photo); text != "📷 Photo large1" {
+		t.Errorf("Expected a label for an uncaptioned photo but got: %q", text)
+	}
+
+	voice := &tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "voice", FileUniqueID: "v1"}}
+	if text, media := messageContent(voice); text != "🎤 Voice note v1" || media.kind != "voice" {
+		t.Errorf("Expected a voice note but got: %q %+v", text, media)
+	}
+}
+
+func TestQuizAttachments(t *testing.T) {
+	attachment := questionAttachments{answer: questionMedia{kind: "audio", fileID: "abc", uniqueID: "u1"}}
+	quizData := map[string]interface{}{
+		"numQns": int64(1),
+		"media":  map[string]interface{}{"which song?": attachment.data()},
+	}
+
+	attachments := quizAttachments(quizData)
+	if attachments["which song?"] != attachment {
+		t.Errorf("Expected the attachment to read back but got: %+v", attachments["which song?"])
+	}
+	if len(quizQuestions(quizData)) != 0 {
+		t.Errorf("Expected media not to be read as a question")
+	}
+}
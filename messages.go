@@ -0,0 +1,305 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// messageLimit is the most characters telegram accepts in one message
+const messageLimit = 4096
+
+// number of lines on each page of a listing, set from the config at startup
+var listPageSize = 20
+
+// messageLength counts characters the way telegram does, in UTF-16 code units. Tags are counted too,
+// so HTML text is never longer than it looks here
+func messageLength(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+// htmlTokens splits HTML text into tags, character references and single characters, none of which can be split
+func htmlTokens(text string) []string {
+	var tokens []string
+	for len(text) > 0 {
+		end := 0
+		switch text[0] {
+		case '<':
+			end = strings.IndexByte(text, '>') + 1
+		case '&':
+			// a lone & is an ordinary character
+			if end = strings.IndexByte(text, ';') + 1; end > 10 {
+				end = 0
+			}
+		}
+		if end <= 0 {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		tokens = append(tokens, text[:end])
+		text = text[end:]
+	}
+	return tokens
+}
+
+func isTag(token string) bool {
+	return len(token) > 2 && token[0] == '<'
+}
+
+// closingTags closes the open tags, innermost first
+func closingTags(open []string) string {
+	var tags string
+	for i := len(open) - 1; i >= 0; i-- {
+		name := strings.Fields(strings.Trim(open[i], "<>"))[0]
+		tags += "</" + name + ">"
+	}
+	return tags
+}
+
+// splitMessage splits HTML text into messages of at most limit characters, after a line where it can
+// or else after a word. Tags open where a message ends are closed in it and opened again in the next
+func splitMessage(text string, limit int) []string {
+	if messageLength(text) <= limit {
+		return []string{text}
+	}
+
+	tokens := htmlTokens(text)
+	var chunks []string
+	var open []string
+	for start := 0; start < len(tokens); {
+		prefix := strings.Join(open, "")
+		length := messageLength(prefix)
+		stack := append([]string(nil), open...)
+
+		lineCut, wordCut := -1, -1
+		var lineStack, wordStack []string
+		end := start
+		for ; end < len(tokens); end++ {
+			token := tokens[end]
+			next := stack
+			if isTag(token) && token[1] == '/' {
+				if len(stack) > 0 {
+					next = stack[:len(stack)-1]
+				}
+			} else if isTag(token) {
+				next = append(append([]string(nil), stack...), token)
+			}
+
+			if length+messageLength(token)+messageLength(closingTags(next)) > limit {
+				break
+			}
+			length += messageLength(token)
+			stack = next
+
+			if token == "\n" {
+				lineCut, lineStack = end+1, stack
+			} else if token == " " {
+				wordCut, wordStack = end+1, stack
+			}
+		}
+
+		cut, cutStack := end, stack
+		if end < len(tokens) && lineCut > start {
+			cut, cutStack = lineCut, lineStack
+		} else if end < len(tokens) && wordCut > start {
+			cut, cutStack = wordCut, wordStack
+		}
+		if cut == start && len(open) > 0 {
+			// the open tags alone fill the message, so drop them rather than never getting further
+			open = nil
+			continue
+		} else if cut == start {
+			cut = start + 1
+		}
+
+		chunks = append(chunks, prefix+strings.Join(tokens[start:cut], "")+closingTags(cutStack))
+		open = cutStack
+		start = cut
+	}
+	return chunks
+}
+
+// sendLongMessage sends the message in as many parts as it needs, with its buttons on the last one,
+// and returns the ID of the last one
+func sendLongMessage(msg tgbotapi.MessageConfig, bot *tgbotapi.BotAPI) int {
+	chunks := splitMessage(msg.Text, messageLimit)
+	for _, chunk := range chunks[:len(chunks)-1] {
+		part := tgbotapi.NewMessage(msg.ChatID, chunk)
+		part.ParseMode = msg.ParseMode
+		queueMessage(bot, part.ChatID, part)
+	}
+
+	msg.Text = chunks[len(chunks)-1]
+	return sendPrompt(msg, bot)
+}
+
+// editLongMessage edits a message in place, sending what does not fit in it as new messages.
+// The buttons go on the last message, whose ID is returned
+func editLongMessage(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup, bot *tgbotapi.BotAPI) int {
+	chunks := splitMessage(text, messageLimit)
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, chunks[0])
+	edit.ParseMode = "HTML"
+	if len(chunks) == 1 {
+		edit.ReplyMarkup = keyboard
+	}
+	queueMessage(bot, edit.ChatID, edit)
+	if len(chunks) == 1 {
+		return messageID
+	}
+
+	for i, chunk := range chunks[1:] {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = "HTML"
+		if i == len(chunks)-2 && keyboard != nil {
+			msg.ReplyMarkup = *keyboard
+		}
+		messageID = sendPrompt(msg, bot)
+	}
+	return messageID
+}
+
+// pagedList is a listing sent as one message, with Prev and Next buttons that turn its pages in place
+type pagedList struct {
+	chatID  int64 // whose language the page buttons are in
+	header  string
+	footer  string
+	pages   []string
+	page    int
+	buttons [][]tgbotapi.InlineKeyboardButton // rows shown below the page buttons on every page
+}
+
+func newPagedList(chatID int64, header string, lines []string, footer string) *pagedList {
+	list := &pagedList{chatID: chatID, header: header, footer: footer}
+
+	// room for the page number
+	room := messageLimit - messageLength(header) - messageLength(footer) - 20
+
+	var page string
+	numLines := 0
+	for _, line := range lines {
+		for _, part := range splitMessage(line, room-1) {
+			if numLines == listPageSize || (numLines > 0 && messageLength(page)+messageLength(part)+1 > room) {
+				list.pages = append(list.pages, page)
+				page = ""
+				numLines = 0
+			}
+			page += part + "\n"
+			numLines++
+		}
+	}
+	list.pages = append(list.pages, page)
+
+	return list
+}
+
+func (list *pagedList) text() string {
+	text := list.header + list.pages[list.page]
+	if len(list.pages) > 1 {
+		text += tr(list.chatID, "<i>Page %d/%d</i>\n", list.page+1, len(list.pages))
+	}
+	return text + list.footer
+}
+
+func (list *pagedList) keyboard() *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	var navRow []tgbotapi.InlineKeyboardButton
+	if list.page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(tr(list.chatID, "« Prev"), "lpage:"+strconv.Itoa(list.page-1)))
+	}
+	if list.page < len(list.pages)-1 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(tr(list.chatID, "Next »"), "lpage:"+strconv.Itoa(list.page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows, list.buttons...)
+
+	if len(rows) == 0 {
+		return nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// sendPagedList sends the first page of the list and returns the ID of its message
+func sendPagedList(chatID int64, bot *tgbotapi.BotAPI, list *pagedList) int {
+	msg := tgbotapi.NewMessage(chatID, list.text())
+	msg.ParseMode = "HTML"
+	if keyboard := list.keyboard(); keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+
+	return sendPrompt(msg, bot)
+}
+
+// handlePagedListCallback turns the page of the list
+func handlePagedListCallback(query *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI, list *pagedList) {
+	_, value := parseCallbackData(query.Data)
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= len(list.pages) || index == list.page {
+		return
+	}
+
+	list.page = index
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, list.text())
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = list.keyboard()
+	queueMessage(bot, edit.ChatID, edit)
+}
+
+// messages with buttons are forgotten once they are this old, or the oldest once there are too many
+const (
+	openMessageAge  = 24 * time.Hour
+	maxOpenMessages = 1000
+)
+
+// openMessages remembers what the buttons of sent messages act on, by chat and message ID
+type openMessages[V any] struct {
+	entries map[string]openMessage[V]
+}
+
+type openMessage[V any] struct {
+	value V
+	sent  time.Time
+}
+
+func newOpenMessages[V any]() *openMessages[V] {
+	return &openMessages[V]{entries: make(map[string]openMessage[V])}
+}
+
+// add remembers the message, forgetting expired ones and the oldest if there are too many
+func (messages *openMessages[V]) add(key string, value V, now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for id, message := range messages.entries {
+		if now.Sub(message.sent) > openMessageAge {
+			delete(messages.entries, id)
+		} else if oldestKey == "" || message.sent.Before(oldest) {
+			oldestKey, oldest = id, message.sent
+		}
+	}
+	if len(messages.entries) >= maxOpenMessages {
+		delete(messages.entries, oldestKey)
+	}
+
+	messages.entries[key] = openMessage[V]{value: value, sent: now}
+}
+
+// get returns what the buttons of the message act on, unless it has been forgotten
+func (messages *openMessages[V]) get(key string, now time.Time) (V, bool) {
+	message, found := messages.entries[key]
+	if found && now.Sub(message.sent) > openMessageAge {
+		delete(messages.entries, key)
+		found = false
+	}
+	return message.value, found
+}
+
+func (messages *openMessages[V]) remove(key string) {
+	delete(messages.entries, key)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitMessage(t *testing.T) {
+	if chunks := splitMessage("short <b>text</b>", 100); len(chunks) != 1 || chunks[0] != "short <b>text</b>" {
+		t.Errorf("Expected short text unchanged but got: %q", chunks)
+	}
+
+	chunks := splitMessage("first line\nsecond line\nthird line", 25)
+	if len(chunks) != 2 || chunks[0] != "first line\nsecond line\n" || chunks[1] != "third line" {
+		t.Errorf("Expected a split after a line but got: %q", chunks)
+	}
+
+	chunks = splitMessage("one two three four", 10)
+	if strings.Join(chunks, "") != "one two three four" {
+		t.Errorf("Expected no text lost splitting between words but got: %q", chunks)
+	}
+	for _, chunk := range chunks {
+		if messageLength(chunk) > 10 {
+			t.Errorf("Expected parts of at most 10 characters but got: %q", chunk)
+		}
+	}
+
+	// tags open at a split are closed and opened again, and references are never cut
+	chunks = splitMessage("<b>bold &amp; <i>italic words</i> here</b>", 35)
+	if len(chunks) != 2 || chunks[0] != "<b>bold &amp; <i>italic </i></b>" || chunks[1] != "<b><i>words</i> here</b>" {
+		t.Errorf("Expected tags closed and reopened across parts but got: %q", chunks)
+	}
+
+	// a long word without spaces is still split
+	chunks = splitMessage(strings.Repeat("a", 25), 10)
+	if len(chunks) != 3 || chunks[2] != "aaaaa" {
+		t.Errorf("Expected a long word split into 3 parts but got: %q", chunks)
+	}
+}
+
+func TestNewPagedList(t *testing.T) {
+	var lines []string
+	for i := 0; i < listPageSize+5; i++ {
+		lines = append(lines, "quiz")
+	}
+
+	list := newPagedList(1, "Quizzes:\n", lines, "footer")
+	if len(list.pages) != 2 {
+		t.Fatalf("Expected 2 pages but got: %d", len(list.pages))
+	}
+	if outputStr := list.text(); !strings.HasPrefix(outputStr, "Quizzes:\nquiz\n") || !strings.HasSuffix(outputStr, "<i>Page 1/2</i>\nfooter") {
+		t.Errorf("Expected the header, page number and footer but got: %q", outputStr)
+	}
+	if keyboard := list.keyboard(); keyboard == nil || len(keyboard.InlineKeyboard[0]) != 1 || *keyboard.InlineKeyboard[0][0].CallbackData != "lpage:1" {
+		t.Errorf("Expected only a Next button on the first page but got: %+v", keyboard)
+	}
+
+	short := newPagedList(1, "Your trash is empty.", nil, "")
+	if short.text() != "Your trash is empty." || short.keyboard() != nil {
+		t.Errorf("Expected a single page without buttons but got: %q", short.text())
+	}
+}
+
+func TestOpenMessages(t *testing.T) {
+	now := time.Now()
+	messages := newOpenMessages[int]()
+	messages.add("1:1", 1, now)
+
+	if value, found := messages.get("1:1", now.Add(time.Hour)); !found || value != 1 {
+		t.Errorf("Expected a recent message to be remembered but got: %d, %t", value, found)
+	}
+	if _, found := messages.get("1:1", now.Add(openMessageAge+time.Minute)); found {
+		t.Errorf("Expected an old message to be forgotten")
+	}
+
+	for i := 0; i <= maxOpenMessages; i++ {
+		messages.add(pickerKey(1, i), i, now.Add(time.Duration(i)*time.Second))
+	}
+	if len(messages.entries) != maxOpenMessages {
+		t.Errorf("Expected %d messages remembered but got: %d", maxOpenMessages, len(messages.entries))
+	}
+	if _, found := messages.get(pickerKey(1, 0), now); found {
+		t.Errorf("Expected the oldest message to be forgotten first")
+	}
+}
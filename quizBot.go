@@ -2,110 +2,193 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/storage"
 	firebase "firebase.google.com/go"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// botUsername is the bot's own username, which commands in groups are addressed to, e.g. /add_qns@<bot>
+var botUsername string
+
+// commandParse returns the argument of the command, e.g. the quiz name of /add_qns demo quiz. Problems with
+// the arguments are answered with the command's usage before it is handled
 func commandParse(msgTxt string, keyword string) string {
-	if strings.Contains(msgTxt, "/"+keyword+"@go_quiz_test_bot ") {
-		return strings.Replace(msgTxt, "/"+keyword+"@go_quiz_test_bot ", "", 1)
-	} else if strings.Contains(msgTxt, "/"+keyword+" ") {
-		return strings.Replace(msgTxt, "/"+keyword+" ", "", 1)
-	} else {
+	cmd, err := parseCommand(msgTxt, botUsername)
+	if err != nil || cmd.name != keyword {
 		return ""
+	} else if len(cmd.options) > 0 {
+		// commands without options take an argument with an = as typed
+		return cmd.text
+	}
+	return cmd.argument()
+}
+
+// fields of a quiz document that hold quiz details rather than questions
+var quizInfoFields = map[string]bool{
+	"numQns":      true,
+	"score":       true,
+	"bestScore":   true,
+	"lastAttempt": true,
+	"tags":        true,
+	"folder":      true,
+	"visibility":  true,
+	"sharedWith":  true,
+	"editors":     true,
+	"attempts":    true,
+	"ratingSum":   true,
+	"ratingCount": true,
+	"copiedFrom":  true,
+	"media":       true,
+	"formatting":  true,
+}
+
+// quizQuestions returns the question-answer pairs stored in a quiz document
+func quizQuestions(quizData map[string]interface{}) map[string]string {
+	questions := make(map[string]string)
+	for field, value := range quizData {
+		if answer, ok := value.(string); ok && !quizInfoFields[field] {
+			questions[field] = answer
+		}
 	}
+	return questions
 }
 
 func sendSimpleMsg(chatID int64, msgTxt string, bot *tgbotapi.BotAPI) {
 	msg := tgbotapi.NewMessage(chatID, msgTxt)
 
-	if _, err := bot.Send(msg); err != nil {
-		log.Panic(err)
-	}
+	queueMessage(bot, msg.ChatID, msg)
 }
 
-func sendHelpMessage(chatID int64, bot *tgbotapi.BotAPI) {
-	msg := tgbotapi.NewMessage(chatID, "")
-	msg.ParseMode = "HTML"
-	msg.Text = "I understand the following commands: \n" +
-		"<strong>/help</strong>  - get list of commands\n" +
-		"<strong>/add_quiz <i>quiz_name</i></strong> - add a new quiz\n" +
-		"<strong>/add_qns <i>quiz_name</i></strong> - add questions to a selected quiz\n" +
-		"<strong>/remove_qns <i>quiz_name</i></strong> - remove questions from a selected quiz\n" +
-		"<strong>/try_quiz</strong> - try a selected quiz\n" +
-		"<strong>/delete_quiz <i>quiz_name</i></strong> - delete a selected quiz\n" +
-		"<strong>/list_quizzes</strong> - list all of your quizzes\n" +
-		"<strong>/get_my_id</strong> - get your telegram ID number"
-
-	if _, err := bot.Send(msg); err != nil {
-		log.Panic(err)
-	}
+func yesNoKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnYes),
+			newActionButton(chatID, btnNo),
+		),
+	)
 }
 
-var yesNoKeyboard = tgbotapi.NewReplyKeyboard(
-	tgbotapi.NewKeyboardButtonRow(
-		tgbotapi.NewKeyboardButton("Yes"),
-		tgbotapi.NewKeyboardButton("No"),
-	),
-)
+func questionReviewKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnKeep),
+			newActionButton(chatID, btnToss),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnCancel),
+		),
+	)
+}
 
-var questionReviewKeyboard = tgbotapi.NewReplyKeyboard(
-	tgbotapi.NewKeyboardButtonRow(
-		tgbotapi.NewKeyboardButton("Keep"),
-		tgbotapi.NewKeyboardButton("Toss"),
-	),
-	tgbotapi.NewKeyboardButtonRow(
-		tgbotapi.NewKeyboardButton("Cancel"),
-	),
-)
+func questionResultKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnCorrect),
+			newActionButton(chatID, btnWrong),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnEndQuiz),
+		),
+	)
+}
 
-var questionResultKeyboard = tgbotapi.NewReplyKeyboard(
-	tgbotapi.NewKeyboardButtonRow(
-		tgbotapi.NewKeyboardButton("Correct"),
-		tgbotapi.NewKeyboardButton("Wrong"),
-	),
-	tgbotapi.NewKeyboardButtonRow(
-		tgbotapi.NewKeyboardButton("End Quiz"),
-	),
-)
+func cancelKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnCancel),
+		),
+	)
+}
 
-func createTwoBtnRowKeyboard(btnTxt1 string, btnTxt2 string) tgbotapi.ReplyKeyboardMarkup {
-	var optionsKeyboard = tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(btnTxt1),
-			tgbotapi.NewKeyboardButton(btnTxt2),
+func createTwoBtnRowKeyboard(chatID int64, btn1 buttonAction, btn2 buttonAction) tgbotapi.InlineKeyboardMarkup {
+	var optionsKeyboard = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btn1),
+			newActionButton(chatID, btn2),
 		),
 	)
 
 	return optionsKeyboard
 }
 
+func sendQuizInstructions(chatID int64, quizName string, prevScore string, typedAnswers bool, bot *tgbotapi.BotAPI) {
+	// buttons are named as the user sees them
+	revealLabel := tr(chatID, buttonLabels[btnRevealAns])
+	correctLabel := tr(chatID, buttonLabels[btnCorrect])
+	wrongLabel := tr(chatID, buttonLabels[btnWrong])
+	endLabel := tr(chatID, buttonLabels[btnEndQuiz])
+
+	msg := tgbotapi.NewMessage(chatID, "")
+	msg.Text = tr(chatID, "Quiz titled %s found!\n", escapeHTML(quizName)) +
+		prevScore +
+		tr(chatID, "For each question:\n") +
+		tr(chatID, "Press <strong>%s</strong> to reveal the answer.\n", revealLabel) +
+		tr(chatID, "After that, press <strong>%s</strong> if you answered correctly,\n", correctLabel) +
+		tr(chatID, "or press <strong>%s</strong> if you answered wrongly\n", wrongLabel)
+	if typedAnswers {
+		msg.Text += tr(chatID, "Or type your answer, and it is marked for you.\n")
+	}
+	msg.Text += tr(chatID, "Your score will be computed at the end of the quiz.\n") +
+		tr(chatID, "You may also <strong>%s</strong> at any time\n", endLabel)
+	msg.ParseMode = "HTML"
+
+	queueMessage(bot, msg.ChatID, msg)
+}
+
+// newQuestionMaps loads the questions of a quiz for an attempt or a review, along with the number of questions loaded
+func newQuestionMaps(quizData map[string]interface{}) (map[string]string, map[int]string, map[string]bool, int) {
+	questionsMap1 := make(map[string]string)
+	questionsMap2 := make(map[int]string)
+	questionsMap3 := make(map[string]bool)
+	numLoaded := 0
+
+	for question, answer := range quizQuestions(quizData) {
+		questionsMap1[question] = answer
+		numLoaded++
+		questionsMap2[numLoaded] = question
+	}
+
+	return questionsMap1, questionsMap2, questionsMap3, numLoaded
+}
+
+func questionAndAnswerText(chatID int64, question string, answer string, formatting questionFormatting) string {
+	return tr(chatID, "<strong>Q:</strong> %s\n", formattedText(question, formatting.question)) +
+		tr(chatID, "<strong>A:</strong> %s\n", formattedText(answer, formatting.answer))
+}
+
 func sendQuestionAndAnswerSet(
 	chatID int64,
 	qnIndex int,
 	bot *tgbotapi.BotAPI,
 	questionsMap1 map[string]string,
 	questionsMap2 map[int]string,
-) {
+	attachments map[string]questionAttachments,
+	formatting map[string]questionFormatting,
+) int {
+
+	sendMedia(chatID, attachments[questionsMap2[qnIndex]].question, bot)
+	sendMedia(chatID, attachments[questionsMap2[qnIndex]].answer, bot)
+	sendFormulas(chatID, questionsMap2[qnIndex]+"\n"+questionsMap1[questionsMap2[qnIndex]], bot)
 
 	msg2 := tgbotapi.NewMessage(chatID, "")
-	msg2.Text = "<strong>Q:</strong> " + questionsMap2[qnIndex] + "\n" +
-		"<strong>A:</strong> " + questionsMap1[questionsMap2[qnIndex]] + "\n\n"
+	msg2.Text = questionAndAnswerText(chatID, questionsMap2[qnIndex], questionsMap1[questionsMap2[qnIndex]], formatting[questionsMap2[qnIndex]])
 	msg2.ParseMode = "HTML"
-	if _, err := bot.Send(msg2); err != nil {
-		log.Panic(err)
-	}
+	msg2.ReplyMarkup = questionReviewKeyboard(chatID)
+
+	return sendLongMessage(msg2, bot)
 }
 
 func sendQuestion(
@@ -114,122 +197,128 @@ func sendQuestion(
 	bot *tgbotapi.BotAPI,
 	questionsMap1 map[string]string,
 	questionsMap2 map[int]string,
-) {
+	attachments map[string]questionAttachments,
+	formatting map[string]questionFormatting,
+) int {
+
+	sendMedia(chatID, attachments[questionsMap2[qnIndex]].question, bot)
+	sendFormulas(chatID, questionsMap2[qnIndex], bot)
 
 	msg2 := tgbotapi.NewMessage(chatID, "")
-	msg2.Text = "<strong>Q:</strong> " + questionsMap2[qnIndex] + "\n"
+	msg2.Text = tr(chatID, "<strong>Q:</strong> %s\n", formattedText(questionsMap2[qnIndex], formatting[questionsMap2[qnIndex]].question))
 	msg2.ParseMode = "HTML"
-	msg2.ReplyMarkup = createTwoBtnRowKeyboard("Reveal Ans", "End Quiz")
-	if _, err := bot.Send(msg2); err != nil {
-		log.Panic(err)
-	}
+	msg2.ReplyMarkup = createTwoBtnRowKeyboard(chatID, btnRevealAns, btnEndQuiz)
+
+	return sendLongMessage(msg2, bot)
 }
 
-func sendAnswer(
+// revealAnswer edits the question message in place to show its answer, and returns the ID of the message
+// that takes the result, which is a new one if the answer does not fit
+func revealAnswer(
 	chatID int64,
+	messageID int,
 	qnIndex int,
 	bot *tgbotapi.BotAPI,
 	questionsMap1 map[string]string,
 	questionsMap2 map[int]string,
+	attachments map[string]questionAttachments,
+	formatting map[string]questionFormatting,
+) int {
+
+	sendMedia(chatID, attachments[questionsMap2[qnIndex]].answer, bot)
+	sendFormulas(chatID, questionsMap1[questionsMap2[qnIndex]], bot)
+
+	keyboard := questionResultKeyboard(chatID)
+	return editLongMessage(
+		chatID,
+		messageID,
+		questionAndAnswerText(chatID, questionsMap2[qnIndex], questionsMap1[questionsMap2[qnIndex]], formatting[questionsMap2[qnIndex]]),
+		&keyboard,
+		bot,
+	)
+}
+
+// markQuestion edits a question message in place to show what the user chose for it, removing its buttons.
+// A question too long for one message only has its last message edited
+func markQuestion(
+	chatID int64,
+	messageID int,
+	qnIndex int,
+	bot *tgbotapi.BotAPI,
+	questionsMap1 map[string]string,
+	questionsMap2 map[int]string,
+	formatting map[string]questionFormatting,
+	mark string,
 ) {
 
-	msg2 := tgbotapi.NewMessage(chatID, "")
-	msg2.Text = "<strong>A:</strong> " + questionsMap1[questionsMap2[qnIndex]] + "\n"
-	msg2.ParseMode = "HTML"
-	msg2.ReplyMarkup = questionResultKeyboard
-	if _, err := bot.Send(msg2); err != nil {
-		log.Panic(err)
-	}
+	chunks := splitMessage(
+		questionAndAnswerText(chatID, questionsMap2[qnIndex], questionsMap1[questionsMap2[qnIndex]], formatting[questionsMap2[qnIndex]])+"<i>"+tr(chatID, mark)+"</i>",
+		messageLimit,
+	)
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, chunks[len(chunks)-1])
+	edit.ParseMode = "HTML"
+	queueMessage(bot, edit.ChatID, edit)
 }
 
+// confirmQnsRemove lists the tossed questions, and returns whether there are any along with the ID of the confirmation prompt
 func confirmQnsRemove(
 	chatID int64,
 	qnIndex int,
 	bot *tgbotapi.BotAPI,
 	questionsMap1 map[string]string,
 	questionsMap3 map[string]bool,
-) bool {
+	formatting map[string]questionFormatting,
+) (bool, int) {
 
-	var msgCompilation string = "QUESTIONS TO REMOVE:\n"
-	var nextQn string = ""
+	var msgCompilation string = tr(chatID, "QUESTIONS TO REMOVE:\n")
 
 	var haveTossed bool = false
+	var promptID int = 0
 
 	for question, isTossed := range questionsMap3 {
 		if isTossed {
 			haveTossed = true
-
-			nextQn = "<strong>Q:</strong> " + question + "\n" +
-				"<strong>A:</strong> " + questionsMap1[question] + "\n"
-
-			if len(msgCompilation)+len(nextQn) < 4096 {
-				// append and continue
-				msgCompilation = msgCompilation + nextQn
-
-			} else {
-				// send partial message
-				msg2 := tgbotapi.NewMessage(chatID, "")
-				msg2.Text = msgCompilation
-				msg2.ParseMode = "HTML"
-				if _, err := bot.Send(msg2); err != nil {
-					log.Panic(err)
-				}
-
-				msgCompilation = nextQn
-			}
+			msgCompilation += questionAndAnswerText(chatID, question, questionsMap1[question], formatting[question])
 		}
-
 	}
 
-	if msgCompilation != "" {
-		msg2 := tgbotapi.NewMessage(chatID, "")
-		msg2.Text = msgCompilation
-		msg2.ParseMode = "HTML"
-		if _, err := bot.Send(msg2); err != nil {
-			log.Panic(err)
-		}
-	}
+	msg := tgbotapi.NewMessage(chatID, msgCompilation)
+	msg.ParseMode = "HTML"
+	sendLongMessage(msg, bot)
 
 	if haveTossed {
 		msg2 := tgbotapi.NewMessage(chatID, "")
-		msg2.Text = "Are you sure you want to remove all the above questions?"
-		msg2.ReplyMarkup = yesNoKeyboard
+		msg2.Text = tr(chatID, "Are you sure you want to remove all the above questions?")
+		msg2.ReplyMarkup = yesNoKeyboard(chatID)
 
-		if _, err := bot.Send(msg2); err != nil {
-			log.Panic(err)
-		}
+		promptID = sendPrompt(msg2, bot)
 	} else {
 		msg2 := tgbotapi.NewMessage(chatID, "")
-		msg2.Text = "No questions selected for removal"
-		msg2.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-			RemoveKeyboard: true,
-			Selective:      false,
-		}
+		msg2.Text = tr(chatID, "No questions selected for removal")
 
-		if _, err := bot.Send(msg2); err != nil {
-			log.Panic(err)
-		}
+		queueMessage(bot, msg2.ChatID, msg2)
 	}
 
-	return haveTossed
+	return haveTossed, promptID
 
 }
 
 func main() {
-	// // check for env file
-	// err := godotenv.Load()
-	// if err != nil {
-	// 	log.Fatal("Error loading .env file")
-	// }
-
-	// fmt.Println("var1 = ", reflect.TypeOf(optionsKeyboard))
+	// settings come from the config file, the environment and the flags
+	cfg, err := loadConfig(os.Args[1:], os.Getenv, os.ReadFile)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		log.Fatalln(err)
+	}
+	listPageSize = cfg.ListPageSize
 
 	// init firebase client
-	opt := option.WithCredentialsFile("firebase_service_acct.json")
+	opt := option.WithCredentialsFile(cfg.StorageDSN)
 	ctx := context.Background()
 	app, err := firebase.NewApp(ctx, nil, opt)
 	if err != nil {
-		fmt.Errorf("error initializing app: %v", err)
+		log.Fatalf("error initializing app: %v", err)
 	}
 
 	client, err := app.Firestore(ctx)
@@ -239,40 +328,78 @@ func main() {
 
 	defer client.Close()
 
+	// copies of question media are kept in a firebase storage bucket when one is set
+	var mediaBucket *storage.BucketHandle
+	if cfg.MediaBucket != "" {
+		storageClient, err := app.Storage(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if mediaBucket, err = storageClient.Bucket(cfg.MediaBucket); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
 	// Load the telegram bot key
-	bot, err := tgbotapi.NewBotAPI(os.Getenv("TELEGRAM_APITOKEN"))
+	bot, err := tgbotapi.NewBotAPI(cfg.Token)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bot.Debug = true
+	bot.Debug = cfg.Debug
+	botUsername = bot.Self.UserName
+
+	// messages are queued per chat to stay within telegram's rate limits, and users who blocked the bot are marked inactive
+	bot.Client = newOutbox(bot.Client, cfg.sendLimits(), func(chatID int64) {
+		setUserInactive(ctx, client, fmt.Sprint(chatID), true)
+	})
 
 	log.Printf("Authorized on account %s", bot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	// users find the commands in telegram's command menu
+	publishCommands(bot)
 
-	updates := bot.GetUpdatesChan(u)
+	// public quizzes are browsed from the catalog, which lists quizzes made public before it was kept once
+	fillCatalog(ctx, client)
+
+	updates, err := receiveUpdates(bot, cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
 	// inactive -> current user has not be logged by bot. User is otherwise logged by the bot
 	// idle -> user has been logged by bot and waiting command
 	var botState string = "inactive"
 	var inputExpected string = "none"
 	var tryingMyQuiz bool = false
+	// options of /try_quiz: typed answers are marked by the bot, and only quizLimit questions are asked when it is set
+	var typedAnswers bool = false
+	var quizLimit int = 0
 
 	var friendUserID string = ""
 
 	var currentUsername string = ""
 	var currentUserID string = ""
 	var quizName string = ""
+	// owner of the quiz being edited, which is another user when editing as a co-editor
+	var quizOwnerID string = ""
+	// source and changes of the copied quiz shown by /sync
+	var syncFork quizFork
+	var syncChanges []syncChange
+
+	// question picked with /edit_qns, as it was before editing
+	var editedQuestion string = ""
 
 	questionsMap1 := make(map[string]string)
 	questionsMap2 := make(map[int]string)
 	questionsMap3 := make(map[string]bool)
 
-	updateDocFields := make(map[string]interface{})
-
 	var questionText = ""
+	// media of the questions and answers being added, tried or reviewed, by question
+	attachments := make(map[string]questionAttachments)
+	// formatting the questions were typed with, by question, kept by users who turned it on
+	formatting := make(map[string]questionFormatting)
+	var keepFormatting bool = false
 
 	var numQns int = 0
 	var qnsRemaining int = 0
@@ -282,422 +409,1442 @@ func main() {
 
 	fmt.Println(numQns, qnsRemaining, scoreInt)
 
-	for update := range updates {
-		// ignore non-Message updates
-		if update.Message == nil {
-			continue
+	// quiz pickers that are still open, by chat and message ID
+	quizPickers := newOpenMessages[*quizPicker]()
+
+	// catalogs opened with /browse and /search, by chat and message ID
+	catalogViews := newOpenMessages[*catalogView]()
+
+	// listings too long for one page, by chat and message ID
+	pagedLists := newOpenMessages[*pagedList]()
+
+	// message whose buttons are currently accepted, buttons on any other message are stale
+	var activePromptID int = 0
+
+	// live quizzes being played in group chats, by chat ID, and the timers of their questions
+	liveGames := make(map[int64]*liveGame)
+	liveTimeouts := make(chan liveTimeout)
+
+	// challenges being answered, by player ID, each in the player's private chat
+	challengeSessions := make(map[string]*challengeSession)
+
+	// scheduled posts are checked regularly, and remember in firebase when they were last sent
+	scheduleTicker := time.NewTicker(time.Minute)
+	defer scheduleTicker.Stop()
+
+	// commands any member of a chat can send in any state, dispatched before the current user's commands
+
+	// /poll_quiz sends the questions as native quiz polls, and can also be played alone in a private chat
+	liveCommand := func(update tgbotapi.Update) {
+		hostCommand := update.Message.Command()
+		if _, found := liveGames[update.Message.Chat.ID]; found {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "A live quiz is already running in this chat. The host can end it with /stop_quiz"), bot)
+			return
 		}
 
-		if currentUserID == "" {
-			currentUserID = fmt.Sprint(update.Message.From.ID)
+		hostQuizName := commandParse(update.Message.Text, hostCommand)
+		hostID := fmt.Sprint(update.Message.From.ID)
+
+		if hostCommand == "host_quiz" && !isGroupChat(update.Message.Chat) {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Live quizzes are played in group chats. Add me to a group and use /host_quiz quiz_name there, or play alone with /poll_quiz quiz_name"), bot)
+		} else if len(hostQuizName) == 0 {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", hostCommand),
+				bot,
+			)
+		} else if doc, err := client.Collection("USERS").Doc(hostID).Collection("QUIZZES").Doc(hostQuizName).Get(ctx); err != nil {
+			if status.Code(err) != codes.NotFound {
+				log.Printf("An error has occurred trying to load live quiz: %s", err)
+			}
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", hostQuizName), bot)
+		} else if len(quizQuestions(doc.Data())) == 0 {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "This quiz has no questions to try!"), bot)
+		} else {
+			game := newLiveGame(update.Message.Chat.ID, hostID, hostQuizName, doc.Data())
+			game.pollMode = hostCommand == "poll_quiz"
+			liveGames[update.Message.Chat.ID] = game
+
+			if isGroupChat(update.Message.Chat) {
+				sendSimpleMsg(update.Message.Chat.ID, trn(update.Message.Chat.ID, len(game.questions),
+					"%s is hosting live quiz %s with %d question! Everyone can play: answer each question within %d seconds, faster correct answers score more points.",
+					"%s is hosting live quiz %s with %d questions! Everyone can play: answer each question within %d seconds, faster correct answers score more points.",
+					liveUserName(update.Message.From), hostQuizName, len(game.questions), int(game.answerTime.Seconds()),
+				), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, trn(update.Message.Chat.ID, len(game.questions),
+					"Starting quiz %s with %d question as a quiz poll. Answer it within %d seconds, or end early with /stop_quiz",
+					"Starting quiz %s with %d questions as quiz polls. Answer each one within %d seconds, or end early with /stop_quiz",
+					hostQuizName, len(game.questions), int(game.answerTime.Seconds()),
+				), bot)
+			}
+			game.nextQuestion(bot, liveTimeouts)
 		}
-		if currentUsername == "" {
-			currentUsername = update.Message.From.UserName
+	}
+	handleCommand("host_quiz", liveCommand)
+	handleCommand("poll_quiz", liveCommand)
+
+	// only the host can end a live quiz early
+	handleCommand("stop_quiz", func(update tgbotapi.Update) {
+		game, found := liveGames[update.Message.Chat.ID]
+		if !found {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "There is no live quiz to stop."), bot)
+		} else if fmt.Sprint(update.Message.From.ID) != game.hostID {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Only the host can stop the live quiz."), bot)
+		} else {
+			if game.accepting() {
+				game.closeQuestion(bot)
+			}
+			finishLiveGame(ctx, client, bot, game)
+			delete(liveGames, update.Message.Chat.ID)
 		}
+	})
+
+	// group leaderboards can be used by every member
+	leaderboardCommand := func(update tgbotapi.Update) {
+		if !isGroupChat(update.Message.Chat) {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Leaderboards are kept for group chats. Use this command in a group."), bot)
+		} else if update.Message.Command() == "leaderboard" {
+			sendLeaderboard(ctx, client, update.Message.Chat.ID, bot, commandParse(update.Message.Text, "leaderboard"))
+		} else {
+			switch setting := strings.ToLower(strings.TrimSpace(commandParse(update.Message.Text, "weekly_summary"))); {
+			case setting != "on" && setting != "off":
+				enabled, err := weeklySummaryEnabled(ctx, client, update.Message.Chat.ID)
+				if err != nil {
+					log.Printf("An error has occurred trying to load weekly summary: %s", err)
+				}
 
-		fmt.Printf("[%s, %s] %s\n", currentUsername, currentUserID, update.Message.Text)
+				state := tr(update.Message.Chat.ID, "off")
+				if enabled {
+					state = tr(update.Message.Chat.ID, "on")
+				}
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "The weekly summary is %s. Group admins can change it with /weekly_summary on or /weekly_summary off", state), bot)
 
-		if update.Message.IsCommand() && update.Message.Command() == "start" {
-			// Check if the focus user id is already in the USERS collection, else create new user
-			currentUsername = update.Message.From.UserName
-			currentUserID = fmt.Sprint(update.Message.From.ID)
+			case !isGroupAdmin(bot, update.Message.Chat.ID, update.Message.From.ID) && !cfg.isAdmin(update.Message.From.ID):
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Only group admins can change the weekly summary."), bot)
 
-			docRef := client.Collection("USERS").Doc(currentUserID)
-			doc, err := docRef.Get(ctx)
-			if err != nil {
-				if status.Code(err) == codes.NotFound {
-					// // Handle document not existing here
-					// _, err := docRef.Set(ctx /* custom object here */)
-					// if err != nil {
-					// 	return err
-					// }
+			default:
+				if err := setWeeklySummary(ctx, client, update.Message.Chat.ID, setting == "on"); err != nil {
+					log.Printf("An error has occurred trying to update weekly summary: %s", err)
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the weekly summary could not be changed. Please try again."), bot)
+				} else if setting == "on" {
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Every Monday I will post last week's leaderboard and crown the top scorer."), bot)
 				} else {
-					// return err
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "The weekly summary is now off."), bot)
 				}
 			}
+		}
+	}
+	handleCommand("leaderboard", leaderboardCommand)
+	handleCommand("weekly_summary", leaderboardCommand)
+
+	// the language is chosen by each user in their private chat, and by the admins of a group
+	handleCommand("language", func(update tgbotapi.Update) {
+		code := strings.TrimSpace(commandParse(update.Message.Text, "language"))
+		lang, supported := supportedLanguage(code)
+
+		switch {
+		case code == "":
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "I am speaking %s. Change it with /language code, choosing from %s",
+				languageNames[chatLanguage(update.Message.Chat.ID)], languageList()), bot)
+
+		case !supported:
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, I do not speak %s yet. Please choose from %s", code, languageList()), bot)
+
+		case isGroupChat(update.Message.Chat) && !isGroupAdmin(bot, update.Message.Chat.ID, update.Message.From.ID) && !cfg.isAdmin(update.Message.From.ID):
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Only group admins can change the language of the group."), bot)
+
+		default:
+			err := retryStorage(func() error {
+				return setChatLanguage(ctx, client, update.Message.Chat.ID, lang)
+			})
+			if status.Code(err) == codes.NotFound {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please run /start first, then choose your language."), bot)
+			} else if err != nil {
+				reportStorageError(update.Message.Chat.ID, bot, "change the language", err)
+			} else {
+				// the new language is used from this reply on
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "From now on I will speak %s.", languageNames[lang]), bot)
+			}
+		}
+	})
 
-			if doc.Exists() {
+	// commands a started user sends while idle, dispatched through botCommands
+	handleCommand("help", func(update tgbotapi.Update) {
+		sendHelpMessage(update.Message.Chat, bot)
+	})
+
+	handleCommand("add_quiz", func(update tgbotapi.Update) {
+		quizTitle := commandParse(update.Message.Text, "add_quiz")
+
+		fmt.Println("SHOW QUIZ TITLE: " + quizTitle)
+
+		paramCharLen := len(quizTitle)
+
+		if paramCharLen < 1 {
+
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Quiz title cannot be empty, please try again!"),
+				bot,
+			)
+
+		} else {
+
+			// create fails if the quiz exists, so a quiz is never overwritten
+			docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizTitle)
+			err := retryStorage(func() error {
+				_, err := docRef.Create(ctx, map[string]interface{}{
+					"numQns":     0,
+					"score":      "none",
+					"visibility": visibilityPrivate,
+				})
+				return err
+			})
+			if status.Code(err) == codes.AlreadyExists {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "Quiz title exists"),
+					bot,
+				)
+			} else if err != nil {
+				reportStorageError(update.Message.Chat.ID, bot, "add the quiz", err)
+			} else {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "New Quiz Title: %s is added into your collection.", quizTitle),
+					bot,
+				)
+			}
+
+		}
+		botState = "idle"
+	})
+
+	handleCommand("add_qns", func(update tgbotapi.Update) {
+		// parse quiz name
+		quizName = commandParse(update.Message.Text, "add_qns")
+
+		fmt.Println("SEARCHING FOR QUIZ: " + quizName)
+
+		paramCharLen := len(quizName)
+
+		if paramCharLen > 0 {
+			// co-editors add questions to the owner's quiz
+			ownerID, doc, err := findEditableQuiz(ctx, client, currentUserID, quizName)
+			var clash *quizNameClash
+			if errors.As(err, &clash) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You can edit more than one quiz named %s. Please choose whose quiz you mean:", quizName), bot)
+				picker := newClashPicker(ctx, client, "add_qns", clash)
+				quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+			} else if err != nil && status.Code(err) != codes.NotFound {
+				log.Printf("An error has occurred trying to find quiz: %s", err)
+			}
+
+			if err == nil {
 				// Handle document existing here
-				fmt.Println("User found")
+				fmt.Println("Doc found:", doc.Ref.ID)
+				quizName = doc.Ref.ID
+
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+				msg.Text = tr(update.Message.Chat.ID, "Quiz titled %s found!\nPress <strong>Exit</strong> to save changes and end\nPress <strong>Cancel</strong> to quit without saving\nPlease input new question:", escapeHTML(quizName))
+				msg.ParseMode = "HTML"
+				msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnExit, btnCancel)
+
+				activePromptID = sendPrompt(msg, bot)
+
+				quizOwnerID = ownerID
+				questionsMap1 = make(map[string]string)
+				attachments = make(map[string]questionAttachments)
+				formatting = make(map[string]questionFormatting)
+				keepFormatting = keepsFormatting(ctx, client, currentUserID)
+
+				botState = "add_qns_Qn"
+				inputExpected = "qn"
+
+			} else if clash == nil {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName),
+					bot,
+				)
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "add_qns"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "add_qns"),
+				bot,
+			)
+		}
+	})
 
-				if doc.Data()["username"].(string) != currentUsername {
-					// update username in database
-					_, err = client.Collection("USERS").Doc(currentUserID).Update(ctx, []firestore.Update{
-						{
-							Path:  "username",
-							Value: currentUsername,
-						},
-					})
+	handleCommand("remove_qns", func(update tgbotapi.Update) {
+		// parse quiz name
+		quizName = commandParse(update.Message.Text, "remove_qns")
 
-					if err != nil {
-						// Handle any errors in an appropriate way, such as returning them.
-						log.Printf("An error has occurred trying to update username: %s", err)
+		fmt.Println("SEARCHING FOR QUIZ: " + quizName)
+
+		paramCharLen := len(quizName)
+
+		if paramCharLen > 0 {
+			ownerID, doc, err := findEditableQuiz(ctx, client, currentUserID, quizName)
+			var clash *quizNameClash
+			if errors.As(err, &clash) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You can edit more than one quiz named %s. Please choose whose quiz you mean:", quizName), bot)
+				picker := newClashPicker(ctx, client, "remove_qns", clash)
+				quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+			} else if err != nil && status.Code(err) != codes.NotFound {
+				log.Printf("An error has occurred trying to find quiz: %s", err)
+			}
+
+			if err == nil {
+				// Handle document existing here
+				fmt.Println("Doc found:", doc.Ref.ID)
+				quizName = doc.Ref.ID
+
+				quizOwnerID = ownerID
+				numQns = len(quizQuestions(doc.Data()))
+				qnsRemaining = 0
+
+				if numQns == 0 {
+					sendSimpleMsg(
+						update.Message.Chat.ID,
+						tr(update.Message.Chat.ID, "This quiz has no questions to remove!"),
+						bot,
+					)
+				} else {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.Text = tr(update.Message.Chat.ID, "Quiz titled %s found!\nFor each question:\nPress <strong>Keep</strong> to keep the question\nPress <strong>Toss</strong> to remove the question\nPress <strong>Cancel</strong> to revert changes\n", escapeHTML(quizName))
+					msg.ParseMode = "HTML"
+
+					queueMessage(bot, msg.ChatID, msg)
+
+					attachments = quizAttachments(doc.Data())
+					formatting = quizFormatting(doc.Data())
+					for question, answer := range quizQuestions(doc.Data()) {
+						questionsMap1[question] = answer
+						qnsRemaining++
+						questionsMap2[qnsRemaining] = question
 					}
 
+					activePromptID = sendQuestionAndAnswerSet(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
+					qnsRemaining--
+
+					numQns = 0
+					botState = "remove_qns"
 				}
+			} else if clash == nil {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName),
+					bot,
+				)
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "remove_qns"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "remove_qns"),
+				bot,
+			)
+		}
+	})
+
+	handleCommand("delete_quiz", func(update tgbotapi.Update) {
+		// parse quiz name
+		quizName = commandParse(update.Message.Text, "delete_quiz")
+		paramCharLen := len(quizName)
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+		msg.ParseMode = "HTML"
+
+		if paramCharLen > 0 {
+			docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName)
+			doc, err := docRef.Get(ctx)
+
+			if err == nil && doc.Exists() {
+				msg.Text = tr(update.Message.Chat.ID, "Are you sure you want to delete quiz <strong>%s</strong>?\n", escapeHTML(quizName)) +
+					trn(update.Message.Chat.ID, int(trashRetention.Hours()/24), "It will be kept in your <strong>/trash</strong> for %d day.", "It will be kept in your <strong>/trash</strong> for %d days.", int(trashRetention.Hours()/24))
+				msg.ReplyMarkup = yesNoKeyboard(update.Message.Chat.ID)
 
+				activePromptID = sendPrompt(msg, bot)
+				botState = "delete_quiz_confirm"
 			} else {
+				msg.Text = tr(update.Message.Chat.ID, "Quiz could not be found. Error deleting quiz: %s", escapeHTML(quizName))
 
-				// Create new user document
-				_, err := client.Collection("USERS").Doc(currentUserID).Set(ctx, map[string]interface{}{
-					"username": currentUsername,
-				})
+				queueMessage(bot, msg.ChatID, msg)
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "delete_quiz"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "delete_quiz"),
+				bot,
+			)
+		}
+	})
+
+	handleCommand("list_quizzes", func(update tgbotapi.Update) {
+		// optional filter by #tag or by folder
+		listFilter := commandParse(update.Message.Text, "list_quizzes")
+
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+		msg.ParseMode = "HTML"
+
+		summaries, err := loadQuizSummaries(ctx, client, currentUserID)
+		if err != nil {
+			log.Printf("An error has occurred trying to list quizzes: %s", err)
+			msg.Text = tr(update.Message.Chat.ID, "Sorry, your quizzes could not be loaded. Please try again.")
+		} else if filtered := filterQuizSummaries(summaries, listFilter); len(filtered) > 0 {
+			list := newPagedList(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Here is the list of your quizzes: \n"), formatQuizList(update.Message.Chat.ID, filtered), "")
+			pagedLists.add(pickerKey(update.Message.Chat.ID, sendPagedList(update.Message.Chat.ID, bot, list)), list, time.Now())
+			return
+		} else if listFilter != "" {
+			msg.Text = tr(update.Message.Chat.ID, "No quizzes found in %s.\nFilter by tag with <strong>/list_quizzes #<i>tag</i></strong> or by folder with <strong>/list_quizzes <i>folder</i></strong>", escapeHTML(listFilter))
+		} else {
+			msg.Text = tr(update.Message.Chat.ID, "No quizzes found. Create one with /add_quiz quiz name")
+		}
 
-				if err != nil {
-					log.Fatalf("Failed adding [%s]: %v", currentUsername, err)
+		queueMessage(bot, msg.ChatID, msg)
+	})
+
+	organizeCommand := func(update tgbotapi.Update) {
+		command := update.Message.Command()
+		quizName = commandParse(update.Message.Text, command)
+
+		if len(quizName) > 0 {
+			doc, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+
+			if err == nil && doc.Exists() {
+				summary := newQuizSummary(doc)
+
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+				msg.ParseMode = "HTML"
+				msg.ReplyMarkup = cancelKeyboard(update.Message.Chat.ID)
+
+				switch command {
+				case "tag":
+					msg.Text = tr(update.Message.Chat.ID, "Quiz titled %s found!\nCurrent tags: %s\nPlease input the tags to add, separated by commas:\n(Press <strong>Cancel</strong> to exit)", escapeHTML(quizName), escapeHTML(formatTags(summary.tags)))
+					botState = "tag_add"
+				case "untag":
+					msg.Text = tr(update.Message.Chat.ID, "Quiz titled %s found!\nCurrent tags: %s\nPlease input the tags to remove, separated by commas:\n(Press <strong>Cancel</strong> to exit)", escapeHTML(quizName), escapeHTML(formatTags(summary.tags)))
+					botState = "tag_remove"
+				case "folder":
+					currentFolder := summary.folder
+					if currentFolder == "" {
+						currentFolder = tr(update.Message.Chat.ID, "none")
+					}
+					msg.Text = tr(update.Message.Chat.ID, "Quiz titled %s found!\nCurrent folder: %s\nPlease input the folder to move this quiz to, e.g. <i>Biology/Chapter 3</i>\nInput <strong>/</strong> to take the quiz out of its folder.\n(Press <strong>Cancel</strong> to exit)", escapeHTML(quizName), escapeHTML(currentFolder))
+					botState = "folder_input"
 				}
 
-				// Create new user quizzes collection
-				_, err2 := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc("demo quiz").Set(ctx, map[string]interface{}{
-					"numQns":                       1,
-					"score":                        "none",
-					"this is a demo quiz question": "this is a demo quiz answer",
-				})
+				activePromptID = sendPrompt(msg, bot)
+			} else {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName),
+					bot,
+				)
+			}
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", command),
+				bot,
+			)
+		}
+	}
+	handleCommand("tag", organizeCommand)
+	handleCommand("untag", organizeCommand)
+	handleCommand("folder", organizeCommand)
+
+	handleCommand("trash", func(update tgbotapi.Update) {
+		entries, err := listTrash(ctx, client, currentUserID)
+		if err != nil {
+			log.Printf("An error has occurred trying to list trash: %s", err)
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, your trash could not be loaded. Please try again."), bot)
+		} else {
+			list := trashList(update.Message.Chat.ID, entries)
+			pagedLists.add(pickerKey(update.Message.Chat.ID, sendPagedList(update.Message.Chat.ID, bot, list)), list, time.Now())
+		}
+	})
+
+	handleCommand("restore", func(update tgbotapi.Update) {
+		entryNum, convErr := strconv.Atoi(strings.TrimSpace(commandParse(update.Message.Text, "restore")))
+		entries, err := listTrash(ctx, client, currentUserID)
+
+		if err != nil {
+			log.Printf("An error has occurred trying to list trash: %s", err)
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, your trash could not be loaded. Please try again."), bot)
+		} else if convErr != nil || entryNum < 1 || entryNum > len(entries) {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include the number of the item to restore, as shown by /trash.\ne.g. `/restore 1`"),
+				bot,
+			)
+		} else {
+			entry := entries[entryNum-1]
+			sendRestoreResult(update.Message.Chat.ID, bot, entry, restoreTrashEntry(ctx, client, currentUserID, entry))
+		}
+	})
+
+	handleCommand("undo", func(update tgbotapi.Update) {
+		entries, err := listTrash(ctx, client, currentUserID)
+
+		if err != nil {
+			log.Printf("An error has occurred trying to list trash: %s", err)
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, your trash could not be loaded. Please try again."), bot)
+		} else if len(entries) == 0 {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "There is nothing to undo."), bot)
+		} else {
+			entry := entries[0]
+			sendRestoreResult(update.Message.Chat.ID, bot, entry, restoreTrashEntry(ctx, client, currentUserID, entry))
+		}
+	})
+
+	shareCommand := func(update tgbotapi.Update) {
+		command := update.Message.Command()
+		quizName = commandParse(update.Message.Text, command)
+
+		if len(quizName) > 0 {
+			doc, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+			msg.ParseMode = "HTML"
+
+			if err != nil || !doc.Exists() {
+				msg.Text = tr(update.Message.Chat.ID, "Quiz with name %s not found.", escapeHTML(quizName))
+			} else if command == "share" {
+				// share links only work for quizzes that are not private
+				var visibilityNote string
+				if quizVisibility(doc.Data()) == visibilityPrivate {
+					err = setQuizVisibility(ctx, client, currentUserID, quizName, visibilityShared, nil)
+					visibilityNote = tr(update.Message.Chat.ID, "\n\nThis quiz was private, so it is now %s.", visibilityDescription(update.Message.Chat.ID, visibilityShared, nil))
+				}
 
-				if err2 != nil {
-					log.Fatalf("Failed adding quizzes collection for [%s]: %v", currentUsername, err2)
+				shareCode, err2 := createShareCode(ctx, client, currentUserID, quizName)
+				if err == nil {
+					err = err2
+				}
+
+				if err != nil {
+					log.Printf("An error has occurred trying to share quiz: %s", err)
+					msg.Text = tr(update.Message.Chat.ID, "Sorry, quiz %s could not be shared. Please try again.", escapeHTML(quizName))
+				} else {
+					msg.Text = tr(update.Message.Chat.ID, "Share quiz <strong>%s</strong> with this link:\n%s\n\nFriends can also send <strong>/start %s</strong> to try it.\nUse <strong>/unshare %s</strong> to stop sharing it.%s", escapeHTML(quizName), shareLink(bot.Self.UserName, shareCode), shareCode, escapeHTML(quizName), visibilityNote)
+				}
+			} else {
+				numRevoked, err := revokeShareCodes(ctx, client, currentUserID, quizName)
+				if err != nil {
+					log.Printf("An error has occurred trying to unshare quiz: %s", err)
+					msg.Text = tr(update.Message.Chat.ID, "Sorry, quiz %s could not be unshared. Please try again.", escapeHTML(quizName))
+				} else if numRevoked == 0 {
+					msg.Text = tr(update.Message.Chat.ID, "Quiz %s is not shared.", escapeHTML(quizName))
+				} else {
+					msg.Text = tr(update.Message.Chat.ID, "Share links for quiz %s no longer work.", escapeHTML(quizName))
 				}
 			}
 
-			sendSimpleMsg(update.Message.Chat.ID, "Hello "+currentUsername+"!", bot)
+			queueMessage(bot, msg.ChatID, msg)
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, command); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", command),
+				bot,
+			)
+		}
+	}
+	handleCommand("share", shareCommand)
+	handleCommand("unshare", shareCommand)
 
-			botState = "idle"
+	handleCommand("visibility", func(update tgbotapi.Update) {
+		quizName = commandParse(update.Message.Text, "visibility")
 
-		} else if currentUserID == fmt.Sprint(update.Message.From.ID) {
-			// check if message is from current user if not ignore other users
+		if len(quizName) > 0 {
+			doc, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Get(ctx)
 
-			switch botState {
-			case "idle":
-				if !update.Message.IsCommand() { // ignore any non-command Messages
-					continue
+			if err == nil && doc.Exists() {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+				msg.ParseMode = "HTML"
+				msg.Text = tr(update.Message.Chat.ID, "Quiz titled %s is %s.\nChoose who can try it:\n<strong>Private</strong> - only you\n<strong>Shared</strong> - users and groups you choose, and anyone with its share link\n<strong>Public</strong> - anyone", escapeHTML(quizName), escapeHTML(visibilityDescription(update.Message.Chat.ID, quizVisibility(doc.Data()), quizSharedWith(doc.Data()))))
+				msg.ReplyMarkup = visibilityKeyboard(update.Message.Chat.ID)
+
+				activePromptID = sendPrompt(msg, bot)
+				botState = "visibility_select"
+			} else {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName),
+					bot,
+				)
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "visibility"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "visibility"),
+				bot,
+			)
+		}
+	})
+
+	handleCommand("edit_qns", func(update tgbotapi.Update) {
+		quizName = commandParse(update.Message.Text, "edit_qns")
+
+		if len(quizName) > 0 {
+			ownerID, doc, err := findEditableQuiz(ctx, client, currentUserID, quizName)
+			var clash *quizNameClash
+			if errors.As(err, &clash) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You can edit more than one quiz named %s. Please choose whose quiz you mean:", quizName), bot)
+				picker := newClashPicker(ctx, client, "edit_qns", clash)
+				quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+			} else if err != nil {
+				if status.Code(err) != codes.NotFound {
+					log.Printf("An error has occurred trying to find quiz: %s", err)
 				}
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName), bot)
+			} else if questionsMap1, questionsMap2, questionsMap3, numQns = newQuestionMaps(doc.Data()); numQns == 0 {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "This quiz has no questions to edit!"), bot)
+			} else {
+				quizName = doc.Ref.ID
+				quizOwnerID = ownerID
+				formatting = quizFormatting(doc.Data())
+				list := numberedQuestionsList(update.Message.Chat.ID, questionsMap2, formatting)
+				pagedLists.add(pickerKey(update.Message.Chat.ID, sendPagedList(update.Message.Chat.ID, bot, list)), list, time.Now())
+
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+				msg.ParseMode = "HTML"
+				msg.Text = tr(update.Message.Chat.ID, "Please input the number of the question to edit.\nPress <strong>Exit</strong> when you are done.")
+				msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+					tgbotapi.NewInlineKeyboardRow(newActionButton(update.Message.Chat.ID, btnExit)),
+				)
+
+				activePromptID = sendPrompt(msg, bot)
+				botState = "edit_qns_select"
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "edit_qns"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "edit_qns"),
+				bot,
+			)
+		}
+	})
 
-				switch update.Message.Command() {
-				case "help":
-					sendHelpMessage(update.Message.Chat.ID, bot)
-				case "add_quiz":
+	handleCommand("editors", func(update tgbotapi.Update) {
+		quizName = commandParse(update.Message.Text, "editors")
 
-					quizTitle := commandParse(update.Message.Text, "add_quiz")
+		if len(quizName) > 0 {
+			// only the owner manages the co-editors of a quiz
+			doc, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Get(ctx)
 
-					fmt.Println("SHOW QUIZ TITLE: " + quizTitle)
+			if err == nil && doc.Exists() {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+				msg.ParseMode = "HTML"
+				if editors := quizEditors(doc.Data()); len(editors) > 0 {
+					msg.Text = tr(update.Message.Chat.ID, "Co-editors of quiz %s: %s\n", escapeHTML(quizName), escapeHTML(strings.Join(usernamesForIDs(ctx, client, editors), ", ")))
+				} else {
+					msg.Text = tr(update.Message.Chat.ID, "Quiz %s has no co-editors yet.\n", escapeHTML(quizName))
+				}
+				msg.Text += tr(update.Message.Chat.ID, "Co-editors can use <strong>/add_qns</strong>, <strong>/edit_qns</strong> and <strong>/remove_qns</strong> on this quiz.")
+				msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+					tgbotapi.NewInlineKeyboardRow(newActionButton(update.Message.Chat.ID, btnAddEditors), newActionButton(update.Message.Chat.ID, btnDelEditors)),
+					tgbotapi.NewInlineKeyboardRow(newActionButton(update.Message.Chat.ID, btnCancel)),
+				)
+
+				activePromptID = sendPrompt(msg, bot)
+				botState = "editors_select"
+			} else {
+				sendSimpleMsg(
+					update.Message.Chat.ID,
+					tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName),
+					bot,
+				)
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "editors"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "editors"),
+				bot,
+			)
+		}
+	})
+
+	handleCommand("history", func(update tgbotapi.Update) {
+		quizName = commandParse(update.Message.Text, "history")
+
+		if len(quizName) > 0 {
+			ownerID, doc, err := findEditableQuiz(ctx, client, currentUserID, quizName)
+			var clash *quizNameClash
+			if err == nil {
+				list := quizHistoryList(ctx, client, update.Message.Chat.ID, ownerID, doc.Ref.ID)
+				pagedLists.add(pickerKey(update.Message.Chat.ID, sendPagedList(update.Message.Chat.ID, bot, list)), list, time.Now())
+			} else if errors.As(err, &clash) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You can edit more than one quiz named %s. Please choose whose quiz you mean:", quizName), bot)
+				picker := newClashPicker(ctx, client, "history", clash)
+				quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+			} else {
+				if status.Code(err) != codes.NotFound {
+					log.Printf("An error has occurred trying to find quiz: %s", err)
+				}
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName), bot)
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "history"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "history"),
+				bot,
+			)
+		}
+	})
+
+	handleCommand("sync", func(update tgbotapi.Update) {
+		quizName = commandParse(update.Message.Text, "sync")
+
+		if len(quizName) > 0 {
+			fork, changes, err := loadSourceChanges(ctx, client, bot, currentUserID, update.Message.Chat.ID, quizName)
+
+			if errors.Is(err, errSourceUnreadable) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "The quiz %s was copied from is no longer shared with you, so it cannot be synced.", quizName), bot)
+			} else if status.Code(err) == codes.NotFound {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", quizName), bot)
+			} else if errors.Is(err, errNotAFork) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz %s was not copied from another quiz, so there is nothing to sync.", quizName), bot)
+			} else if err != nil {
+				log.Printf("An error has occurred trying to load source changes: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the changes could not be loaded. Please try again."), bot)
+			} else if len(changes) == 0 {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz %s is up to date with its source.", quizName), bot)
+			} else {
+				syncFork = fork
+				syncChanges = changes
 
-					paramCharLen := len(quizTitle)
+				list := sourceChangesList(update.Message.Chat.ID, quizName, changes)
+				activePromptID = sendPagedList(update.Message.Chat.ID, bot, list)
+				pagedLists.add(pickerKey(update.Message.Chat.ID, activePromptID), list, time.Now())
+				botState = "sync_select"
+			}
+		} else if picker, err := newQuizPicker(ctx, client, currentUserID, "sync"); err == nil && len(picker.quizNames) > 0 {
+			quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\nSpaces in the quiz name are allowed.\ne.g. `/%s demo quiz`", "sync"),
+				bot,
+			)
+		}
+	})
+
+	handleCommand("copy_quiz", func(update tgbotapi.Update) {
+		// the friend comes first, as quiz names may contain spaces
+		copyArgs := strings.SplitN(commandParse(update.Message.Text, "copy_quiz"), " ", 2)
+
+		if len(copyArgs) < 2 || strings.TrimSpace(copyArgs[1]) == "" {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include your friend's id or @username and their quiz name with this command.\ne.g. `/copy_quiz @friend demo quiz`"),
+				bot,
+			)
+		} else if ownerIDs, _, err := resolveShareTargets(ctx, client, copyArgs[0]); err != nil {
+			log.Printf("An error has occurred trying to find user: %s", err)
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the quiz could not be copied. Please try again."), bot)
+		} else if len(ownerIDs) == 0 {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Could not find %s.", copyArgs[0]), bot)
+		} else {
+			copyName := strings.TrimSpace(copyArgs[1])
+			err := copyQuiz(ctx, client, bot, ownerIDs[0], copyName, currentUserID, update.Message.Chat.ID)
+
+			// quizzes the user may not try are reported as not found, so private quiz names are not revealed
+			if errors.Is(err, errQuizExists) {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You already have a quiz named %s.", copyName), bot)
+			} else if status.Code(err) == codes.NotFound {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", copyName), bot)
+			} else if err != nil {
+				log.Printf("An error has occurred trying to copy quiz: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the quiz could not be copied. Please try again."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Copied quiz %s to your quizzes. Use /sync %s to pull in later changes from your friend.", copyName, copyName), bot)
+			}
+		}
+	})
+
+	handleCommand("challenge", func(update tgbotapi.Update) {
+		// the opponent comes first, as quiz names may contain spaces
+		challengeArgs := strings.SplitN(commandParse(update.Message.Text, "challenge"), " ", 2)
+
+		if isGroupChat(update.Message.Chat) {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Challenges are played in private chats with me. Please send /challenge to me directly."), bot)
+		} else if _, busy := challengeSessions[currentUserID]; busy {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please finish your current challenge first."), bot)
+		} else if len(challengeArgs) < 2 || strings.TrimSpace(challengeArgs[1]) == "" {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include your friend's id or @username and one of your quiz names with this command.\ne.g. `/challenge @friend demo quiz`"),
+				bot,
+			)
+		} else if opponentIDs, unresolved, err := resolveShareTargets(ctx, client, challengeArgs[0]); err != nil || len(unresolved) > 0 || len(opponentIDs) == 0 {
+			if err != nil {
+				log.Printf("An error has occurred trying to find user: %s", err)
+			}
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Could not find %s. They need to /start the bot before you can challenge them.", challengeArgs[0]), bot)
+		} else if opponentIDs[0] == currentUserID {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You cannot challenge yourself!"), bot)
+		} else {
+			challengeQuizName := strings.TrimSpace(challengeArgs[1])
+			doc, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(challengeQuizName).Get(ctx)
+
+			var questions []challengeQuestion
+			var challengeID string
+			if err == nil {
+				questions = newChallengeQuestions(doc.Data())
+			}
+			if err == nil && len(questions) > 0 {
+				challengeID, err = createChallenge(ctx, client, currentUserID, liveUserName(update.Message.From), opponentIDs[0], challengeQuizName, questions)
+			}
+			if err == nil && len(questions) > 0 {
+				err = sendChallengeInvite(ctx, client, bot, opponentIDs[0], liveUserName(update.Message.From), challengeQuizName, len(questions), challengeID)
+			}
 
-					if paramCharLen < 1 {
+			if status.Code(err) == codes.NotFound {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", challengeQuizName), bot)
+			} else if err == nil && len(questions) == 0 {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "This quiz has no questions to try!"), bot)
+			} else if err != nil {
+				log.Printf("An error has occurred trying to create challenge: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the challenge could not be sent. Your friend needs to have started a chat with me."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Challenge sent to %s! Your questions start now, I will send you both the results once you have both finished.", challengeArgs[0]), bot)
 
-						sendSimpleMsg(
-							update.Message.Chat.ID,
-							"Quiz title cannot be empty, please try again!",
-							bot,
-						)
+				session := newChallengeSession(challengeID, currentUserID, update.Message.Chat.ID, questions)
+				challengeSessions[currentUserID] = session
+				advanceChallenge(ctx, client, bot, session)
+			}
+		}
+	})
+
+	catalogCommand := func(update tgbotapi.Update) {
+		publicQuizzes, err := loadPublicQuizzes(ctx, client)
+		keywords := commandParse(update.Message.Text, update.Message.Command())
+
+		if err != nil {
+			log.Printf("An error has occurred trying to load public quizzes: %s", err)
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the quiz catalog could not be loaded. Please try again."), bot)
+		} else if len(publicQuizzes) == 0 {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "There are no public quizzes yet. Make one of yours public with /visibility quiz_name"), bot)
+		} else if update.Message.Command() == "browse" {
+			view := &catalogView{public: publicQuizzes, categories: catalogCategories(publicQuizzes)}
+			catalogViews.add(pickerKey(update.Message.Chat.ID, sendCatalogCategories(update.Message.Chat.ID, bot, view)), view, time.Now())
+		} else if len(strings.TrimSpace(keywords)) > 0 {
+			view := &catalogView{public: publicQuizzes, title: "\"" + keywords + "\"", results: searchCatalog(publicQuizzes, keywords)}
+			catalogViews.add(pickerKey(update.Message.Chat.ID, sendCatalogResults(ctx, client, update.Message.Chat.ID, bot, view)), view, time.Now())
+		} else {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include keywords to search for.\nQuiz titles, tags and questions are searched.\ne.g. `/search cell biology`"),
+				bot,
+			)
+		}
+	}
+	handleCommand("browse", catalogCommand)
+	handleCommand("search", catalogCommand)
 
-					} else {
+	handleCommand("daily", func(update tgbotapi.Update) {
+		dailyArgs := commandParse(update.Message.Text, "daily")
 
-						docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizTitle)
-						_, err := docRef.Get(ctx)
-						if err == nil {
-							sendSimpleMsg(
-								update.Message.Chat.ID,
-								"Quiz title exists",
-								bot,
-							)
-						} else {
-							_, _ = client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizTitle).Set(ctx, map[string]interface{}{
-								"numQns": 0,
-								"score":  "none",
-							})
+		if len(dailyArgs) == 0 {
+			if subscriptions, err := loadDailySubscriptions(ctx, client, currentUserID); err != nil {
+				log.Printf("An error has occurred trying to load daily questions: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, your daily questions could not be loaded. Please try again."), bot)
+			} else {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, formatDailySubscriptions(update.Message.Chat.ID, subscriptions))
+				msg.ParseMode = "HTML"
+				queueMessage(bot, msg.ChatID, msg)
+			}
+		} else if dailyQuizName, hour, minute, timezone, err := parseDailyArgs(dailyArgs); err != nil {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name and a time with this command, and optionally your time zone.\ne.g. `/daily demo quiz 08:30` or `/daily demo quiz 08:30 Europe/London`"),
+				bot,
+			)
+		} else if _, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(dailyQuizName).Get(ctx); err != nil {
+			if status.Code(err) != codes.NotFound {
+				log.Printf("An error has occurred trying to find quiz: %s", err)
+			}
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz with name %s not found.", dailyQuizName), bot)
+		} else {
+			// the time zone is remembered for the user's next daily questions
+			if timezone == "" {
+				timezone = userTimezone(ctx, client, currentUserID)
+			} else if err := setUserTimezone(ctx, client, currentUserID, timezone); err != nil {
+				log.Printf("An error has occurred trying to save time zone: %s", err)
+			}
 
-							sendSimpleMsg(
-								update.Message.Chat.ID,
-								"New Quiz Title: "+quizTitle+" is added into your collection.",
-								bot,
-							)
-						}
+			if nextSendAt, err := subscribeDaily(ctx, client, currentUserID, dailyQuizName, hour, minute, timezone); err != nil {
+				log.Printf("An error has occurred trying to save daily question: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, your daily question could not be saved. Please try again."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID,
+					"You will get a question from %s every day at %02d:%02d %s in your private chat with me, starting %s.",
+					dailyQuizName, hour, minute, timezone, nextSendAt.Format("Mon 2 Jan")), bot)
+			}
+		}
+	})
+
+	handleCommand("daily_off", func(update tgbotapi.Update) {
+		dailyQuizName := commandParse(update.Message.Text, "daily_off")
+
+		if len(dailyQuizName) == 0 {
+			sendSimpleMsg(
+				update.Message.Chat.ID,
+				tr(update.Message.Chat.ID, "Please include a quiz name with this command.\ne.g. `/daily_off demo quiz`"),
+				bot,
+			)
+		} else if err := unsubscribeDaily(ctx, client, currentUserID, dailyQuizName); status.Code(err) == codes.NotFound {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You have no daily question from %s.", dailyQuizName), bot)
+		} else if err != nil {
+			log.Printf("An error has occurred trying to remove daily question: %s", err)
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, please try again."), bot)
+		} else {
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "You will no longer get daily questions from %s.", dailyQuizName), bot)
+		}
+	})
+
+	handleCommand("profile", func(update tgbotapi.Update) {
+		sendProfile(ctx, client, update.Message.Chat.ID, bot, currentUserID, currentUsername)
+	})
+
+	handleCommand("nudge", func(update tgbotapi.Update) {
+		switch strings.ToLower(strings.TrimSpace(commandParse(update.Message.Text, "nudge"))) {
+		case "on":
+			if err := setNudges(ctx, client, currentUserID, true); err != nil {
+				log.Printf("An error has occurred trying to turn on nudges: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, please try again."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "I will remind you at %02d:00 in your time zone when your streak is about to break.", nudgeHour), bot)
+			}
+		case "off":
+			if err := setNudges(ctx, client, currentUserID, false); err != nil {
+				log.Printf("An error has occurred trying to turn off nudges: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, please try again."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Streak reminders are off."), bot)
+			}
+		default:
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please choose on or off.\ne.g. `/%s on`", "nudge"), bot)
+		}
+	})
+
+	handleCommand("formatting", func(update tgbotapi.Update) {
+		switch strings.ToLower(strings.TrimSpace(commandParse(update.Message.Text, "formatting"))) {
+		case "on":
+			if err := setKeepFormatting(ctx, client, currentUserID, true); err != nil {
+				log.Printf("An error has occurred trying to turn on formatting: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, please try again."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Questions and answers you add from now on keep their bold, italics, underline, strikethrough, code, spoilers and links."), bot)
+			}
+		case "off":
+			if err := setKeepFormatting(ctx, client, currentUserID, false); err != nil {
+				log.Printf("An error has occurred trying to turn off formatting: %s", err)
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, please try again."), bot)
+			} else {
+				sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Questions and answers you add from now on are saved as plain text."), bot)
+			}
+		default:
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please choose on or off.\ne.g. `/%s on`", "formatting"), bot)
+		}
+	})
 
-					}
-					botState = "idle"
+	handleCommand("get_my_id", func(update tgbotapi.Update) {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+		msg.ParseMode = "HTML"
+		msg.Text = tr(update.Message.Chat.ID, "Here is your user info: \n<strong>id</strong>: %s\n<strong>firstname</strong> %s\n<strong>username</strong> %s\n", currentUserID, escapeHTML(update.Message.From.FirstName), escapeHTML(currentUsername))
 
-				case "add_qns":
-					// parse quiz name
-					quizName = commandParse(update.Message.Text, "add_qns")
+		// group chat ids are used to share quizzes with a whole group
+		if !update.Message.Chat.IsPrivate() {
+			msg.Text += tr(update.Message.Chat.ID, "<strong>group chat id</strong>: %d\n", update.Message.Chat.ID)
+		}
 
-					fmt.Println("SEARCHING FOR QUIZ: " + quizName)
+		queueMessage(bot, msg.ChatID, msg)
+	})
 
-					paramCharLen := len(quizName)
+	handleCommand("try_quiz", func(update tgbotapi.Update) {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+		msg.ParseMode = "HTML"
+		msg.Text = tr(update.Message.Chat.ID, "Would you like to try your own quiz or a friend's quiz?")
+		msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnMyQuiz, btnFriendQuiz)
 
-					if paramCharLen > 0 {
-						docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName)
-						doc, err := docRef.Get(ctx)
-						if err != nil {
-							if status.Code(err) == codes.NotFound {
-								// // Handle document not existing here
-								// _, err := docRef.Set(ctx /* custom object here */)
-								// if err != nil {
-								// 	return err
-								// }
-							} else {
-								// return err
-							}
-						}
+		activePromptID = sendPrompt(msg, bot)
 
-						if doc.Exists() {
-							// Handle document existing here
-							fmt.Println("Doc found:", doc.Ref.ID)
+		// reset questionMaps
+		questionsMap1 = make(map[string]string)
+		questionsMap2 = make(map[int]string)
+		questionsMap3 = make(map[string]bool)
 
-							fmt.Println("var1 = ", reflect.TypeOf(doc.Data()))
+		botState = "try_quiz_select"
+	})
 
-							msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-							msg.Text = "Quiz titled " + quizName + " found!\n" +
-								"Press <strong>Exit</strong> to save changes and end\n" +
-								"Press <strong>Cancel</strong> to quit without saving\n" +
-								"Please input new question:"
-							msg.ParseMode = "HTML"
-							msg.ReplyMarkup = createTwoBtnRowKeyboard("Exit", "Cancel")
+	// every listed command needs a handler, or it would be offered in the menu but not understood
+	if missing := unhandledCommands(); len(missing) > 0 {
+		log.Fatalf("An error has occurred trying to start the bot: no handler for %s", strings.Join(missing, ", "))
+	}
 
-							if _, err := bot.Send(msg); err != nil {
-								log.Panic(err)
-							}
+	for {
+		var update tgbotapi.Update
+
+		select {
+		case timeout := <-liveTimeouts:
+			// close the question once its time is up, unless the game has moved on or ended
+			if game := timeout.game; liveGames[game.chatID] == game && game.qnIndex == timeout.qnIndex {
+				game.closeQuestion(bot)
+				if !game.nextQuestion(bot, liveTimeouts) {
+					finishLiveGame(ctx, client, bot, game)
+					delete(liveGames, game.chatID)
+				}
+			}
+			continue
 
-							numQns = int(doc.Data()["numQns"].(int64))
+		case now := <-scheduleTicker.C:
+			sendDueDailyQuestions(ctx, client, bot, now)
+			sendDueNudges(ctx, client, bot, now)
+			// weekly summaries only need checking once an hour
+			if now.Minute() == 0 {
+				postWeeklySummaries(ctx, client, bot, now)
+			}
+			continue
 
-							botState = "add_qns_Qn"
-							inputExpected = "qn"
+		case update = <-updates:
+		}
 
-						} else {
-							sendSimpleMsg(
-								update.Message.Chat.ID,
-								"Quiz with name "+quizName+" not found.",
-								bot,
-							)
-						}
-					} else {
-						sendSimpleMsg(
-							update.Message.Chat.ID,
-							"Please include a quiz name with this command.\n"+
-								"Spaces in the quiz name are allowed.\n"+
-								"e.g. `/add_qns demo quiz`",
-							bot,
-						)
+		// users blocking and unblocking the bot in their private chat
+		if member := update.MyChatMember; member != nil {
+			if member.Chat.IsPrivate() {
+				setUserInactive(ctx, client, fmt.Sprint(member.From.ID), member.NewChatMember.Status == "kicked")
+			}
+			continue
+		}
+
+		// search the user's quizzes as they type, from the quiz picker
+		if update.InlineQuery != nil {
+			answerQuizSearch(ctx, client, bot, update.InlineQuery)
+			continue
+		}
+
+		// answers to the quiz polls of live quizzes
+		if update.PollAnswer != nil {
+			for _, game := range liveGames {
+				if game.pollMode && game.accepting() && game.handlePollAnswer(update.PollAnswer) {
+					break
+				}
+			}
+			continue
+		}
+
+		// button pressed for this update, empty when the user typed a message
+		var pressedBtn buttonAction = ""
+		var pressedPromptID int = 0
+
+		if update.CallbackQuery != nil && update.CallbackQuery.Message != nil {
+			query := update.CallbackQuery
+			callback := tgbotapi.NewCallback(query.ID, "")
+			loadChatLanguage(ctx, client, query.Message.Chat.ID, query.From.LanguageCode)
+			kind, value := parseCallbackData(query.Data)
+
+			if game, found := liveGames[query.Message.Chat.ID]; found && kind == "live" {
+				// every member of the group can answer a live quiz
+				callback.Text = game.handleLiveCallback(query)
+			} else if kind == "live" {
+				callback.Text = tr(query.Message.Chat.ID, "This question is closed")
+			} else if kind == "chal" {
+				// challenges are answered by each player in their own chat, whoever the current user is
+				session, found := challengeSessions[fmt.Sprint(query.From.ID)]
+				if !found || session.chatID != query.Message.Chat.ID {
+					callback.Text = tr(query.Message.Chat.ID, "This question is closed")
+				} else if callback.Text = session.handleChallengeCallback(bot, query); callback.Text == "" {
+					if advanceChallenge(ctx, client, bot, session) {
+						delete(challengeSessions, session.userID)
 					}
-				case "remove_qns":
-					// parse quiz name
-					quizName = commandParse(update.Message.Text, "remove_qns")
+				}
+			} else if kind == "chalinv" {
+				challengeID, answer := parseCallbackData(value)
+				opponentID := fmt.Sprint(query.From.ID)
+
+				if _, busy := challengeSessions[opponentID]; busy && answer == "accept" {
+					callback.Text = tr(query.Message.Chat.ID, "Please finish your current challenge first")
+				} else if data, err := answerChallengeInvite(ctx, client, challengeID, opponentID, answer == "accept"); errors.Is(err, errChallengeClosed) || status.Code(err) == codes.NotFound {
+					callback.Text = tr(query.Message.Chat.ID, "This challenge is no longer open")
+					removeInlineKeyboard(query.Message.Chat.ID, query.Message.MessageID, bot)
+				} else if err != nil {
+					log.Printf("An error has occurred trying to answer challenge: %s", err)
+					callback.Text = tr(query.Message.Chat.ID, "Sorry, please try again")
+				} else {
+					removeInlineKeyboard(query.Message.Chat.ID, query.Message.MessageID, bot)
+					challengerID, _ := data["challengerID"].(string)
+					challengeQuizName, _ := data["quizName"].(string)
 
-					fmt.Println("SEARCHING FOR QUIZ: " + quizName)
+					if answer == "accept" {
+						notifyUser(ctx, client, challengerID, bot, "%s accepted your challenge on quiz %s!", liveUserName(query.From), challengeQuizName)
 
-					paramCharLen := len(quizName)
+						session := newChallengeSession(challengeID, opponentID, query.Message.Chat.ID, challengeQuestionsFromData(data))
+						challengeSessions[opponentID] = session
+						advanceChallenge(ctx, client, bot, session)
+					} else {
+						notifyUser(ctx, client, challengerID, bot, "%s declined your challenge on quiz %s.", liveUserName(query.From), challengeQuizName)
+						sendSimpleMsg(query.Message.Chat.ID, tr(query.Message.Chat.ID, "Challenge declined."), bot)
+					}
+				}
+			} else if kind == "daily" {
+				// daily questions are answered outside of a quiz session, whoever the current user is
+				callback.Text = handleDailyCallback(ctx, client, bot, query)
+			} else if fmt.Sprint(query.From.ID) != currentUserID {
+				callback.Text = tr(query.Message.Chat.ID, "Only the current user can use these buttons")
+			} else {
+				switch kind {
+				case "pick", "page":
+					key := pickerKey(query.Message.Chat.ID, query.Message.MessageID)
+					picker, found := quizPickers.get(key, time.Now())
+
+					if !found {
+						callback.Text = tr(query.Message.Chat.ID, "This list has expired, please run the command again")
+					} else if pickedQuiz, picked := handlePickerCallback(query, bot, picker); picked {
+						// continue as if the user typed the quiz name
+						quizPickers.remove(key)
+						update.Message = callbackMessage(query, picker.selectionText(pickedQuiz))
+					}
 
-					if paramCharLen > 0 {
-						docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName)
-						doc, err := docRef.Get(ctx)
-						if err != nil {
-							if status.Code(err) == codes.NotFound {
-								// // Handle document not existing here
-								// _, err := docRef.Set(ctx /* custom object here */)
-								// if err != nil {
-								// 	return err
-								// }
-							} else {
-								// return err
-							}
-						}
+				case "lpage":
+					if list, found := pagedLists.get(pickerKey(query.Message.Chat.ID, query.Message.MessageID), time.Now()); found {
+						handlePagedListCallback(query, bot, list)
+					} else {
+						callback.Text = tr(query.Message.Chat.ID, "This list has expired, please run the command again")
+					}
 
-						if doc.Exists() {
-							// Handle document existing here
-							fmt.Println("Doc found:", doc.Ref.ID)
-
-							numQns = int(doc.Data()["numQns"].(int64))
-							qnsRemaining = 0
-
-							if numQns == 0 {
-								sendSimpleMsg(
-									update.Message.Chat.ID,
-									"This quiz has no questions to remove!",
-									bot,
-								)
-							} else {
-								msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-								msg.Text = "Quiz titled " + quizName + " found!\n" +
-									"For each question:\n" +
-									"Press <strong>Keep</strong> to keep the question\n" +
-									"Press <strong>Toss</strong> to remove the question\n" +
-									"Press <strong>Cancel</strong> to revert changes\n"
-								msg.ParseMode = "HTML"
-
-								msg.ReplyMarkup = questionReviewKeyboard
-
-								if _, err := bot.Send(msg); err != nil {
-									log.Panic(err)
-								}
-
-								for question, answer := range doc.Data() {
-									if question != "numQns" && question != "score" {
-										questionsMap1[question] = answer.(string)
-										qnsRemaining++
-										questionsMap2[qnsRemaining] = question
-									}
-								}
-
-								sendQuestionAndAnswerSet(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
-								qnsRemaining--
-
-								numQns = 0
-								botState = "remove_qns"
-							}
+				case "cat", "cpage", "try", "copy":
+					key := pickerKey(query.Message.Chat.ID, query.Message.MessageID)
+					view, found := catalogViews.get(key, time.Now())
+
+					if !found {
+						callback.Text = tr(query.Message.Chat.ID, "This list has expired, please run the command again")
+					} else if entry, chosen := handleCatalogCallback(ctx, client, query, bot, view); !chosen {
+						break
+					} else if botState != "idle" {
+						callback.Text = tr(query.Message.Chat.ID, "Please finish what you are doing first")
+					} else if kind == "copy" {
+						err := copyQuiz(ctx, client, bot, entry.ownerID, entry.quizName, currentUserID, query.Message.Chat.ID)
+						if errors.Is(err, errQuizExists) {
+							sendSimpleMsg(query.Message.Chat.ID, tr(query.Message.Chat.ID, "You already have a quiz named %s.", entry.quizName), bot)
+						} else if status.Code(err) == codes.NotFound {
+							sendSimpleMsg(query.Message.Chat.ID, tr(query.Message.Chat.ID, "Quiz %s is no longer public.", entry.quizName), bot)
+						} else if err != nil {
+							log.Printf("An error has occurred trying to copy quiz: %s", err)
+							sendSimpleMsg(query.Message.Chat.ID, tr(query.Message.Chat.ID, "Sorry, quiz %s could not be copied. Please try again.", entry.quizName), bot)
 						} else {
-							sendSimpleMsg(
-								update.Message.Chat.ID,
-								"Quiz with name "+quizName+" not found.",
-								bot,
-							)
+							sendSimpleMsg(query.Message.Chat.ID, tr(query.Message.Chat.ID, "Copied quiz %s to your quizzes. It is private until you change its /visibility, and /sync pulls in later changes to the original.", entry.quizName), bot)
 						}
 					} else {
-						sendSimpleMsg(
-							update.Message.Chat.ID,
-							"Please include a quiz name with this command.\n"+
-								"Spaces in the quiz name are allowed.\n"+
-								"e.g. `/remove_qns demo quiz`",
-							bot,
-						)
+						// continue as if the user typed the quiz name after choosing a friend's quiz
+						friendUserID = entry.ownerID
+						tryingMyQuiz = entry.ownerID == currentUserID
+						typedAnswers = false
+						quizLimit = 0
+						botState = "try_quiz_friendQuiz"
+						update.Message = callbackMessage(query, entry.quizName)
 					}
-				case "delete_quiz":
-					// parse quiz name
-					quizName = commandParse(update.Message.Text, "delete_quiz")
-					paramCharLen := len(quizName)
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.ParseMode = "HTML"
-
-					if paramCharLen > 0 {
-						docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName)
-						doc, err := docRef.Get(ctx)
-						if doc.Exists() {
-							docRef.Delete(ctx)
-						}
 
-						if err == nil {
-							msg.Text = "Successfully deleted quiz: " + quizName
-						}
+				case "btn":
+					if query.Message.MessageID != activePromptID {
+						callback.Text = tr(query.Message.Chat.ID, "This button is no longer active")
+						removeInlineKeyboard(query.Message.Chat.ID, query.Message.MessageID, bot)
+					} else {
+						pressedBtn = buttonAction(value)
+						pressedPromptID = activePromptID
 
-						if err != nil {
-							msg.Text = "Quiz could not be found. Error deleting quiz: " + quizName
+						// each prompt takes a single press, unless the flow keeps it open
+						activePromptID = 0
+						if !pressedBtn.editsInPlace() {
+							removeInlineKeyboard(query.Message.Chat.ID, query.Message.MessageID, bot)
 						}
 
-						if _, err := bot.Send(msg); err != nil {
-							log.Panic(err)
-						}
-					} else {
-						sendSimpleMsg(
-							update.Message.Chat.ID,
-							"Please include a quiz name with this command.\n"+
-								"Spaces in the quiz name are allowed.\n"+
-								"e.g. `/delete_quiz demo quiz`",
-							bot,
-						)
-					}
-				case "list_quizzes":
-					var docNames []string
-					iter := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Documents(ctx)
-					for {
-						doc, err := iter.Next()
-						if err == iterator.Done {
-							break
-						}
-						if err != nil {
-							//return err
-						}
-						docNames = append(docNames, doc.Ref.ID)
+						update.Message = callbackMessage(query, "")
 					}
 
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.ParseMode = "HTML"
-					if len(docNames) > 0 {
-						msg.Text = "Here is the list of your quizzes: \n"
-						for i, s := range docNames {
-							msg.Text += "- " + s + "\n"
-							fmt.Println(i, s)
-						}
-					} else {
-						msg.Text = "No quizzes found. Create one with /add_quiz quiz name"
-					}
+				default:
+					callback.Text = tr(query.Message.Chat.ID, "This button is no longer active")
+				}
+			}
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+			if _, err := bot.Request(callback); err != nil {
+				log.Printf("An error has occurred trying to answer callback query: %s", err)
+			}
+		}
 
-				case "get_my_id":
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.ParseMode = "HTML"
-					msg.Text = "Here is your user info: \n" +
-						"<strong>id</strong>: " + currentUserID + "\n" +
-						"<strong>firstname</strong> " + update.Message.From.FirstName + "\n" +
-						"<strong>username</strong> " + currentUsername + "\n"
+		// ignore non-Message updates
+		if update.Message == nil {
+			continue
+		}
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+		// replies are in the language chosen for the chat, or else the language of the user's telegram app
+		if update.Message.From != nil {
+			loadChatLanguage(ctx, client, update.Message.Chat.ID, update.Message.From.LanguageCode)
+		}
 
-				case "try_quiz":
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.ParseMode = "HTML"
-					msg.Text = "Would you like to try your own quiz or a friend's quiz?"
-					msg.ReplyMarkup = createTwoBtnRowKeyboard("My own quiz", "A friend's quiz")
+		// commands for other bots in the same group are not ours to answer, and our commands with a problem in
+		// their arguments are answered with their usage
+		var cmdErr *commandError
+		if _, err := parseCommand(update.Message.Text, botUsername); errors.Is(err, errOtherBot) {
+			continue
+		} else if _, found := findCommand(update.Message.Command()); found && errors.As(err, &cmdErr) {
+			sendSimpleMsg(update.Message.Chat.ID, commandUsageText(update.Message.Chat.ID, err, commandUsage(cmdErr.command)), bot)
+			continue
+		}
+
+		// bot admins can change the settings of any group, so they get the group admin menu in the groups they write in
+		if isGroupChat(update.Message.Chat) && cfg.isAdmin(update.Message.From.ID) {
+			publishAdminCommands(bot, update.Message.Chat.ID, update.Message.From.ID)
+		}
+
+		// commands for every member of the chat, not just the current user
+		if update.Message.IsCommand() {
+			if command, found := findCommand(update.Message.Command()); found && command.anyone {
+				command.handler(update)
+				continue
+			}
+		}
+
+		// live quizzes in groups are played by every member, not just the current user
+		if game, found := liveGames[update.Message.Chat.ID]; found {
+			if game.handleLiveReply(update.Message) {
+				continue
+			}
+		}
+
+		// typed answers to a challenge, which players answer in their private chats
+		if session, found := challengeSessions[fmt.Sprint(update.Message.From.ID)]; found && session.chatID == update.Message.Chat.ID &&
+			!update.Message.IsCommand() && update.Message.Text != "" && pressedBtn == "" && update.CallbackQuery == nil {
+			removeInlineKeyboard(session.chatID, session.qnMsgID, bot)
+			session.recordAnswer(bot, update.Message.Text)
+			if advanceChallenge(ctx, client, bot, session) {
+				delete(challengeSessions, session.userID)
+			}
+			continue
+		}
+
+		if currentUserID == "" {
+			currentUserID = fmt.Sprint(update.Message.From.ID)
+		}
+		if currentUsername == "" {
+			currentUsername = update.Message.From.UserName
+		}
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
+		fmt.Printf("[%s, %s] %s%s\n", currentUsername, currentUserID, update.Message.Text, pressedBtn)
+
+		if update.Message.IsCommand() && update.Message.Command() == "start" {
+			// Check if the focus user id is already in the USERS collection, else create new user
+			currentUsername = update.Message.From.UserName
+			currentUserID = fmt.Sprint(update.Message.From.ID)
+
+			docRef := client.Collection("USERS").Doc(currentUserID)
+			var doc *firestore.DocumentSnapshot
+			err := retryStorage(func() (err error) {
+				doc, err = docRef.Get(ctx)
+				return err
+			})
+			if err != nil && status.Code(err) != codes.NotFound {
+				reportStorageError(update.Message.Chat.ID, bot, "log you in", err)
+				continue
+			}
+
+			if doc.Exists() {
+				// Handle document existing here
+				fmt.Println("User found")
+
+				if username, _ := doc.Data()["username"].(string); username != currentUsername {
+					// update username in database
+					_, err = client.Collection("USERS").Doc(currentUserID).Update(ctx, []firestore.Update{
+						{
+							Path:  "username",
+							Value: currentUsername,
+						},
+					})
+
+					if err != nil {
+						// Handle any errors in an appropriate way, such as returning them.
+						log.Printf("An error has occurred trying to update username: %s", err)
 					}
 
-					// reset questionMaps
+				}
+
+			} else {
+
+				// Create new user document with its quizzes collection, together so that neither is left half made
+				batch := client.Batch()
+				batch.Set(docRef, map[string]interface{}{
+					"username": currentUsername,
+				})
+				batch.Set(docRef.Collection("QUIZZES").Doc("demo quiz"), map[string]interface{}{
+					"numQns":                       1,
+					"score":                        "none",
+					"visibility":                   visibilityPrivate,
+					"this is a demo quiz question": "this is a demo quiz answer",
+				})
+
+				err := retryStorage(func() error {
+					_, err := batch.Commit(ctx)
+					return err
+				})
+				if err != nil {
+					reportStorageError(update.Message.Chat.ID, bot, "create your account", err)
+					continue
+				}
+			}
+
+			sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Hello %s!", currentUsername), bot)
+
+			botState = "idle"
+
+			// open a quiz shared by link, e.g. t.me/<bot>?start=<share code>
+			if shareCode := update.Message.CommandArguments(); shareCode != "" {
+				ownerID, sharedQuizName, err := lookupShareCode(ctx, client, shareCode)
+
+				var sharedDoc *firestore.DocumentSnapshot
+				if err == nil {
+					sharedDoc, err = client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(sharedQuizName).Get(ctx)
+				}
+
+				if errors.Is(err, errShareCodeNotFound) || status.Code(err) == codes.NotFound || (err == nil && !canReadSharedQuiz(sharedDoc.Data())) {
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "This share link is no longer valid. Please ask your friend for a new one."), bot)
+				} else if err != nil {
+					log.Printf("An error has occurred trying to open shared quiz: %s", err)
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, the shared quiz could not be loaded. Please try again."), bot)
+				} else if len(quizQuestions(sharedDoc.Data())) == 0 {
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "This quiz has no questions to try!"), bot)
+				} else {
+					quizName = sharedQuizName
+					friendUserID = ownerID
+					tryingMyQuiz = ownerID == currentUserID
+					scoreInt = 0
+					typedAnswers = false
+					quizLimit = 0
+
+					sendQuizInstructions(update.Message.Chat.ID, quizName, "", typedAnswers, bot)
+
+					// save questions to question map
+					questionsMap1, questionsMap2, questionsMap3, qnsRemaining = newQuestionMaps(sharedDoc.Data())
+					attachments = quizAttachments(sharedDoc.Data())
+					formatting = quizFormatting(sharedDoc.Data())
+					numQns = qnsRemaining
+
+					// send first question
+					activePromptID = sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
+
+					botState = "try_quiz_quizAttempt"
+					inputExpected = "post-qn"
+				}
+			}
+
+		} else if currentUserID == fmt.Sprint(update.Message.From.ID) {
+			// check if message is from current user if not ignore other users
+
+			// /try_quiz with a quiz name goes straight to the user's own quiz, as if they had picked it
+			if botState == "idle" && update.Message.IsCommand() && update.Message.Command() == "try_quiz" {
+				cmd, err := parseCommand(update.Message.Text, botUsername)
+				if err == nil {
+					err = cmd.checkOptions("n", "mode")
+				}
+				if err == nil {
+					quizLimit, err = cmd.intOption("n", 0)
+				}
+				mode := ""
+				if err == nil {
+					mode, err = cmd.choiceOption("mode", "reveal", "typed")
+				}
+				if err != nil {
+					sendSimpleMsg(update.Message.Chat.ID, commandUsageText(update.Message.Chat.ID, err, commandUsage("try_quiz")), bot)
+					continue
+				}
+				typedAnswers = mode == "typed"
+
+				if quizArg := cmd.argument(); quizArg != "" {
 					questionsMap1 = make(map[string]string)
 					questionsMap2 = make(map[int]string)
 					questionsMap3 = make(map[string]bool)
+					tryingMyQuiz = true
+					botState = "try_quiz_myQuiz"
 
-					botState = "try_quiz_select"
+					typed := *update.Message
+					typed.Text = quizArg
+					typed.Entities = nil
+					update.Message = &typed
+				}
+			}
 
-				default:
+			// with typed answers, an answer typed to a question is marked as if the user had revealed it and pressed Correct or Wrong
+			if botState == "try_quiz_quizAttempt" && inputExpected == "post-qn" && typedAnswers && pressedBtn == "" && update.Message.Text != "" && !update.Message.IsCommand() {
+				correct := normalizeAnswer(update.Message.Text) == normalizeAnswer(questionsMap1[questionsMap2[qnsRemaining]])
+
+				pressedPromptID = revealAnswer(update.Message.Chat.ID, activePromptID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
+				activePromptID = pressedPromptID
+				qnsRemaining--
+				inputExpected = "post-ans"
+
+				pressedBtn = btnWrong
+				if correct {
+					pressedBtn = btnCorrect
+				}
+			}
+
+			switch botState {
+			case "idle":
+				if !update.Message.IsCommand() { // ignore any non-command Messages
+					continue
+				}
+
+				if command, found := findCommand(update.Message.Command()); found && command.handler != nil {
+					command.handler(update)
+				} else {
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Sorry I don't understand you! Type <strong>/help</strong> for a list of commands!"
+					msg.Text = tr(update.Message.Chat.ID, "Sorry I don't understand you! Type <strong>/help</strong> for a list of commands!")
 					msg.ParseMode = "HTML"
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 				}
 
 			case "try_quiz_select":
-				switch update.Message.Text {
-				case "My own quiz":
+				switch pressedBtn {
+				case btnMyQuiz:
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Please input the quiz name:\n" +
-						"(Press <strong>Cancel</strong> to exit)"
-					msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
-						tgbotapi.NewKeyboardButtonRow(
-							tgbotapi.NewKeyboardButton("Cancel"),
-						),
-					)
+					msg.Text = tr(update.Message.Chat.ID, "Please input the quiz name, or select it below:\n(Press <strong>Cancel</strong> to exit)")
+					msg.ReplyMarkup = cancelKeyboard(update.Message.Chat.ID)
 					msg.ParseMode = "HTML"
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
+					activePromptID = sendPrompt(msg, bot)
+
+					if picker, err := newQuizPicker(ctx, client, currentUserID, "try_quiz"); err == nil && len(picker.quizNames) > 0 {
+						quizPickers.add(pickerKey(update.Message.Chat.ID, sendQuizPicker(update.Message.Chat.ID, bot, picker)), picker, time.Now())
 					}
 
 					botState = "try_quiz_myQuiz"
 					tryingMyQuiz = true
 
-				case "A friend's quiz":
+				case btnFriendQuiz:
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Please input your friend's user id number.\n" +
-						"Your friend can get their id number using the <strong>/get_my_id</strong> command.\n" +
-						"(Press <strong>Cancel</strong> to exit)"
-					msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
-						tgbotapi.NewKeyboardButtonRow(
-							tgbotapi.NewKeyboardButton("Cancel"),
-						),
-					)
+					msg.Text = tr(update.Message.Chat.ID, "Please input your friend's user id number.\nYour friend can get their id number using the <strong>/get_my_id</strong> command.\n(Press <strong>Cancel</strong> to exit)")
+					msg.ReplyMarkup = cancelKeyboard(update.Message.Chat.ID)
 					msg.ParseMode = "HTML"
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					activePromptID = sendPrompt(msg, bot)
 
 					botState = "try_quiz_friend"
 					tryingMyQuiz = false
@@ -706,35 +1853,27 @@ func main() {
 
 				}
 			case "try_quiz_myQuiz":
-				switch update.Message.Text {
-				case "Cancel":
+				switch pressedBtn {
+				case btnCancel:
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Cancelling quiz attempt"
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+					msg.Text = tr(update.Message.Chat.ID, "Cancelling quiz attempt")
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					botState = "idle"
 
-				default:
+				case "":
 					quizName = update.Message.Text
 					docRef := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName)
-					doc, err := docRef.Get(ctx)
-					if err != nil {
-						if status.Code(err) == codes.NotFound {
-							// // Handle document not existing here
-							// _, err := docRef.Set(ctx /* custom object here */)
-							// if err != nil {
-							// 	return err
-							// }
-						} else {
-							// return err
-						}
+					var doc *firestore.DocumentSnapshot
+					err := retryStorage(func() (err error) {
+						doc, err = docRef.Get(ctx)
+						return err
+					})
+					if err != nil && status.Code(err) != codes.NotFound {
+						// the user can enter the name again once storage is back
+						reportStorageError(update.Message.Chat.ID, bot, "load the quiz", err)
+						break
 					}
 
 					if doc.Exists() {
@@ -747,7 +1886,7 @@ func main() {
 						scoreInt = 0
 
 						if prevScore != "none" {
-							prevScore = "You previously got " + prevScore + " on this quiz.\n"
+							prevScore = tr(update.Message.Chat.ID, "You previously got %s on this quiz.\n", prevScore)
 						} else {
 							prevScore = ""
 						}
@@ -755,42 +1894,23 @@ func main() {
 						if numQns == 0 {
 							sendSimpleMsg(
 								update.Message.Chat.ID,
-								"This quiz has no questions to try! Please enter another quiz name.",
+								tr(update.Message.Chat.ID, "This quiz has no questions to try! Please enter another quiz name."),
 								bot,
 							)
 						} else {
-							// send quiz instructions
-							msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-							msg.Text = "Quiz titled " + quizName + " found!\n" +
-								prevScore +
-								"For each question:\n" +
-								"Press <strong>Reveal Answer</strong> to reveal the answer.\n" +
-								"After that, press <strong>Correct</strong> if you answered correctly,\n" +
-								"or press <strong>Wrong</strong> if you answered wrongly\n" +
-								"Your score will be computed at the end of the quiz.\n" +
-								"You may also <strong>End quiz</strong> at any time\n"
-							msg.ParseMode = "HTML"
-
-							if _, err := bot.Send(msg); err != nil {
-								log.Panic(err)
-							}
-
-							// reset questionMaps
-							questionsMap1 = make(map[string]string)
-							questionsMap2 = make(map[int]string)
-							questionsMap3 = make(map[string]bool)
+							sendQuizInstructions(update.Message.Chat.ID, quizName, prevScore, typedAnswers, bot)
 
 							// save questions to question map
-							for question, answer := range doc.Data() {
-								if question != "numQns" && question != "score" {
-									questionsMap1[question] = answer.(string)
-									qnsRemaining++
-									questionsMap2[qnsRemaining] = question
-								}
+							questionsMap1, questionsMap2, questionsMap3, qnsRemaining = newQuestionMaps(doc.Data())
+							if quizLimit > 0 && quizLimit < qnsRemaining {
+								qnsRemaining = quizLimit
+								numQns = quizLimit
 							}
+							attachments = quizAttachments(doc.Data())
+							formatting = quizFormatting(doc.Data())
 
 							// send first question
-							sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+							activePromptID = sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 
 							botState = "try_quiz_quizAttempt"
 							inputExpected = "post-qn"
@@ -798,42 +1918,34 @@ func main() {
 					} else {
 						sendSimpleMsg(
 							update.Message.Chat.ID,
-							"Quiz with name "+quizName+" not found. Please re-enter your quiz name",
+							tr(update.Message.Chat.ID, "Quiz with name %s not found. Please re-enter your quiz name", quizName),
 							bot,
 						)
 					}
 				}
 
 			case "try_quiz_friend":
-				switch update.Message.Text {
-				case "Cancel":
+				switch pressedBtn {
+				case btnCancel:
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Cancelling quiz attempt"
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+					msg.Text = tr(update.Message.Chat.ID, "Cancelling quiz attempt")
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					botState = "idle"
 
-				default:
+				case "":
 					friendUserID = update.Message.Text
 					docRef := client.Collection("USERS").Doc(friendUserID)
-					doc, err := docRef.Get(ctx)
-					if err != nil {
-						if status.Code(err) == codes.NotFound {
-							// // Handle document not existing here
-							// _, err := docRef.Set(ctx /* custom object here */)
-							// if err != nil {
-							// 	return err
-							// }
-						} else {
-							// return err
-						}
+					var doc *firestore.DocumentSnapshot
+					err := retryStorage(func() (err error) {
+						doc, err = docRef.Get(ctx)
+						return err
+					})
+					if err != nil && status.Code(err) != codes.NotFound {
+						// the user can enter the name again once storage is back
+						reportStorageError(update.Message.Chat.ID, bot, "look up your friend", err)
+						break
 					}
 
 					if doc.Exists() {
@@ -843,63 +1955,49 @@ func main() {
 						friendUsername := doc.Data()["username"].(string)
 
 						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-						msg.Text = "Friend with username " + friendUsername + " found! Please input the quiz name:\n" +
-							"(Press <strong>Cancel</strong> to exit)"
-						msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
-							tgbotapi.NewKeyboardButtonRow(
-								tgbotapi.NewKeyboardButton("Cancel"),
-							),
-						)
+						msg.Text = tr(update.Message.Chat.ID, "Friend with username %s found! Please input the quiz name:\n(Press <strong>Cancel</strong> to exit)", escapeHTML(friendUsername))
+						msg.ReplyMarkup = cancelKeyboard(update.Message.Chat.ID)
 						msg.ParseMode = "HTML"
 
-						if _, err := bot.Send(msg); err != nil {
-							log.Panic(err)
-						}
+						activePromptID = sendPrompt(msg, bot)
 
 						botState = "try_quiz_friendQuiz"
 
 					} else {
 						sendSimpleMsg(
 							update.Message.Chat.ID,
-							"User with with ID "+friendUserID+" not found in our database. Please re-enter friend ID",
+							tr(update.Message.Chat.ID, "User with with ID %s not found in our database. Please re-enter friend ID", friendUserID),
 							bot,
 						)
 					}
 				}
 
 			case "try_quiz_friendQuiz":
-				switch update.Message.Text {
-				case "Cancel":
+				switch pressedBtn {
+				case btnCancel:
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Cancelling quiz attempt"
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+					msg.Text = tr(update.Message.Chat.ID, "Cancelling quiz attempt")
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					botState = "idle"
 
-				default:
+				case "":
 					quizName = update.Message.Text
 					docRef := client.Collection("USERS").Doc(friendUserID).Collection("QUIZZES").Doc(quizName)
-					doc, err := docRef.Get(ctx)
-					if err != nil {
-						if status.Code(err) == codes.NotFound {
-							// // Handle document not existing here
-							// _, err := docRef.Set(ctx /* custom object here */)
-							// if err != nil {
-							// 	return err
-							// }
-						} else {
-							// return err
-						}
+					var doc *firestore.DocumentSnapshot
+					err := retryStorage(func() (err error) {
+						doc, err = docRef.Get(ctx)
+						return err
+					})
+					if err != nil && status.Code(err) != codes.NotFound {
+						// the user can enter the name again once storage is back
+						reportStorageError(update.Message.Chat.ID, bot, "load the quiz", err)
+						break
 					}
 
-					if doc.Exists() {
+					// quizzes the user may not try are reported as not found, so private quiz names are not revealed
+					if doc.Exists() && canReadQuiz(bot, doc.Data(), friendUserID, currentUserID, update.Message.Chat.ID) {
 						// Handle document existing here
 						fmt.Println("Doc found:", doc.Ref.ID)
 
@@ -910,41 +2008,23 @@ func main() {
 						if numQns == 0 {
 							sendSimpleMsg(
 								update.Message.Chat.ID,
-								"This quiz has no questions to try! Please enter another quiz name.",
+								tr(update.Message.Chat.ID, "This quiz has no questions to try! Please enter another quiz name."),
 								bot,
 							)
 						} else {
-							// send quiz instructions
-							msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-							msg.Text = "Quiz titled " + quizName + " found!\n" +
-								"For each question:\n" +
-								"Press <strong>Reveal Answer</strong> to reveal the answer.\n" +
-								"After that, press <strong>Correct</strong> if you answered correctly,\n" +
-								"or press <strong>Wrong</strong> if you answered wrongly\n" +
-								"Your score will be computed at the end of the quiz.\n" +
-								"You may also <strong>End quiz</strong> at any time\n"
-							msg.ParseMode = "HTML"
-
-							if _, err := bot.Send(msg); err != nil {
-								log.Panic(err)
-							}
-
-							// reset questionMaps
-							questionsMap1 = make(map[string]string)
-							questionsMap2 = make(map[int]string)
-							questionsMap3 = make(map[string]bool)
+							sendQuizInstructions(update.Message.Chat.ID, quizName, "", typedAnswers, bot)
 
 							// save questions to question map
-							for question, answer := range doc.Data() {
-								if question != "numQns" && question != "score" {
-									questionsMap1[question] = answer.(string)
-									qnsRemaining++
-									questionsMap2[qnsRemaining] = question
-								}
+							questionsMap1, questionsMap2, questionsMap3, qnsRemaining = newQuestionMaps(doc.Data())
+							if quizLimit > 0 && quizLimit < qnsRemaining {
+								qnsRemaining = quizLimit
+								numQns = quizLimit
 							}
+							attachments = quizAttachments(doc.Data())
+							formatting = quizFormatting(doc.Data())
 
 							// send first question
-							sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+							activePromptID = sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 
 							botState = "try_quiz_quizAttempt"
 							inputExpected = "post-qn"
@@ -952,7 +2032,7 @@ func main() {
 					} else {
 						sendSimpleMsg(
 							update.Message.Chat.ID,
-							"Quiz with name "+quizName+" not found. Please re-enter your friend's quiz name",
+							tr(update.Message.Chat.ID, "Quiz with name %s not found. Please re-enter your friend's quiz name", quizName),
 							bot,
 						)
 					}
@@ -960,53 +2040,44 @@ func main() {
 			case "try_quiz_quizAttempt":
 				switch inputExpected {
 				case "post-qn":
-					switch update.Message.Text {
-					case "Reveal Ans":
-						sendAnswer(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+					switch pressedBtn {
+					case btnRevealAns:
+						// the same message now takes the result of the question, unless the answer did not fit in it
+						activePromptID = revealAnswer(update.Message.Chat.ID, pressedPromptID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 						qnsRemaining--
 						inputExpected = "post-ans"
 
-					case "End Quiz":
+					case btnEndQuiz:
 						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-						msg.Text = "Cancelling quiz attempt"
-						msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-							RemoveKeyboard: true,
-							Selective:      false,
-						}
+						msg.Text = tr(update.Message.Chat.ID, "Cancelling quiz attempt")
 
-						if _, err := bot.Send(msg); err != nil {
-							log.Panic(err)
-						}
+						queueMessage(bot, msg.ChatID, msg)
 
 						botState = "idle"
 					}
 
 				case "post-ans":
 					inputError := false
-					switch update.Message.Text {
-					case "Correct":
+					switch pressedBtn {
+					case btnCorrect:
 						scoreInt++
+						markQuestion(update.Message.Chat.ID, pressedPromptID, qnsRemaining+1, bot, questionsMap1, questionsMap2, formatting, "Correct")
 						if qnsRemaining != 0 {
-							sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+							activePromptID = sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 						}
 						inputExpected = "post-qn"
-					case "Wrong":
+					case btnWrong:
+						markQuestion(update.Message.Chat.ID, pressedPromptID, qnsRemaining+1, bot, questionsMap1, questionsMap2, formatting, "Wrong")
 						if qnsRemaining != 0 {
-							sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+							activePromptID = sendQuestion(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 						}
 						inputExpected = "post-qn"
 
-					case "End Quiz":
+					case btnEndQuiz:
 						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-						msg.Text = "Cancelling quiz attempt"
-						msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-							RemoveKeyboard: true,
-							Selective:      false,
-						}
+						msg.Text = tr(update.Message.Chat.ID, "Cancelling quiz attempt")
 
-						if _, err := bot.Send(msg); err != nil {
-							log.Panic(err)
-						}
+						queueMessage(bot, msg.ChatID, msg)
 
 						botState = "idle"
 
@@ -1016,12 +2087,7 @@ func main() {
 
 					if qnsRemaining == 0 && !inputError {
 						if tryingMyQuiz {
-							_, err = client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Update(ctx, []firestore.Update{
-								{
-									Path:  "score",
-									Value: fmt.Sprint(scoreInt) + "/" + fmt.Sprint(numQns),
-								},
-							})
+							err = recordQuizAttempt(ctx, client, currentUserID, quizName, scoreInt, numQns)
 
 							if err != nil {
 								// Handle any errors in an appropriate way, such as returning them.
@@ -1030,151 +2096,190 @@ func main() {
 
 						}
 
+						// quizzes tried in a group count towards its leaderboards
+						if isGroupChat(update.Message.Chat) {
+							err := recordGroupResults(ctx, client, update.Message.Chat.ID, quizName, []groupResult{{
+								userID:  currentUserID,
+								name:    liveUserName(update.Message.From),
+								points:  scoreInt * attemptPointsPerAnswer,
+								correct: scoreInt,
+								total:   numQns,
+							}})
+							if err != nil {
+								log.Printf("An error has occurred trying to record group result: %s", err)
+							}
+						}
+
+						// attempts of other users' quizzes count towards their popularity in the catalog
+						askRating := false
+						if !tryingMyQuiz {
+							isPublic, err := recordFriendAttempt(ctx, client, friendUserID, quizName)
+							if err != nil {
+								log.Printf("An error has occurred trying to record quiz attempt: %s", err)
+							}
+							askRating = err == nil && isPublic
+						}
+
 						// TODO: link to html instead
 						// define endMsg based on pass fail
 						var endMsg string
 
 						if scoreInt/numQns == 1 {
-							endMsg = "Congrats perfect score!"
+							endMsg = tr(update.Message.Chat.ID, "Congrats perfect score!")
 						} else if float64(scoreInt)/float64(numQns) > float64(0.5) {
-							endMsg = "Congrats you passed!"
+							endMsg = tr(update.Message.Chat.ID, "Congrats you passed!")
 						} else {
-							endMsg = "You failed! Better luck next time."
+							endMsg = tr(update.Message.Chat.ID, "You failed! Better luck next time.")
 						}
 
 						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-						msg.Text = "You scored " + fmt.Sprint(scoreInt) + "/" + fmt.Sprint(numQns) + "\n" + endMsg
-						msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-							RemoveKeyboard: true,
-							Selective:      false,
-						}
+						msg.Text = trn(update.Message.Chat.ID, numQns, "You scored %d/%d point\n%s", "You scored %d/%d points\n%s", scoreInt, numQns, endMsg)
 
-						if _, err := bot.Send(msg); err != nil {
-							log.Panic(err)
+						// every finished quiz counts towards the user's streak
+						if streakText := recordStudyDayText(ctx, client, update.Message.Chat.ID, currentUserID); streakText != "" {
+							msg.Text += "\n\n" + streakText
 						}
 
+						queueMessage(bot, msg.ChatID, msg)
+
 						// send score
 						botState = "idle"
+
+						if askRating {
+							msg := tgbotapi.NewMessage(update.Message.Chat.ID, tr(update.Message.Chat.ID, "How would you rate quiz %s?", quizName))
+							msg.ReplyMarkup = ratingKeyboard(update.Message.Chat.ID)
+
+							activePromptID = sendPrompt(msg, bot)
+							botState = "rate_quiz"
+						}
 					}
 				default:
 
 				}
 			case "add_qns_Qn":
-				switch update.Message.Text {
-				case "Exit":
+				switch pressedBtn {
+				case btnExit:
 					if inputExpected == "ans" {
 						delete(questionsMap1, questionText)
+						delete(attachments, questionText)
+						delete(formatting, questionText)
 					}
 
-					questionsMap1["score"] = "none"
-
-					_, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Set(ctx, questionsMap1, firestore.MergeAll)
-
-					if err != nil {
-						// Handle any errors in an appropriate way, such as returning them.
-						log.Printf("An error has occurred: %s", err)
-					}
+					copyMediaToBucket(ctx, bot, mediaBucket, quizOwnerID, attachments)
 
-					_, err = client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Update(ctx, []firestore.Update{
-						{
-							Path:  "numQns",
-							Value: numQns,
-						},
+					// merge with questions saved by co-editors meanwhile instead of overwriting them
+					err := retryStorage(func() error {
+						_, err := saveNewQuestions(ctx, client, quizOwnerID, quizName, currentUserID, currentUsername, questionsMap1, attachments, formatting)
+						return err
 					})
 
-					if err != nil {
-						// Handle any errors in an appropriate way, such as returning them.
-						log.Printf("An error has occurred trying to update numQns to firebase: %s", err)
+					if errors.Is(err, errNotEditor) {
+						reportStorageError(update.Message.Chat.ID, bot, "save your questions", err)
+						botState = "idle"
+						inputExpected = "none"
+						break
 					}
+					if err != nil {
+						reportStorageError(update.Message.Chat.ID, bot, "save your questions", err)
 
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Questions with answer inputs added to quiz!"
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+						// keep the questions so that they are not lost, and let the user save them again
+						msg := tgbotapi.NewMessage(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Your questions are kept. Press <strong>Exit</strong> to try saving them again, or <strong>Cancel</strong> to discard them."))
+						msg.ParseMode = "HTML"
+						msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnExit, btnCancel)
+						activePromptID = sendPrompt(msg, bot)
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
+						inputExpected = "qn"
+						break
 					}
 
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Questions with answer inputs added to quiz!"), bot)
+					notifyForks(ctx, client, bot, quizOwnerID, quizName)
+
 					botState = "idle"
 					inputExpected = "none"
 
-				case "Cancel":
+				case btnCancel:
 
 					// to quit without saving
 					msg2 := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg2.Text = "Are you sure you want to <strong>Cancel</strong> update?"
+					msg2.Text = tr(update.Message.Chat.ID, "Are you sure you want to <strong>Cancel</strong> update?")
 					msg2.ParseMode = "HTML"
-					msg2.ReplyMarkup = yesNoKeyboard
+					msg2.ReplyMarkup = yesNoKeyboard(update.Message.Chat.ID)
 
-					if _, err := bot.Send(msg2); err != nil {
-						log.Panic(err)
-					}
+					activePromptID = sendPrompt(msg2, bot)
 
 					botState = "add_qns_cancel"
 
-				default:
-					if inputExpected == "qn" {
+				case "":
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnExit, btnCancel)
+
+					// questions and answers can be photos, documents, audio or voice notes, captioned or not
+					inputText, inputMedia := messageContent(update.Message)
+
+					if inputText == "" {
+						msg.Text = tr(update.Message.Chat.ID, "Please input text, or send a photo, document, audio or voice note:")
+						activePromptID = sendPrompt(msg, bot)
+
+					} else if inputExpected == "qn" {
 						// input expected is qn
-						questionText = update.Message.Text
+						questionText = inputText
+						attachments[questionText] = questionAttachments{question: inputMedia}
+						delete(formatting, questionText)
+						if keepFormatting {
+							formatting[questionText] = questionFormatting{question: messageFormatting(update.Message)}
+						}
 						inputExpected = "ans"
 
-						sendSimpleMsg(
-							update.Message.Chat.ID,
-							"Please input the answer:",
-							bot,
-						)
+						msg.Text = tr(update.Message.Chat.ID, "Please input the answer:")
+						activePromptID = sendPrompt(msg, bot)
 
 					} else if inputExpected == "ans" {
 						//input expected is answer
 
 						// add ans to array
-						questionsMap1[questionText] = update.Message.Text
-						numQns++
+						questionsMap1[questionText] = inputText
+						attachment := attachments[questionText]
+						attachment.answer = inputMedia
+						attachments[questionText] = attachment
+						if keepFormatting {
+							format := formatting[questionText]
+							format.answer = messageFormatting(update.Message)
+							formatting[questionText] = format
+						}
 						inputExpected = "qn"
 
-						sendSimpleMsg(
-							update.Message.Chat.ID,
-							"Please input the next question:",
-							bot,
-						)
+						msg.Text = tr(update.Message.Chat.ID, "Please input the next question:")
+						activePromptID = sendPrompt(msg, bot)
 					} else {
-						log.Panic("inputExpected should be qn or ans")
+						log.Printf("inputExpected should be qn or ans, not %s", inputExpected)
 					}
 
 				}
 
 			case "add_qns_cancel":
-				switch update.Message.Text {
-				case "Yes":
+				switch pressedBtn {
+				case btnYes:
 					// cancel all changes
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Changes to quiz cancelled."
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+					msg.Text = tr(update.Message.Chat.ID, "Changes to quiz cancelled.")
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					botState = "idle"
 
-				case "No":
+				case btnNo:
 
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
 					if inputExpected == "qn" {
-						msg.Text = "Please input next question"
+						msg.Text = tr(update.Message.Chat.ID, "Please input next question")
+					} else {
+						msg.Text = tr(update.Message.Chat.ID, "Please input the answer")
 					}
 
-					msg.ReplyMarkup = createTwoBtnRowKeyboard("Exit", "Cancel")
+					msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnExit, btnCancel)
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					activePromptID = sendPrompt(msg, bot)
 
 					botState = "add_qns_Qn"
 
@@ -1182,14 +2287,16 @@ func main() {
 				}
 
 			case "remove_qns":
-				switch update.Message.Text {
-				case "Keep":
+				switch pressedBtn {
+				case btnKeep:
 					// check for next qn to send
 					questionsMap3[questionsMap2[qnsRemaining+1]] = false
 					numQns++
+					markQuestion(update.Message.Chat.ID, pressedPromptID, qnsRemaining+1, bot, questionsMap1, questionsMap2, formatting, "Kept")
 
 					if qnsRemaining == 0 {
-						haveTossed := confirmQnsRemove(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap3)
+						haveTossed, promptID := confirmQnsRemove(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap3, formatting)
+						activePromptID = promptID
 
 						if haveTossed {
 							botState = "remove_qns_confirm"
@@ -1198,17 +2305,19 @@ func main() {
 						}
 
 					} else {
-						sendQuestionAndAnswerSet(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+						activePromptID = sendQuestionAndAnswerSet(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 						qnsRemaining--
 					}
 
-				case "Toss":
+				case btnToss:
 					// add to questionsMap3
 					questionsMap3[questionsMap2[qnsRemaining+1]] = true
+					markQuestion(update.Message.Chat.ID, pressedPromptID, qnsRemaining+1, bot, questionsMap1, questionsMap2, formatting, "Tossed")
 
 					// check for next qn to send
 					if qnsRemaining == 0 {
-						haveTossed := confirmQnsRemove(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap3)
+						haveTossed, promptID := confirmQnsRemove(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap3, formatting)
+						activePromptID = promptID
 
 						if haveTossed {
 							botState = "remove_qns_confirm"
@@ -1217,20 +2326,18 @@ func main() {
 						}
 
 					} else {
-						sendQuestionAndAnswerSet(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2)
+						activePromptID = sendQuestionAndAnswerSet(update.Message.Chat.ID, qnsRemaining, bot, questionsMap1, questionsMap2, attachments, formatting)
 						qnsRemaining--
 					}
 
-				case "Cancel":
+				case btnCancel:
 					// to quit without saving
 					msg2 := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg2.Text = "Are you sure you want to <strong>Cancel</strong> update?"
+					msg2.Text = tr(update.Message.Chat.ID, "Are you sure you want to <strong>Cancel</strong> update?")
 					msg2.ParseMode = "HTML"
-					msg2.ReplyMarkup = yesNoKeyboard
+					msg2.ReplyMarkup = yesNoKeyboard(update.Message.Chat.ID)
 
-					if _, err := bot.Send(msg2); err != nil {
-						log.Panic(err)
-					}
+					activePromptID = sendPrompt(msg2, bot)
 
 					botState = "remove_qns_cancel"
 
@@ -1238,19 +2345,13 @@ func main() {
 				}
 
 			case "remove_qns_cancel":
-				switch update.Message.Text {
-				case "Yes":
+				switch pressedBtn {
+				case btnYes:
 					// cancel all changes
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Changes to quiz cancelled."
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+					msg.Text = tr(update.Message.Chat.ID, "Changes to quiz cancelled.")
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					// reset arrays
 					questionsMap1 = make(map[string]string)
@@ -1259,14 +2360,12 @@ func main() {
 
 					botState = "idle"
 
-				case "No":
+				case btnNo:
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Continuing quiz review. Toss or keep previous question?"
-					msg.ReplyMarkup = questionReviewKeyboard
+					msg.Text = tr(update.Message.Chat.ID, "Continuing quiz review. Toss or keep previous question?")
+					msg.ReplyMarkup = questionReviewKeyboard(update.Message.Chat.ID)
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					activePromptID = sendPrompt(msg, bot)
 
 					botState = "remove_qns"
 
@@ -1274,38 +2373,35 @@ func main() {
 				}
 
 			case "remove_qns_confirm":
-				switch update.Message.Text {
-				case "Yes":
-					// update all the listed questions to remove in firebase
-					updateDocFields = make(map[string]interface{})
+				switch pressedBtn {
+				case btnYes:
+					// remove all the listed questions in firebase, keeping them in the trash so the removal can be undone
+					removedQns := make(map[string]string)
 					for question, isTossed := range questionsMap3 {
-						if !isTossed {
-							updateDocFields[question] = questionsMap1[question]
+						if isTossed {
+							removedQns[question] = questionsMap1[question]
 						}
 					}
-					// update score field to "none"
-					updateDocFields["score"] = "none"
 
-					// update numQns field to numQns
-					updateDocFields["numQns"] = numQns
+					err := retryStorage(func() error {
+						return removeQuestionsToTrash(ctx, client, quizOwnerID, quizName, currentUserID, currentUsername, removedQns)
+					})
 
-					_, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Set(ctx, updateDocFields)
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ParseMode = "HTML"
 					if err != nil {
-						// Handle any errors in an appropriate way, such as returning them.
-						log.Printf("An error has occurred: %s", err)
+						msg.Text = storageErrorText(update.Message.Chat.ID, "remove the questions", err, logStorageError("remove questions", err))
+					} else if quizOwnerID == currentUserID {
+						msg.Text = tr(update.Message.Chat.ID, "Removed selected questions. Use <strong>/undo</strong> to bring them back.")
+					} else {
+						msg.Text = tr(update.Message.Chat.ID, "Removed selected questions. The quiz owner can bring them back from their trash.")
 					}
 
-					// cancel all changes
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Removed selected questions."
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
+					if err == nil {
+						notifyForks(ctx, client, bot, quizOwnerID, quizName)
 					}
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					// reset arrays
 					questionsMap1 = make(map[string]string)
@@ -1314,19 +2410,13 @@ func main() {
 
 					botState = "idle"
 
-				case "No":
+				case btnNo:
 
 					// cancel all changes
 					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-					msg.Text = "Changes to quiz cancelled."
-					msg.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{
-						RemoveKeyboard: true,
-						Selective:      false,
-					}
+					msg.Text = tr(update.Message.Chat.ID, "Changes to quiz cancelled.")
 
-					if _, err := bot.Send(msg); err != nil {
-						log.Panic(err)
-					}
+					queueMessage(bot, msg.ChatID, msg)
 
 					// reset arrays
 					questionsMap1 = make(map[string]string)
@@ -1338,14 +2428,396 @@ func main() {
 				default:
 				}
 
+			case "tag_add", "tag_remove", "folder_input":
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+
+				if pressedBtn == btnCancel {
+					msg.Text = tr(update.Message.Chat.ID, "No changes made to quiz %s.", quizName)
+				} else {
+					var err error
+
+					switch botState {
+					case "tag_add":
+						tags := normalizeTags(update.Message.Text)
+						err = updateQuizTags(ctx, client, currentUserID, quizName, tags, true)
+						msg.Text = tr(update.Message.Chat.ID, "Added tags %s to quiz %s.", formatTags(tags), quizName)
+					case "tag_remove":
+						tags := normalizeTags(update.Message.Text)
+						err = updateQuizTags(ctx, client, currentUserID, quizName, tags, false)
+						msg.Text = tr(update.Message.Chat.ID, "Removed tags %s from quiz %s.", formatTags(tags), quizName)
+					case "folder_input":
+						folder := normalizeFolder(update.Message.Text)
+						err = setQuizFolder(ctx, client, currentUserID, quizName, folder)
+						if folder == "" {
+							msg.Text = tr(update.Message.Chat.ID, "Quiz %s is no longer in a folder.", quizName)
+						} else {
+							msg.Text = tr(update.Message.Chat.ID, "Moved quiz %s to folder %s.", quizName, folder)
+						}
+					}
+
+					if err != nil {
+						log.Printf("An error has occurred trying to update quiz details: %s", err)
+						msg.Text = tr(update.Message.Chat.ID, "Sorry, quiz %s could not be updated. Please try again.", quizName)
+					}
+				}
+
+				queueMessage(bot, msg.ChatID, msg)
+
+				botState = "idle"
+
+			case "visibility_select":
+				switch pressedBtn {
+				case btnPrivate, btnPublic:
+					visibility := visibilityPrivate
+					if pressedBtn == btnPublic {
+						visibility = visibilityPublic
+					}
+
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					if err := setQuizVisibility(ctx, client, currentUserID, quizName, visibility, nil); err != nil {
+						log.Printf("An error has occurred trying to update quiz visibility: %s", err)
+						msg.Text = tr(update.Message.Chat.ID, "Sorry, quiz %s could not be updated. Please try again.", quizName)
+					} else {
+						msg.Text = tr(update.Message.Chat.ID, "Quiz %s is now %s.", quizName, visibilityDescription(update.Message.Chat.ID, visibility, nil))
+					}
+
+					queueMessage(bot, msg.ChatID, msg)
+
+					botState = "idle"
+
+				case btnShared:
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ParseMode = "HTML"
+					msg.Text = tr(update.Message.Chat.ID, "Please input the user ids or @usernames to share this quiz with, separated by commas.\nTo share it with a group, input the group chat id shown by <strong>/get_my_id</strong> in that group.\nInput <strong>-</strong> to share it only with people who have its share link.\n(Press <strong>Cancel</strong> to exit)")
+					msg.ReplyMarkup = cancelKeyboard(update.Message.Chat.ID)
+
+					activePromptID = sendPrompt(msg, bot)
+					botState = "visibility_shared_input"
+
+				case btnCancel:
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "No changes made to quiz %s.", quizName), bot)
+					botState = "idle"
+
+				default:
+				}
+
+			case "visibility_shared_input":
+				switch pressedBtn {
+				case btnCancel:
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "No changes made to quiz %s.", quizName), bot)
+					botState = "idle"
+
+				case "":
+					sharedWith := []string{}
+					var unresolved []string
+					var err error
+
+					if strings.TrimSpace(update.Message.Text) != "-" {
+						sharedWith, unresolved, err = resolveShareTargets(ctx, client, update.Message.Text)
+					}
+
+					if err == nil && len(unresolved) > 0 {
+						sendSimpleMsg(
+							update.Message.Chat.ID,
+							tr(update.Message.Chat.ID, "Could not find %s. They need to /start the bot before you can share with them. Please re-enter the users to share with.", strings.Join(unresolved, ", ")),
+							bot,
+						)
+						break
+					}
+
+					if err == nil {
+						err = setQuizVisibility(ctx, client, currentUserID, quizName, visibilityShared, sharedWith)
+					}
+
+					if err != nil {
+						log.Printf("An error has occurred trying to update quiz visibility: %s", err)
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, quiz %s could not be updated. Please try again.", quizName), bot)
+					} else {
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Quiz %s is now %s.", quizName, visibilityDescription(update.Message.Chat.ID, visibilityShared, sharedWith)), bot)
+					}
+
+					botState = "idle"
+
+				default:
+				}
+
+			case "editors_select":
+				switch pressedBtn {
+				case btnAddEditors, btnDelEditors:
+					inputExpected = "add"
+					if pressedBtn == btnDelEditors {
+						inputExpected = "remove"
+					}
+
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ParseMode = "HTML"
+					msg.Text = tr(update.Message.Chat.ID, "Please input the user ids or @usernames of the co-editors to add, separated by commas.\n(Press <strong>Cancel</strong> to exit)")
+					if inputExpected == "remove" {
+						msg.Text = tr(update.Message.Chat.ID, "Please input the user ids or @usernames of the co-editors to remove, separated by commas.\n(Press <strong>Cancel</strong> to exit)")
+					}
+					msg.ReplyMarkup = cancelKeyboard(update.Message.Chat.ID)
+
+					activePromptID = sendPrompt(msg, bot)
+					botState = "editors_input"
+
+				case btnCancel:
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "No changes made to quiz %s.", quizName), bot)
+					botState = "idle"
+
+				default:
+				}
+
+			case "editors_input":
+				switch pressedBtn {
+				case btnCancel:
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "No changes made to quiz %s.", quizName), bot)
+					botState = "idle"
+					inputExpected = "none"
+
+				case "":
+					editorIDs, unresolved, err := resolveShareTargets(ctx, client, update.Message.Text)
+
+					if err == nil && len(unresolved) > 0 {
+						sendSimpleMsg(
+							update.Message.Chat.ID,
+							tr(update.Message.Chat.ID, "Could not find %s. They need to /start the bot before they can edit your quizzes. Please re-enter the co-editors.", strings.Join(unresolved, ", ")),
+							bot,
+						)
+						break
+					}
+
+					if err == nil && len(editorIDs) == 0 {
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please input at least one user id or @username."), bot)
+						break
+					}
+
+					if err == nil {
+						err = updateQuizEditors(ctx, client, currentUserID, quizName, editorIDs, inputExpected == "add")
+					}
+
+					if err != nil {
+						log.Printf("An error has occurred trying to update quiz editors: %s", err)
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Sorry, quiz %s could not be updated. Please try again.", quizName), bot)
+					} else if inputExpected == "add" {
+						for _, editorID := range editorIDs {
+							notifyUser(ctx, client, editorID, bot, "@%s added you as a co-editor of quiz %s. "+
+								"Use /add_qns, /edit_qns and /remove_qns to help build it.", currentUsername, quizName)
+						}
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Added %s as co-editors of quiz %s.", strings.Join(usernamesForIDs(ctx, client, editorIDs), ", "), quizName), bot)
+					} else {
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Removed %s from the co-editors of quiz %s.", strings.Join(usernamesForIDs(ctx, client, editorIDs), ", "), quizName), bot)
+					}
+
+					botState = "idle"
+					inputExpected = "none"
+
+				default:
+				}
+
+			case "edit_qns_select":
+				switch pressedBtn {
+				case btnExit:
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Finished editing quiz %s.", quizName), bot)
+					botState = "idle"
+
+				case "":
+					qnIndex, err := strconv.Atoi(strings.TrimSpace(update.Message.Text))
+					if err != nil || qnIndex < 1 || qnIndex > len(questionsMap2) {
+						msg := tgbotapi.NewMessage(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please input a question number from 1 to %d.", len(questionsMap2)))
+						msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+							tgbotapi.NewInlineKeyboardRow(newActionButton(update.Message.Chat.ID, btnExit)),
+						)
+						activePromptID = sendPrompt(msg, bot)
+						break
+					}
+
+					editedQuestion = questionsMap2[qnIndex]
+
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ParseMode = "HTML"
+					msg.Text = questionAndAnswerText(update.Message.Chat.ID, editedQuestion, questionsMap1[editedQuestion], formatting[editedQuestion]) + "\n\n" +
+						tr(update.Message.Chat.ID, "Please input the new question, or press <strong>Keep current</strong>:")
+					msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnUnchanged, btnCancel)
+
+					activePromptID = sendPrompt(msg, bot)
+					botState = "edit_qns_qn"
+
+				default:
+				}
+
+			case "edit_qns_qn", "edit_qns_ans":
+				if pressedBtn == btnCancel {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Question not changed. Please input the number of the next question to edit:"))
+					msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+						tgbotapi.NewInlineKeyboardRow(newActionButton(update.Message.Chat.ID, btnExit)),
+					)
+
+					activePromptID = sendPrompt(msg, bot)
+					botState = "edit_qns_select"
+					break
+				}
+
+				if pressedBtn != btnUnchanged && pressedBtn != "" {
+					break
+				}
+
+				if botState == "edit_qns_qn" {
+					questionText = editedQuestion
+					if pressedBtn == "" {
+						questionText = update.Message.Text
+					}
+
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ParseMode = "HTML"
+					msg.Text = tr(update.Message.Chat.ID, "Please input the new answer, or press <strong>Keep current</strong>:")
+					msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnUnchanged, btnCancel)
+
+					activePromptID = sendPrompt(msg, bot)
+					botState = "edit_qns_ans"
+					break
+				}
+
+				newAnswer := questionsMap1[editedQuestion]
+				if pressedBtn == "" {
+					newAnswer = update.Message.Text
+				}
+
+				err := retryStorage(func() error {
+					return editQuestion(ctx, client, quizOwnerID, quizName, currentUserID, currentUsername,
+						editedQuestion, questionsMap1[editedQuestion], questionText, newAnswer)
+				})
+
+				msgTxt := tr(update.Message.Chat.ID, "Question updated!")
+				if errors.Is(err, errQuestionChanged) {
+					msgTxt = tr(update.Message.Chat.ID, "This question was changed by another editor, or the new question already exists. Please check the latest questions with /edit_qns %s.", quizName)
+				} else if err != nil {
+					msgTxt = storageErrorText(update.Message.Chat.ID, "update the question", err, logStorageError("edit question", err))
+				} else {
+					// keep the local list in step with the saved quiz
+					for i, question := range questionsMap2 {
+						if question == editedQuestion {
+							questionsMap2[i] = questionText
+						}
+					}
+					delete(questionsMap1, editedQuestion)
+					questionsMap1[questionText] = newAnswer
+
+					notifyForks(ctx, client, bot, quizOwnerID, quizName)
+				}
+
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, tr(update.Message.Chat.ID, "%s\nPlease input the number of the next question to edit:", msgTxt))
+				msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+					tgbotapi.NewInlineKeyboardRow(newActionButton(update.Message.Chat.ID, btnExit)),
+				)
+
+				activePromptID = sendPrompt(msg, bot)
+				botState = "edit_qns_select"
+
+			case "rate_quiz":
+				if stars, ok := ratingStars(pressedBtn); ok {
+					err := retryStorage(func() error {
+						return rateQuiz(ctx, client, friendUserID, quizName, currentUserID, stars)
+					})
+					if err != nil {
+						reportStorageError(update.Message.Chat.ID, bot, "save your rating", err)
+					} else {
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Thanks for rating quiz %s!", quizName), bot)
+					}
+					botState = "idle"
+				} else if pressedBtn == btnSkip || pressedBtn == "" {
+					// typing instead of rating skips it
+					if pressedBtn == "" {
+						removeInlineKeyboard(update.Message.Chat.ID, activePromptID, bot)
+					}
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Rating skipped."), bot)
+					botState = "idle"
+				}
+
+			case "sync_select":
+				var selected []syncChange
+
+				switch pressedBtn {
+				case btnYes:
+					selected = syncChanges
+
+				case btnCancel:
+					sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "No changes made to quiz %s.", quizName), bot)
+					botState = "idle"
+
+				case "":
+					numbers, err := parseNumberSelection(update.Message.Text, len(syncChanges))
+					if err != nil {
+						msg := tgbotapi.NewMessage(update.Message.Chat.ID, tr(update.Message.Chat.ID, "Please input change numbers from 1 to %d, e.g. 1, 3-4", len(syncChanges)))
+						msg.ReplyMarkup = createTwoBtnRowKeyboard(update.Message.Chat.ID, btnYes, btnCancel)
+
+						activePromptID = sendPrompt(msg, bot)
+						break
+					}
+
+					// typing replaces the buttons of the list of changes
+					removeInlineKeyboard(update.Message.Chat.ID, activePromptID, bot)
+					for _, number := range numbers {
+						selected = append(selected, syncChanges[number-1])
+					}
+				}
+
+				if len(selected) > 0 {
+					err := retryStorage(func() error {
+						return pullSourceChanges(ctx, client, bot, currentUserID, update.Message.Chat.ID, quizName, syncFork, selected)
+					})
+					if errors.Is(err, errSourceUnreadable) {
+						sendSimpleMsg(update.Message.Chat.ID, tr(update.Message.Chat.ID, "The quiz %s was copied from is no longer shared with you, so it cannot be synced.", quizName), bot)
+					} else if err != nil {
+						reportStorageError(update.Message.Chat.ID, bot, "pull the changes", err)
+					} else {
+						sendSimpleMsg(update.Message.Chat.ID, trn(update.Message.Chat.ID, len(selected), "Pulled %d change into quiz %s.", "Pulled %d changes into quiz %s.", len(selected), quizName), bot)
+					}
+					botState = "idle"
+				}
+
+			case "delete_quiz_confirm":
+				switch pressedBtn {
+				case btnYes:
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.ParseMode = "HTML"
+
+					err := retryStorage(func() error {
+						doc, err := client.Collection("USERS").Doc(currentUserID).Collection("QUIZZES").Doc(quizName).Get(ctx)
+						if err != nil {
+							return err
+						}
+						return moveQuizToTrash(ctx, client, currentUserID, quizName, doc.Data())
+					})
+
+					if status.Code(err) == codes.NotFound {
+						msg.Text = tr(update.Message.Chat.ID, "Quiz could not be found. Error deleting quiz: %s", escapeHTML(quizName))
+					} else if err != nil {
+						msg.Text = storageErrorText(update.Message.Chat.ID, "delete the quiz", err, logStorageError("delete quiz", err))
+					} else {
+						msg.Text = tr(update.Message.Chat.ID, "Successfully deleted quiz: %s\nUse <strong>/undo</strong> to bring it back.", escapeHTML(quizName))
+					}
+
+					queueMessage(bot, msg.ChatID, msg)
+
+					botState = "idle"
+
+				case btnNo:
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
+					msg.Text = tr(update.Message.Chat.ID, "Quiz deletion cancelled.")
+
+					queueMessage(bot, msg.ChatID, msg)
+
+					botState = "idle"
+
+				default:
+				}
+
 			default:
 				msg2 := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-				msg2.Text = "User not logged in. Please run <strong>/start</strong> to log in user"
+				msg2.Text = tr(update.Message.Chat.ID, "User not logged in. Please run <strong>/start</strong> to log in user")
 				msg2.ParseMode = "HTML"
 
-				if _, err := bot.Send(msg2); err != nil {
-					log.Panic(err)
-				}
+				queueMessage(bot, msg2.ChatID, msg2)
 				fmt.Println("BOT STATE INVALID")
 			}
 
@@ -1356,5 +2828,8 @@ func main() {
 
 func Parser(str string) string {
 	arr := strings.SplitN(str, " ", 2)
+	if len(arr) < 2 {
+		return ""
+	}
 	return arr[1]
 }
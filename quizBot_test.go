@@ -11,4 +11,8 @@ func TestParser(t *testing.T) {
 	if outputStr != "testing 123" {
 		t.Error("Expected: " + "testing but got: " + outputStr)
 	}
+
+	if outputStr := Parser("/start"); outputStr != "" {
+		t.Error("Expected no argument but got: " + outputStr)
+	}
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// number of quizzes shown on each page of the quiz picker
+const pickerPageSize = 8
+
+// quizPicker is an inline keyboard listing the user's quizzes for a command given without a quiz name
+type quizPicker struct {
+	action    string // "try_quiz" or the command to run on the quiz, e.g. "add_qns"
+	quizNames []string
+	page      int
+}
+
+func newQuizPicker(ctx context.Context, client *firestore.Client, userID string, action string) (*quizPicker, error) {
+	summaries, err := loadQuizSummaries(ctx, client, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	picker := &quizPicker{action: action}
+	for _, summary := range summaries {
+		picker.quizNames = append(picker.quizNames, summary.name)
+	}
+
+	// quizzes the user co-edits can also be picked for editing, with their owner if the user can edit another
+	// quiz of the same name
+	switch action {
+	case "add_qns", "edit_qns", "remove_qns", "history":
+		coEdited, err := loadEditableQuizzes(ctx, client, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		var quizzes []editableQuiz
+		for _, quizName := range picker.quizNames {
+			quizzes = append(quizzes, editableQuiz{ownerID: userID, quizName: quizName})
+		}
+		quizzes = append(quizzes, coEdited...)
+		picker.quizNames = editableQuizNames(quizzes, func(ownerID string) string {
+			return quizOwnerLabel(ctx, client, ownerID)
+		})
+	}
+
+	return picker, nil
+}
+
+func pickerKey(chatID int64, messageID int) string {
+	return fmt.Sprint(chatID) + ":" + fmt.Sprint(messageID)
+}
+
+func (picker *quizPicker) numPages() int {
+	return (len(picker.quizNames) + pickerPageSize - 1) / pickerPageSize
+}
+
+func (picker *quizPicker) keyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	start := picker.page * pickerPageSize
+	end := start + pickerPageSize
+	if end > len(picker.quizNames) {
+		end = len(picker.quizNames)
+	}
+
+	// buttons refer to quizzes by index, as quiz names can be longer than the callback data limit
+	for i := start; i < end; i++ {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(picker.quizNames[i], "pick:"+strconv.Itoa(i)),
+		))
+	}
+
+	if picker.numPages() > 1 {
+		var navRow []tgbotapi.InlineKeyboardButton
+		if picker.page > 0 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "« Prev"), "page:"+strconv.Itoa(picker.page-1)))
+		}
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprint(picker.page+1)+"/"+fmt.Sprint(picker.numPages()), "page:"+strconv.Itoa(picker.page),
+		))
+		if picker.page < picker.numPages()-1 {
+			navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData(tr(chatID, "Next »"), "page:"+strconv.Itoa(picker.page+1)))
+		}
+		rows = append(rows, navRow)
+
+		// long lists can be searched as you type with an inline query
+		searchQuery := picker.action + " "
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{
+			{Text: tr(chatID, "Search"), SwitchInlineQueryCurrentChat: &searchQuery},
+		})
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// selectionText is the message the user would have typed to choose the quiz
+func (picker *quizPicker) selectionText(quizName string) string {
+	if picker.action == "try_quiz" {
+		return quizName
+	}
+	return "/" + picker.action + " " + quizName
+}
+
+// sendQuizPicker sends the picker and returns the ID of its message
+func sendQuizPicker(chatID int64, bot *tgbotapi.BotAPI, picker *quizPicker) int {
+	msg := tgbotapi.NewMessage(chatID, tr(chatID, "Select a quiz:"))
+	msg.ReplyMarkup = picker.keyboard(chatID)
+
+	return sendPrompt(msg, bot)
+}
+
+// handlePickerCallback turns pages of the picker, and returns the selected quiz name once one is pressed
+func handlePickerCallback(query *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI, picker *quizPicker) (string, bool) {
+	kind, value := parseCallbackData(query.Data)
+	index, err := strconv.Atoi(value)
+	if err != nil {
+		return "", false
+	}
+
+	switch kind {
+	case "page":
+		if index < 0 || index >= picker.numPages() || index == picker.page {
+			return "", false
+		}
+
+		picker.page = index
+		edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, picker.keyboard(query.Message.Chat.ID))
+		queueMessage(bot, edit.ChatID, edit)
+
+	case "pick":
+		if index < 0 || index >= len(picker.quizNames) {
+			return "", false
+		}
+
+		quizName := picker.quizNames[index]
+		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, tr(query.Message.Chat.ID, "Selected quiz: %s", quizName))
+		queueMessage(bot, edit.ChatID, edit)
+		return quizName, true
+	}
+
+	return "", false
+}
+
+// answerQuizSearch answers inline queries such as "add_qns bio" with the user's quizzes matching the search
+func answerQuizSearch(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, query *tgbotapi.InlineQuery) {
+	queryParts := strings.SplitN(strings.TrimSpace(query.Query), " ", 2)
+	switch queryParts[0] {
+	case "try_quiz", "add_qns", "edit_qns", "remove_qns", "delete_quiz", "share", "unshare", "visibility", "editors", "history", "sync":
+	default:
+		return
+	}
+
+	picker, err := newQuizPicker(ctx, client, fmt.Sprint(query.From.ID), queryParts[0])
+	if err != nil {
+		log.Printf("An error has occurred trying to search quizzes: %s", err)
+		return
+	}
+
+	var search string
+	if len(queryParts) > 1 {
+		search = strings.ToLower(queryParts[1])
+	}
+
+	var results []interface{}
+	for i, quizName := range picker.quizNames {
+		if !strings.Contains(strings.ToLower(quizName), search) {
+			continue
+		}
+
+		results = append(results, tgbotapi.NewInlineQueryResultArticle(
+			strconv.Itoa(i), quizName, picker.selectionText(quizName),
+		))
+
+		// telegram accepts at most 50 results per query
+		if len(results) == 50 {
+			break
+		}
+	}
+
+	inlineConf := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     0,
+		IsPersonal:    true,
+	}
+
+	if _, err := bot.Request(inlineConf); err != nil {
+		log.Printf("An error has occurred trying to answer quiz search: %s", err)
+	}
+}
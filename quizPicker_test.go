@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQuizPickerKeyboard(t *testing.T) {
+	picker := &quizPicker{action: "add_qns"}
+	for i := 0; i < pickerPageSize+3; i++ {
+		picker.quizNames = append(picker.quizNames, fmt.Sprint("quiz ", i))
+	}
+
+	// a full page of quizzes, then the page buttons and the search button
+	firstPage := picker.keyboard(1).InlineKeyboard
+	if len(firstPage) != pickerPageSize+2 {
+		t.Errorf("Expected %d rows on the first page but got: %d", pickerPageSize+2, len(firstPage))
+	}
+
+	picker.page = 1
+	lastPage := picker.keyboard(1).InlineKeyboard
+	if len(lastPage) != 3+2 {
+		t.Errorf("Expected %d rows on the last page but got: %d", 3+2, len(lastPage))
+	}
+	if *lastPage[0][0].CallbackData != fmt.Sprint("pick:", pickerPageSize) {
+		t.Error("Expected the last page to start at quiz " + fmt.Sprint(pickerPageSize) + " but got: " + *lastPage[0][0].CallbackData)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type quizSummary struct {
+	name        string
+	folder      string
+	tags        []string
+	numQns      int
+	score       string
+	bestScore   string
+	lastAttempt time.Time
+}
+
+// quizTags returns the tags of a quiz document
+func quizTags(quizData map[string]interface{}) []string {
+	var tags []string
+	if values, ok := quizData["tags"].([]interface{}); ok {
+		for _, value := range values {
+			if tag, ok := value.(string); ok {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+func newQuizSummary(doc *firestore.DocumentSnapshot) quizSummary {
+	summary := quizSummary{name: doc.Ref.ID}
+	summary.folder, _ = doc.Data()["folder"].(string)
+	summary.score, _ = doc.Data()["score"].(string)
+	summary.bestScore, _ = doc.Data()["bestScore"].(string)
+	summary.lastAttempt, _ = doc.Data()["lastAttempt"].(time.Time)
+
+	if numQns, ok := doc.Data()["numQns"].(int64); ok {
+		summary.numQns = int(numQns)
+	}
+
+	summary.tags = quizTags(doc.Data())
+
+	// quizzes attempted before best scores were recorded only have their last score
+	if summary.bestScore == "" && summary.score != "none" {
+		summary.bestScore = summary.score
+	}
+
+	return summary
+}
+
+// loadQuizSummaries returns the user's quizzes sorted by folder, then by name
+func loadQuizSummaries(ctx context.Context, client *firestore.Client, userID string) ([]quizSummary, error) {
+	var summaries []quizSummary
+
+	iter := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, newQuizSummary(doc))
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].folder != summaries[j].folder {
+			return summaries[i].folder < summaries[j].folder
+		}
+		return summaries[i].name < summaries[j].name
+	})
+
+	return summaries, nil
+}
+
+// filterQuizSummaries keeps quizzes with the tag given as "#tag", or quizzes inside the given folder
+func filterQuizSummaries(summaries []quizSummary, filter string) []quizSummary {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return summaries
+	}
+
+	var filtered []quizSummary
+	if strings.HasPrefix(filter, "#") {
+		tags := normalizeTags(filter)
+		for _, summary := range summaries {
+			if len(tags) > 0 && containsString(summary.tags, tags[0]) {
+				filtered = append(filtered, summary)
+			}
+		}
+	} else {
+		folder := strings.ToLower(normalizeFolder(filter))
+		for _, summary := range summaries {
+			quizFolder := strings.ToLower(summary.folder)
+			if quizFolder == folder || strings.HasPrefix(quizFolder, folder+"/") {
+				filtered = append(filtered, summary)
+			}
+		}
+	}
+
+	return filtered
+}
+
+// formatQuizList lists the quizzes one per line, under the name of each folder
+func formatQuizList(chatID int64, summaries []quizSummary) []string {
+	var lines []string
+	var currentFolder string
+
+	for _, summary := range summaries {
+		if summary.folder != currentFolder {
+			currentFolder = summary.folder
+			lines = append(lines, "", "<strong>"+escapeHTML(currentFolder)+"</strong>")
+		}
+
+		line := "- " + escapeHTML(summary.name) + " (" + trn(chatID, summary.numQns, "%d qn", "%d qns", summary.numQns)
+		if summary.lastAttempt.IsZero() {
+			line += tr(chatID, ", not attempted yet")
+		} else {
+			line += tr(chatID, ", last attempt %s", summary.lastAttempt.Format("2 Jan 2006"))
+		}
+		if summary.bestScore != "" {
+			line += tr(chatID, ", best %s", summary.bestScore)
+		}
+		line += ")"
+
+		if len(summary.tags) > 0 {
+			line += " " + escapeHTML(formatTags(summary.tags))
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+// normalizeTags splits user input on commas and spaces into lowercase tags without the leading #
+func normalizeTags(input string) []string {
+	var tags []string
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+	for _, field := range fields {
+		tag := strings.ToLower(strings.TrimLeft(field, "#"))
+		if tag != "" && !containsString(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// normalizeFolder trims the parts of a folder path such as " Biology / Chapter 3 "
+func normalizeFolder(input string) string {
+	var parts []string
+	for _, part := range strings.Split(input, "/") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+func updateQuizTags(ctx context.Context, client *firestore.Client, userID string, quizName string, tags []string, add bool) error {
+	tagValues := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		tagValues[i] = tag
+	}
+
+	var value interface{} = firestore.ArrayRemove(tagValues...)
+	if add {
+		value = firestore.ArrayUnion(tagValues...)
+	}
+
+	_, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName).Update(ctx, []firestore.Update{
+		{
+			Path:  "tags",
+			Value: value,
+		},
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, userID, quizName)
+	}
+	return err
+}
+
+func setQuizFolder(ctx context.Context, client *firestore.Client, userID string, quizName string, folder string) error {
+	_, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName).Update(ctx, []firestore.Update{
+		{
+			Path:  "folder",
+			Value: folder,
+		},
+	})
+	return err
+}
+
+// parseScore converts a score such as "3/5" to the fraction of questions answered correctly
+func parseScore(score string) (float64, bool) {
+	parts := strings.Split(score, "/")
+	if len(parts) != 2 {
+		return 0, false
+	}
+
+	correct, err1 := strconv.Atoi(parts[0])
+	total, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || total == 0 {
+		return 0, false
+	}
+
+	return float64(correct) / float64(total), true
+}
+
+func isBetterScore(score string, bestScore string) bool {
+	best, ok := parseScore(bestScore)
+	if !ok {
+		return true
+	}
+
+	current, _ := parseScore(score)
+	return current > best
+}
+
+// recordQuizAttempt saves the latest score, the attempt time and the best score of a quiz
+func recordQuizAttempt(ctx context.Context, client *firestore.Client, userID string, quizName string, scoreInt int, numQns int) error {
+	score := fmt.Sprint(scoreInt) + "/" + fmt.Sprint(numQns)
+	quizRef := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName)
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(quizRef)
+		if err != nil {
+			return err
+		}
+
+		updates := []firestore.Update{
+			{
+				Path:  "score",
+				Value: score,
+			},
+			{
+				Path:  "lastAttempt",
+				Value: time.Now(),
+			},
+		}
+		if isBetterScore(score, newQuizSummary(doc).bestScore) {
+			updates = append(updates, firestore.Update{
+				Path:  "bestScore",
+				Value: score,
+			})
+		}
+
+		return tx.Update(quizRef, updates)
+	})
+}
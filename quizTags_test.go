@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	outputTags := normalizeTags("#Biology, exam  biology,,revision")
+	expectedTags := []string{"biology", "exam", "revision"}
+
+	if !reflect.DeepEqual(outputTags, expectedTags) {
+		t.Errorf("Expected: %v but got: %v", expectedTags, outputTags)
+	}
+}
+
+func TestFilterQuizSummaries(t *testing.T) {
+	summaries := []quizSummary{
+		{name: "cells", folder: "Biology/Chapter 3", tags: []string{"exam"}},
+		{name: "plants", folder: "Biology"},
+		{name: "demo quiz"},
+	}
+
+	if filtered := filterQuizSummaries(summaries, "#exam"); len(filtered) != 1 || filtered[0].name != "cells" {
+		t.Errorf("Expected only cells tagged #exam but got: %v", filtered)
+	}
+
+	if filtered := filterQuizSummaries(summaries, "biology"); len(filtered) != 2 {
+		t.Errorf("Expected 2 quizzes in folder biology but got: %v", filtered)
+	}
+
+	if filtered := filterQuizSummaries(summaries, "Biology / Chapter 3"); len(filtered) != 1 {
+		t.Errorf("Expected 1 quiz in folder Biology/Chapter 3 but got: %v", filtered)
+	}
+}
+
+func TestIsBetterScore(t *testing.T) {
+	if !isBetterScore("3/5", "") {
+		t.Error("Expected any score to beat a missing best score")
+	}
+
+	if !isBetterScore("4/5", "1/2") {
+		t.Error("Expected 4/5 to beat 1/2")
+	}
+
+	if isBetterScore("2/5", "3/5") {
+		t.Error("Expected 2/5 not to beat 3/5")
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegram's limits on messages sent by a bot, in messages a second, used unless configured otherwise
+const (
+	globalSendRate  = 30.0
+	privateSendRate = 1.0
+	groupSendRate   = 20.0 / 60
+)
+
+// messages sent to one chat at once before they are paced, so that a question and its media go out together
+const chatSendBurst = 5
+
+// attempts at a request before its error is given to the caller
+const maxSendAttempts = 5
+
+// sendLimits are the rates the outbox sends at, in messages a second, and its attempts at each request
+type sendLimits struct {
+	global   float64
+	private  float64
+	group    float64
+	attempts int
+}
+
+// sendBucket is a token bucket, handing out send times in the order messages are queued
+type sendBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newSendBucket(rate float64, burst float64) *sendBucket {
+	return &sendBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// reserve takes a token and returns how long the message waits for it
+func (bucket *sendBucket) reserve(now time.Time) time.Duration {
+	if !bucket.last.IsZero() {
+		bucket.tokens = math.Min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	}
+	bucket.last = now
+	bucket.tokens--
+
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
+
+// outbox is the HTTP client of the bot. Messages to each chat are queued and sent in order by a worker
+// goroutine, which waits to stay within telegram's rate limits and retries requests that fail with 429.
+// A request whose caller waits for the answer is not held up: the chat's queue is sent without waiting
+// until it has gone. Private chats that blocked the bot are reported
+type outbox struct {
+	client    tgbotapi.HTTPClient
+	limits    sendLimits
+	onBlocked func(chatID int64)
+	sleep     func(delay time.Duration, hurry <-chan struct{})
+
+	mutex   sync.Mutex
+	global  *sendBucket
+	chats   map[int64]*sendBucket
+	queues  map[int64][]func()
+	waiting map[int64]int           // requests queued to each chat whose caller waits for them
+	hurry   map[int64]chan struct{} // closed to wake a chat's worker when a request is waited for
+}
+
+func newOutbox(client tgbotapi.HTTPClient, limits sendLimits, onBlocked func(chatID int64)) *outbox {
+	return &outbox{
+		client:    client,
+		limits:    limits,
+		onBlocked: onBlocked,
+		sleep:     sleepUnless,
+		global:    newSendBucket(limits.global, limits.global),
+		chats:     make(map[int64]*sendBucket),
+		queues:    make(map[int64][]func()),
+		waiting:   make(map[int64]int),
+		hurry:     make(map[int64]chan struct{}),
+	}
+}
+
+// sleepUnless sleeps for the delay, or until hurry is closed
+func sleepUnless(delay time.Duration, hurry <-chan struct{}) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-hurry:
+	}
+}
+
+// enqueue adds the job to the chat's queue, starting a worker for the chat if it has none
+func (box *outbox) enqueue(chatID int64, job func()) {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	queue, working := box.queues[chatID]
+	box.queues[chatID] = append(queue, job)
+	if !working {
+		go box.work(chatID)
+	}
+}
+
+// work runs the chat's jobs in order until its queue is empty
+func (box *outbox) work(chatID int64) {
+	for {
+		box.mutex.Lock()
+		queue := box.queues[chatID]
+		if len(queue) == 0 {
+			delete(box.queues, chatID)
+			delete(box.hurry, chatID)
+			box.mutex.Unlock()
+			return
+		}
+		job := queue[0]
+		box.queues[chatID] = queue[1:]
+		box.mutex.Unlock()
+
+		job()
+	}
+}
+
+// wait adds a request whose caller waits for it to the chat's count, or removes one, and wakes the worker
+func (box *outbox) wait(chatID int64, count int) {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	if box.waiting[chatID] += count; box.waiting[chatID] <= 0 {
+		delete(box.waiting, chatID)
+	}
+	if hurry, found := box.hurry[chatID]; found && count > 0 {
+		close(hurry)
+		delete(box.hurry, chatID)
+	}
+}
+
+// hurried reports whether a request to the chat is waited for, so that its queue is sent without waiting
+func (box *outbox) hurried(chatID int64) bool {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+	return box.waiting[chatID] > 0
+}
+
+// pause waits for the delay before the next request, unless the chat's queue is hurried
+func (box *outbox) pause(chatID int64, toChat bool, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	if !toChat {
+		box.sleep(delay, nil)
+		return
+	}
+
+	box.mutex.Lock()
+	if box.waiting[chatID] > 0 {
+		box.mutex.Unlock()
+		return
+	}
+	hurry, found := box.hurry[chatID]
+	if !found {
+		hurry = make(chan struct{})
+		box.hurry[chatID] = hurry
+	}
+	box.mutex.Unlock()
+
+	box.sleep(delay, hurry)
+}
+
+// reserve takes the chat's next turn and returns how long the message waits for it
+func (box *outbox) reserve(chatID int64, now time.Time) time.Duration {
+	box.mutex.Lock()
+	defer box.mutex.Unlock()
+
+	bucket, found := box.chats[chatID]
+	if !found {
+		// forget chats that have not been sent anything for a while
+		for id, idle := range box.chats {
+			if now.Sub(idle.last) > time.Hour {
+				delete(box.chats, id)
+			}
+		}
+
+		rate := box.limits.private
+		if chatID < 0 {
+			rate = box.limits.group
+		}
+		bucket = newSendBucket(rate, chatSendBurst)
+		box.chats[chatID] = bucket
+	}
+
+	wait := box.global.reserve(now)
+	if chatWait := bucket.reserve(now); chatWait > wait {
+		wait = chatWait
+	}
+	return wait
+}
+
+// isMessageMethod reports whether the telegram method posts to a chat, which is what the rate limits are for
+func isMessageMethod(method string) bool {
+	for _, prefix := range []string{"send", "edit", "copy", "forward", "stop"} {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestChatID returns the chat_id parameter of a form or multipart request
+func requestChatID(contentType string, body []byte) (int64, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, false
+	}
+
+	var chatID string
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return 0, false
+		}
+		chatID = values.Get("chat_id")
+
+	case "multipart/form-data":
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FormName() == "chat_id" {
+				value, _ := io.ReadAll(part)
+				chatID = string(value)
+				break
+			}
+		}
+	}
+
+	id, err := strconv.ParseInt(chatID, 10, 64)
+	return id, err == nil
+}
+
+// retryDelay is how long to wait before another attempt, doubling from a second
+func retryDelay(attempt int) time.Duration {
+	return time.Second << (attempt - 1)
+}
+
+// isBlockedError reports whether telegram refused a message because the user blocked the bot or deleted their account
+func isBlockedError(description string) bool {
+	return strings.Contains(description, "bot was blocked by the user") || strings.Contains(description, "user is deactivated")
+}
+
+// isRepeatable reports whether the request can be made again after it failed without an answer from telegram.
+// Messages cannot, as the first attempt may have been delivered
+func isRepeatable(method string) bool {
+	for _, prefix := range []string{"send", "copy", "forward"} {
+		if strings.HasPrefix(method, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// readRequest returns the telegram method and the body of the request, and the chat it posts to if it does
+func readRequest(req *http.Request) (string, []byte, int64, bool, error) {
+	method := path.Base(req.URL.Path)
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return method, nil, 0, false, err
+		}
+	}
+
+	chatID, hasChat := requestChatID(req.Header.Get("Content-Type"), body)
+	return method, body, chatID, hasChat && isMessageMethod(method), nil
+}
+
+// Do sends requests to a chat after the messages queued to it, and waits for the answer. The queue is hurried
+// meanwhile, so that the caller only waits for the messages before its own to be sent
+func (box *outbox) Do(req *http.Request) (*http.Response, error) {
+	if path.Base(req.URL.Path) == "getUpdates" {
+		// long polling is retried by the updates loop itself
+		return box.client.Do(req)
+	}
+
+	method, body, chatID, toChat, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if !toChat {
+		return box.send(req, method, body, 0, false)
+	}
+
+	var resp *http.Response
+	done := make(chan struct{})
+	box.wait(chatID, 1)
+	box.enqueue(chatID, func() {
+		resp, err = box.send(req, method, body, chatID, true)
+		box.wait(chatID, -1)
+		close(done)
+	})
+	<-done
+	return resp, err
+}
+
+// send makes the request, first waiting for its turn if it posts to a chat, and retries it if telegram asks to.
+// A hurried request is made once, without waiting
+func (box *outbox) send(req *http.Request, method string, body []byte, chatID int64, toChat bool) (*http.Response, error) {
+	if toChat {
+		box.pause(chatID, toChat, box.reserve(chatID, time.Now()))
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req.Clone(req.Context())
+		attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+		attemptReq.ContentLength = int64(len(body))
+
+		delay := retryDelay(attempt)
+		resp, err := box.client.Do(attemptReq)
+		if err == nil {
+			respBody, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				return nil, readErr
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+			var apiResp tgbotapi.APIResponse
+			json.Unmarshal(respBody, &apiResp)
+
+			if resp.StatusCode == http.StatusTooManyRequests {
+				// telegram refused the request, so it is safe to make again
+				if apiResp.Parameters != nil && apiResp.Parameters.RetryAfter > 0 {
+					delay = time.Duration(apiResp.Parameters.RetryAfter) * time.Second
+				}
+			} else if resp.StatusCode < http.StatusInternalServerError || !isRepeatable(method) {
+				if resp.StatusCode == http.StatusForbidden && toChat && chatID > 0 && isBlockedError(apiResp.Description) {
+					box.onBlocked(chatID)
+				}
+				return resp, nil
+			}
+		} else if !isRepeatable(method) {
+			return resp, err
+		}
+
+		if attempt >= box.limits.attempts || (toChat && box.hurried(chatID)) {
+			return resp, err
+		}
+		log.Printf("Retrying %s in %s, attempt %d failed", method, delay, attempt)
+		box.pause(chatID, toChat, delay)
+	}
+}
+
+// queuedClient is the client of the outbox's workers, making requests without queueing them again
+type queuedClient struct {
+	box *outbox
+}
+
+func (client queuedClient) Do(req *http.Request) (*http.Response, error) {
+	method, body, chatID, toChat, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return client.box.send(req, method, body, chatID, toChat)
+}
+
+// queueJob runs the job on the chat's queue, after the messages queued to it, with a bot whose requests are
+// made from the queue. It is used for sends that take a while to prepare but must keep their place in the chat
+func queueJob(bot *tgbotapi.BotAPI, chatID int64, job func(worker *tgbotapi.BotAPI)) {
+	box, ok := bot.Client.(*outbox)
+	if !ok {
+		go job(bot)
+		return
+	}
+
+	worker := *bot
+	worker.Client = queuedClient{box: box}
+	box.enqueue(chatID, func() {
+		job(&worker)
+	})
+}
+
+// queueMessage sends the message or edit to the chat in the background, after the messages queued to it.
+// It does not wait, so it is used for messages whose answer is not needed
+func queueMessage(bot *tgbotapi.BotAPI, chatID int64, c tgbotapi.Chattable) {
+	box, ok := bot.Client.(*outbox)
+	if !ok {
+		if _, err := bot.Request(c); err != nil {
+			log.Printf("An error has occurred trying to send message to %d: %s", chatID, err)
+		}
+		return
+	}
+
+	worker := *bot
+	worker.Client = queuedClient{box: box}
+	var job func()
+	job = func() {
+		_, err := worker.Request(c)
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests && box.hurried(chatID) {
+			// it was not retried so as not to hold up a message that is waited for, and is sent after it instead
+			box.enqueue(chatID, job)
+		} else if err != nil {
+			log.Printf("An error has occurred trying to send message to %d: %s", chatID, err)
+		}
+	}
+	box.enqueue(chatID, job)
+}
+
+// setUserInactive records whether the user has blocked the bot, so that scheduled messages skip them
+func setUserInactive(ctx context.Context, client *firestore.Client, userID string, inactive bool) {
+	_, err := client.Collection("USERS").Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "inactive", Value: inactive},
+	})
+	if err != nil {
+		log.Printf("An error has occurred trying to mark user %s inactive: %s", userID, err)
+	}
+}
+
+// userInactive reports whether the user has blocked the bot
+func userInactive(ctx context.Context, client *firestore.Client, userID string) bool {
+	doc, err := client.Collection("USERS").Doc(userID).Get(ctx)
+	if err != nil {
+		return false
+	}
+	inactive, _ := doc.Data()["inactive"].(bool)
+	return inactive
+}
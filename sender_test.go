@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSendBucket(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	bucket := newSendBucket(1, 2)
+
+	if wait := bucket.reserve(now); wait != 0 {
+		t.Errorf("Expected no wait within the burst but got: %s", wait)
+	}
+	bucket.reserve(now)
+	if wait := bucket.reserve(now); wait != time.Second {
+		t.Errorf("Expected to wait a second after the burst but got: %s", wait)
+	}
+	if wait := bucket.reserve(now.Add(5 * time.Second)); wait != 0 {
+		t.Errorf("Expected the bucket to refill but got: %s", wait)
+	}
+}
+
+func TestRequestChatID(t *testing.T) {
+	form := url.Values{"chat_id": {"-1001"}, "text": {"hi"}}.Encode()
+	if chatID, ok := requestChatID("application/x-www-form-urlencoded", []byte(form)); !ok || chatID != -1001 {
+		t.Errorf("Expected chat -1001 from a form but got: %d %v", chatID, ok)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("caption", "formula")
+	writer.WriteField("chat_id", "42")
+	writer.Close()
+	if chatID, ok := requestChatID(writer.FormDataContentType(), body.Bytes()); !ok || chatID != 42 {
+		t.Errorf("Expected chat 42 from a multipart upload but got: %d %v", chatID, ok)
+	}
+
+	if _, ok := requestChatID("application/x-www-form-urlencoded", []byte("offset=3")); ok {
+		t.Errorf("Expected no chat without chat_id")
+	}
+}
+
+// fakeTelegram answers requests with the given statuses and bodies in turn
+type fakeTelegram struct {
+	statuses []int
+	bodies   []string
+	calls    int
+}
+
+func (fake *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	i := fake.calls
+	fake.calls++
+	return &http.Response{StatusCode: fake.statuses[i], Body: io.NopCloser(strings.NewReader(fake.bodies[i]))}, nil
+}
+
+func newTestRequest(method string, chatID string) *http.Request {
+	req, _ := http.NewRequest("POST", "https://api.telegram.org/botTOKEN/"+method, strings.NewReader(url.Values{"chat_id": {chatID}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestOutboxRetriesAndBlocking(t *testing.T) {
+	var slept []time.Duration
+	var blocked []int64
+
+	fake := &fakeTelegram{
+		statuses: []int{429, 200},
+		bodies:   []string{`{"ok":false,"error_code":429,"parameters":{"retry_after":7}}`, `{"ok":true,"result":{}}`},
+	}
+	box := newOutbox(fake, defaultConfig().sendLimits(), func(chatID int64) { blocked = append(blocked, chatID) })
+	box.sleep = func(delay time.Duration, hurry <-chan struct{}) { slept = append(slept, delay) }
+
+	// queued messages are sent by the chat's worker, which waits when telegram asks it to
+	resp, err := queuedClient{box: box}.Do(newTestRequest("sendMessage", "42"))
+	if err != nil || resp.StatusCode != 200 || fake.calls != 2 {
+		t.Fatalf("Expected a retry after 429 but got: %v %v after %d calls", resp, err, fake.calls)
+	}
+	if len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Errorf("Expected to wait retry_after before retrying but slept: %v", slept)
+	}
+	if respBody, _ := io.ReadAll(resp.Body); string(respBody) != `{"ok":true,"result":{}}` {
+		t.Errorf("Expected the response body to be readable but got: %q", respBody)
+	}
+
+	// a message that is waited for is not held up by retries
+	fake = &fakeTelegram{
+		statuses: []int{429},
+		bodies:   []string{`{"ok":false,"error_code":429,"parameters":{"retry_after":7}}`},
+	}
+	box.client = fake
+	if resp, _ := box.Do(newTestRequest("sendMessage", "42")); resp.StatusCode != 429 || fake.calls != 1 {
+		t.Errorf("Expected a message that is waited for to be tried once but made %d calls", fake.calls)
+	}
+
+	fake = &fakeTelegram{
+		statuses: []int{403},
+		bodies:   []string{`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`},
+	}
+	box.client = fake
+	if resp, _ := box.Do(newTestRequest("sendMessage", "42")); resp.StatusCode != 403 || fake.calls != 1 {
+		t.Errorf("Expected a blocked user not to be retried")
+	}
+	if len(blocked) != 1 || blocked[0] != 42 {
+		t.Errorf("Expected user 42 to be reported as blocked but got: %v", blocked)
+	}
+}
+
+func TestOutboxDoesNotResendMessages(t *testing.T) {
+	fake := &fakeTelegram{
+		statuses: []int{502, 502, 200},
+		bodies:   []string{`{"ok":false}`, `{"ok":false}`, `{"ok":true,"result":true}`},
+	}
+	box := newOutbox(fake, defaultConfig().sendLimits(), func(chatID int64) {})
+	box.sleep = func(delay time.Duration, hurry <-chan struct{}) {}
+	worker := queuedClient{box: box}
+
+	if resp, _ := worker.Do(newTestRequest("sendMessage", "42")); resp.StatusCode != 502 || fake.calls != 1 {
+		t.Errorf("Expected a message not to be sent again after a server error but made %d calls", fake.calls)
+	}
+	if resp, _ := worker.Do(newTestRequest("editMessageText", "42")); resp.StatusCode != 200 || fake.calls != 3 {
+		t.Errorf("Expected an edit to be retried after a server error but made %d calls", fake.calls)
+	}
+}
+
+// orderedTelegram records the text of each message it is sent, refusing texts with 429 as often as limited says
+type orderedTelegram struct {
+	mutex   sync.Mutex
+	texts   []string
+	limited map[string]int
+}
+
+func (fake *orderedTelegram) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	values, _ := url.ParseQuery(string(body))
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	text := values.Get("text")
+	fake.texts = append(fake.texts, text)
+	if fake.limited[text] > 0 {
+		fake.limited[text]--
+		return &http.Response{StatusCode: 429, Body: io.NopCloser(strings.NewReader(`{"ok":false,"error_code":429,"parameters":{"retry_after":30}}`))}, nil
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"ok":true,"result":{"message_id":1}}`))}, nil
+}
+
+func newTestBot(box *outbox) *tgbotapi.BotAPI {
+	bot := &tgbotapi.BotAPI{Token: "TOKEN", Client: box}
+	bot.SetAPIEndpoint(tgbotapi.APIEndpoint)
+	return bot
+}
+
+func TestQueueMessage(t *testing.T) {
+	fake := &orderedTelegram{}
+	box := newOutbox(fake, defaultConfig().sendLimits(), func(chatID int64) {})
+	// waits for a turn only end when a message is waited for
+	box.sleep = func(delay time.Duration, hurry <-chan struct{}) { <-hurry }
+	bot := newTestBot(box)
+
+	// the private chat's burst is used up, so the last queued message waits without holding up the caller
+	for _, text := range []string{"one", "two", "three", "four", "five", "six"} {
+		queueMessage(bot, 42, tgbotapi.NewMessage(42, text))
+	}
+
+	// a message that is waited for hurries the queue, and is sent after the queued ones
+	if _, err := bot.Send(tgbotapi.NewMessage(42, "seven")); err != nil {
+		t.Fatalf("Expected the message to be sent but got: %s", err)
+	}
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if strings.Join(fake.texts, " ") != "one two three four five six seven" {
+		t.Errorf("Expected the messages in the order they were sent but got: %v", fake.texts)
+	}
+}
+
+func TestQueueMessageRequeuedWhenHurried(t *testing.T) {
+	fake := &orderedTelegram{limited: map[string]int{"late": 1}}
+	box := newOutbox(fake, defaultConfig().sendLimits(), func(chatID int64) {})
+	box.sleep = func(delay time.Duration, hurry <-chan struct{}) {
+		t.Errorf("Expected no wait while hurried but waited %s", delay)
+	}
+
+	// while a message is waited for, a queued one refused with 429 goes to the back of the queue
+	done := make(chan struct{})
+	box.wait(42, 1)
+	queueMessage(newTestBot(box), 42, tgbotapi.NewMessage(42, "late"))
+	box.enqueue(42, func() {
+		box.wait(42, -1)
+		box.enqueue(42, func() { close(done) })
+	})
+	<-done
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if strings.Join(fake.texts, " ") != "late late" {
+		t.Errorf("Expected the refused message to be sent again but got: %v", fake.texts)
+	}
+}
+
+func TestQueueJob(t *testing.T) {
+	fake := &orderedTelegram{}
+	box := newOutbox(fake, defaultConfig().sendLimits(), func(chatID int64) {})
+	bot := newTestBot(box)
+
+	// a job that takes a while keeps its place before the messages sent after it
+	queueJob(bot, 42, func(worker *tgbotapi.BotAPI) {
+		time.Sleep(10 * time.Millisecond)
+		worker.Send(tgbotapi.NewMessage(42, "formula"))
+	})
+	if _, err := bot.Send(tgbotapi.NewMessage(42, "question")); err != nil {
+		t.Fatalf("Expected the message to be sent but got: %s", err)
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if strings.Join(fake.texts, " ") != "formula question" {
+		t.Errorf("Expected the job's message first but got: %v", fake.texts)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// share codes are used as /start payloads, which only allow letters, digits, _ and -
+const shareCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+const shareCodeLength = 8
+
+var errShareCodeNotFound = errors.New("share code not found")
+
+func newShareCode() (string, error) {
+	code := make([]byte, shareCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(shareCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = shareCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+func shareLink(botUsername string, code string) string {
+	return "https://t.me/" + botUsername + "?start=" + code
+}
+
+// createShareCode returns the quiz's existing share code, or creates one if the quiz has not been shared yet
+func createShareCode(ctx context.Context, client *firestore.Client, ownerID string, quizName string) (string, error) {
+	iter := client.Collection("SHARES").Where("ownerID", "==", ownerID).Where("quizName", "==", quizName).Limit(1).Documents(ctx)
+	defer iter.Stop()
+
+	doc, err := iter.Next()
+	if err == nil {
+		return doc.Ref.ID, nil
+	}
+	if err != iterator.Done {
+		return "", err
+	}
+
+	// retry in the unlikely case that the code is already taken
+	for attempt := 0; attempt < 3; attempt++ {
+		code, err := newShareCode()
+		if err != nil {
+			return "", err
+		}
+
+		_, err = client.Collection("SHARES").Doc(code).Create(ctx, map[string]interface{}{
+			"ownerID":   ownerID,
+			"quizName":  quizName,
+			"createdAt": time.Now(),
+		})
+		if status.Code(err) == codes.AlreadyExists {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return code, nil
+	}
+
+	return "", errors.New("could not find an unused share code")
+}
+
+// lookupShareCode returns the owner and name of the quiz shared with the code
+func lookupShareCode(ctx context.Context, client *firestore.Client, code string) (string, string, error) {
+	doc, err := client.Collection("SHARES").Doc(code).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return "", "", errShareCodeNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	ownerID, _ := doc.Data()["ownerID"].(string)
+	quizName, _ := doc.Data()["quizName"].(string)
+	return ownerID, quizName, nil
+}
+
+// revokeShareCodes deletes every share code of the quiz, returning how many were revoked
+func revokeShareCodes(ctx context.Context, client *firestore.Client, ownerID string, quizName string) (int, error) {
+	docs, err := client.Collection("SHARES").Where("ownerID", "==", ownerID).Where("quizName", "==", quizName).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, doc := range docs {
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(docs), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewShareCode(t *testing.T) {
+	code, err := newShareCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != shareCodeLength {
+		t.Errorf("Expected a share code of length %d but got: %s", shareCodeLength, code)
+	}
+	for _, char := range code {
+		if !strings.ContainsRune(shareCodeAlphabet, char) {
+			t.Error("Expected only share code alphabet characters but got: " + code)
+		}
+	}
+}
+
+func TestShareLink(t *testing.T) {
+	outputStr := shareLink("go_quiz_test_bot", "Ab3dEf7h")
+
+	if outputStr != "https://t.me/go_quiz_test_bot?start=Ab3dEf7h" {
+		t.Error("Expected: https://t.me/go_quiz_test_bot?start=Ab3dEf7h but got: " + outputStr)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// kinds of storage errors, told apart so that users get a message that fits
+const (
+	storageNotFound    = "not found"
+	storageConflict    = "conflict"
+	storageUnavailable = "unavailable"
+	storageForbidden   = "forbidden"
+	storageFailed      = "failed"
+)
+
+// attempts at a storage operation that keeps failing with a transient error
+const storageAttempts = 3
+
+func classifyStorageError(err error) string {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return storageNotFound
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return storageConflict
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return storageUnavailable
+	}
+
+	switch {
+	case errors.Is(err, errQuestionChanged), errors.Is(err, errQuizExists):
+		return storageConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return storageUnavailable
+	case errors.Is(err, errNotEditor):
+		return storageForbidden
+	}
+	return storageFailed
+}
+
+// retryStorage runs a storage operation again while it fails with a transient error
+func retryStorage(operation func() error) error {
+	var err error
+	for attempt := 1; attempt <= storageAttempts; attempt++ {
+		if err = operation(); err == nil || classifyStorageError(err) != storageUnavailable {
+			return err
+		}
+		if attempt < storageAttempts {
+			time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+		}
+	}
+	return err
+}
+
+// newErrorRef returns a short reference that is logged with an error and shown to the user,
+// so that the log can be found from what they report
+func newErrorRef() string {
+	ref := make([]byte, 4)
+	if _, err := rand.Read(ref); err != nil {
+		return time.Now().Format("150405")
+	}
+	return hex.EncodeToString(ref)
+}
+
+// logStorageError logs what could not be done and returns the reference of the log
+func logStorageError(action string, err error) string {
+	ref := newErrorRef()
+	log.Printf("An error has occurred trying to %s [ref %s]: %s", action, ref, err)
+	return ref
+}
+
+// storageErrorText tells the user in the chat's language what could not be done and why, e.g. for action
+// "save your questions"
+func storageErrorText(chatID int64, action string, err error, ref string) string {
+	action = tr(chatID, action)
+
+	var text string
+	switch classifyStorageError(err) {
+	case storageNotFound:
+		text = tr(chatID, "Sorry, I could not %s because it no longer exists.", action)
+	case storageConflict:
+		text = tr(chatID, "Sorry, I could not %s because it was changed at the same time. Please try again.", action)
+	case storageUnavailable:
+		text = tr(chatID, "Sorry, I could not %s because storage is unavailable right now. Please try again in a minute.", action)
+	case storageForbidden:
+		text = tr(chatID, "Sorry, I could not %s because you can no longer edit this quiz.", action)
+	default:
+		text = tr(chatID, "Sorry, I could not %s. Please try again.", action)
+	}
+	return text + tr(chatID, " (error ref %s)", ref)
+}
+
+// reportStorageError logs a storage error and tells the user about it
+func reportStorageError(chatID int64, bot *tgbotapi.BotAPI, action string, err error) {
+	sendSimpleMsg(chatID, storageErrorText(chatID, action, err, logStorageError(action, err)), bot)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyStorageError(t *testing.T) {
+	cases := map[error]string{
+		status.Error(codes.NotFound, "no quiz"):       storageNotFound,
+		status.Error(codes.Aborted, "contention"):     storageConflict,
+		fmt.Errorf("editing: %w", errQuestionChanged): storageConflict,
+		errNotEditor: storageForbidden,
+		status.Error(codes.Unavailable, "try later"):         storageUnavailable,
+		status.Error(codes.DeadlineExceeded, "too slow"):     storageUnavailable,
+		status.Error(codes.PermissionDenied, "rules denied"): storageFailed,
+		errors.New("something else"):                         storageFailed,
+	}
+	for err, expected := range cases {
+		if kind := classifyStorageError(err); kind != expected {
+			t.Errorf("Expected %q to be %s but got: %s", err, expected, kind)
+		}
+	}
+}
+
+func TestRetryStorage(t *testing.T) {
+	attempts := 0
+	err := retryStorage(func() error {
+		attempts++
+		if attempts < 2 {
+			return status.Error(codes.Unavailable, "try later")
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("Expected success on the second attempt but got %d attempts and: %v", attempts, err)
+	}
+
+	attempts = 0
+	err = retryStorage(func() error {
+		attempts++
+		return status.Error(codes.NotFound, "no quiz")
+	})
+	if status.Code(err) != codes.NotFound || attempts != 1 {
+		t.Errorf("Expected a not found error without retries but got %d attempts and: %v", attempts, err)
+	}
+}
+
+func TestStorageErrorText(t *testing.T) {
+	outputStr := storageErrorText(1, "save your questions", status.Error(codes.Unavailable, "try later"), "1a2b3c4d")
+	if !strings.HasPrefix(outputStr, "Sorry, I could not save your questions because storage is unavailable") || !strings.HasSuffix(outputStr, "(error ref 1a2b3c4d)") {
+		t.Errorf("Expected a friendly message with the reference but got: %q", outputStr)
+	}
+}
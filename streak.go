@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// local hour at which users who turned on nudges are reminded of a streak about to break
+const nudgeHour = 20
+
+// days are compared as dates in the user's time zone
+const studyDayLayout = "2006-01-02"
+
+// studyStreak is the number of consecutive days a user finished a quiz or a review
+type studyStreak struct {
+	current int
+	best    int
+	lastDay string
+}
+
+func newStudyStreak(userData map[string]interface{}) studyStreak {
+	var streak studyStreak
+	if current, ok := userData["streak"].(int64); ok {
+		streak.current = int(current)
+	}
+	if best, ok := userData["bestStreak"].(int64); ok {
+		streak.best = int(best)
+	}
+	streak.lastDay, _ = userData["lastStudyDay"].(string)
+	return streak
+}
+
+func dayBefore(day string) string {
+	date, err := time.Parse(studyDayLayout, day)
+	if err != nil {
+		return ""
+	}
+	return date.AddDate(0, 0, -1).Format(studyDayLayout)
+}
+
+// studiedOn returns the streak after studying on the day, which continues if the last study day was the day before
+func (streak studyStreak) studiedOn(today string) studyStreak {
+	switch streak.lastDay {
+	case today:
+		return streak
+	case dayBefore(today):
+		streak.current++
+	default:
+		streak.current = 1
+	}
+
+	streak.lastDay = today
+	if streak.current > streak.best {
+		streak.best = streak.current
+	}
+	return streak
+}
+
+// currentOn returns the streak as of the day, 0 if a day has been missed since the last study day
+func (streak studyStreak) currentOn(today string) int {
+	if streak.lastDay == today || streak.lastDay == dayBefore(today) {
+		return streak.current
+	}
+	return 0
+}
+
+// atRiskOn reports whether the streak breaks unless the user studies on the day
+func (streak studyStreak) atRiskOn(today string) bool {
+	return streak.current > 0 && streak.lastDay == dayBefore(today)
+}
+
+func formatStreak(chatID int64, current int, best int) string {
+	return trn(chatID, current, "🔥 Streak: %d day", "🔥 Streak: %d days", current) +
+		trn(chatID, best, ", best %d day", ", best %d days", best)
+}
+
+// userLocation returns the time zone the user chose for daily questions, UTC if none
+func userLocation(userData map[string]interface{}) *time.Location {
+	timezone, _ := userData["timezone"].(string)
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// recordStudyDay counts today towards the user's streak, returning the streak
+func recordStudyDay(ctx context.Context, client *firestore.Client, userID string) (studyStreak, error) {
+	userRef := client.Collection("USERS").Doc(userID)
+	var streak studyStreak
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if !doc.Exists() {
+			// members of a group who have not started the bot have no streak to keep
+			return nil
+		}
+		userData := doc.Data()
+
+		streak = newStudyStreak(userData).studiedOn(time.Now().In(userLocation(userData)).Format(studyDayLayout))
+		return tx.Set(userRef, map[string]interface{}{
+			"streak":       streak.current,
+			"bestStreak":   streak.best,
+			"lastStudyDay": streak.lastDay,
+		}, firestore.MergeAll)
+	})
+	return streak, err
+}
+
+// recordStudyDayText records today towards the user's streak, returning the streak to show in the chat, or ""
+// if it failed
+func recordStudyDayText(ctx context.Context, client *firestore.Client, chatID int64, userID string) string {
+	streak, err := recordStudyDay(ctx, client, userID)
+	if err != nil {
+		log.Printf("An error has occurred trying to update streak: %s", err)
+		return ""
+	}
+	return formatStreak(chatID, streak.current, streak.best)
+}
+
+// setNudges turns the user's evening reminder on or off
+func setNudges(ctx context.Context, client *firestore.Client, userID string, enabled bool) error {
+	userRef := client.Collection("USERS").Doc(userID)
+	if !enabled {
+		_, err := userRef.Set(ctx, map[string]interface{}{
+			"nudges":      false,
+			"nextNudgeAt": firestore.Delete,
+		}, firestore.MergeAll)
+		return err
+	}
+
+	doc, err := userRef.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = userRef.Set(ctx, map[string]interface{}{
+		"nudges":      true,
+		"nextNudgeAt": nextDailySend(time.Now(), nudgeHour, 0, userLocation(doc.Data())),
+	}, firestore.MergeAll)
+	return err
+}
+
+// claimNudge moves the user's next nudge to the following evening, returning the streak if it is about to break
+func claimNudge(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, now time.Time) (studyStreak, bool, error) {
+	var streak studyStreak
+	atRisk := false
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		nextNudgeAt, _ := doc.Data()["nextNudgeAt"].(time.Time)
+		if enabled, _ := doc.Data()["nudges"].(bool); !enabled || nextNudgeAt.After(now) {
+			atRisk = false
+			return nil
+		}
+
+		location := userLocation(doc.Data())
+		streak = newStudyStreak(doc.Data())
+		atRisk = streak.atRiskOn(now.In(location).Format(studyDayLayout))
+		return tx.Update(ref, []firestore.Update{
+			{Path: "nextNudgeAt", Value: nextDailySend(now, nudgeHour, 0, location)},
+		})
+	})
+	return streak, atRisk, err
+}
+
+// sendDueNudges reminds users whose streak breaks tonight to try a quiz
+func sendDueNudges(ctx context.Context, client *firestore.Client, bot *tgbotapi.BotAPI, now time.Time) {
+	docs, err := client.Collection("USERS").Where("nextNudgeAt", "<=", now).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load nudges: %s", err)
+		return
+	}
+
+	for _, doc := range docs {
+		// users who blocked the bot are not nudged until they unblock it
+		if inactive, _ := doc.Data()["inactive"].(bool); inactive {
+			continue
+		}
+
+		streak, atRisk, err := claimNudge(ctx, client, doc.Ref, now)
+		if err != nil {
+			log.Printf("An error has occurred trying to claim nudge: %s", err)
+			continue
+		}
+		if atRisk {
+			notifyUserPlural(ctx, client, doc.Ref.ID, bot, streak.current,
+				"Your %d day streak ends tonight! Try a quiz with /try_quiz to keep it going.",
+				"Your %d day streak ends tonight! Try a quiz with /try_quiz to keep it going.", streak.current)
+		}
+	}
+}
+
+// sendProfile shows the user's quizzes, streak and reminder settings
+func sendProfile(ctx context.Context, client *firestore.Client, chatID int64, bot *tgbotapi.BotAPI, userID string, username string) {
+	doc, err := client.Collection("USERS").Doc(userID).Get(ctx)
+	if err != nil {
+		log.Printf("An error has occurred trying to load profile: %s", err)
+		sendSimpleMsg(chatID, tr(chatID, "Sorry, your profile could not be loaded. Please try again."), bot)
+		return
+	}
+	userData := doc.Data()
+
+	quizDocs, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("An error has occurred trying to load quizzes: %s", err)
+	}
+	subscriptions, err := loadDailySubscriptions(ctx, client, userID)
+	if err != nil {
+		log.Printf("An error has occurred trying to load daily questions: %s", err)
+	}
+
+	location := userLocation(userData)
+	streak := newStudyStreak(userData)
+	nudges, _ := userData["nudges"].(bool)
+
+	nudgesText := tr(chatID, "off")
+	if nudges {
+		nudgesText = tr(chatID, "on, at %02d:00", nudgeHour)
+	}
+
+	current := streak.currentOn(time.Now().In(location).Format(studyDayLayout))
+	msg := tgbotapi.NewMessage(chatID, "")
+	msg.ParseMode = "HTML"
+	msg.Text = tr(chatID, "<strong>Profile of %s</strong>\n", escapeHTML(username)) +
+		tr(chatID, "<strong>Quizzes:</strong> %d\n", len(quizDocs)) +
+		trn(chatID, current, "<strong>Current streak:</strong> %d day\n", "<strong>Current streak:</strong> %d days\n", current) +
+		trn(chatID, streak.best, "<strong>Best streak:</strong> %d day\n", "<strong>Best streak:</strong> %d days\n", streak.best) +
+		tr(chatID, "<strong>Daily questions:</strong> %d\n", len(subscriptions)) +
+		tr(chatID, "<strong>Time zone:</strong> %s\n", location.String()) +
+		tr(chatID, "<strong>Streak reminders:</strong> %s\n\n", nudgesText) +
+		tr(chatID, "<i>Turn streak reminders on or off with /nudge on or /nudge off</i>")
+
+	queueMessage(bot, msg.ChatID, msg)
+}
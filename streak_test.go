@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStudiedOn(t *testing.T) {
+	streak := studyStreak{current: 3, best: 3, lastDay: "2024-02-28"}
+
+	if next := streak.studiedOn("2024-02-29"); next.current != 4 || next.best != 4 || next.lastDay != "2024-02-29" {
+		t.Errorf("Expected the streak to continue on the next day but got: %+v", next)
+	}
+	if next := streak.studiedOn("2024-02-28"); next != streak {
+		t.Errorf("Expected a second study on the same day to keep the streak but got: %+v", next)
+	}
+	if next := streak.studiedOn("2024-03-02"); next.current != 1 || next.best != 3 {
+		t.Errorf("Expected a missed day to restart the streak but got: %+v", next)
+	}
+	if next := (studyStreak{}).studiedOn("2024-03-01"); next.current != 1 || next.best != 1 {
+		t.Errorf("Expected a first study day to start a streak but got: %+v", next)
+	}
+}
+
+func TestStreakOnDay(t *testing.T) {
+	streak := studyStreak{current: 5, best: 7, lastDay: "2023-12-31"}
+
+	if !streak.atRiskOn("2024-01-01") || streak.currentOn("2024-01-01") != 5 {
+		t.Errorf("Expected a 5 day streak at risk the next day")
+	}
+	if streak.atRiskOn("2023-12-31") {
+		t.Errorf("Expected no risk on a day already studied")
+	}
+	if streak.currentOn("2024-01-02") != 0 || streak.atRiskOn("2024-01-02") {
+		t.Errorf("Expected a broken streak after a missed day")
+	}
+}
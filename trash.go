@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// deleted quizzes and removed questions are kept in the trash for this long
+const trashRetention = 30 * 24 * time.Hour
+
+var errQuizExists = errors.New("quiz already exists")
+
+type trashEntry struct {
+	ref        *firestore.DocumentRef
+	kind       string // "quiz" or "questions"
+	quizName   string
+	data       map[string]interface{}
+	media      map[string]interface{} // media of trashed questions, by question
+	formatting map[string]interface{} // kept formatting of trashed questions, by question
+	deletedAt  time.Time
+	expiresAt  time.Time
+}
+
+func newTrashEntry(ref *firestore.DocumentRef, data map[string]interface{}) trashEntry {
+	entry := trashEntry{ref: ref}
+	entry.kind, _ = data["kind"].(string)
+	entry.quizName, _ = data["quizName"].(string)
+	entry.data, _ = data["data"].(map[string]interface{})
+	entry.media, _ = data["media"].(map[string]interface{})
+	entry.formatting, _ = data["formatting"].(map[string]interface{})
+	entry.deletedAt, _ = data["deletedAt"].(time.Time)
+	entry.expiresAt, _ = data["expiresAt"].(time.Time)
+	return entry
+}
+
+// expired reports whether the entry has been kept for the whole retention period, and is purged
+func (entry trashEntry) expired(now time.Time) bool {
+	return now.After(entry.expiresAt)
+}
+
+func trashCollection(client *firestore.Client, userID string) *firestore.CollectionRef {
+	return client.Collection("USERS").Doc(userID).Collection("TRASH")
+}
+
+func newTrashDoc(kind string, quizName string, data map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"kind":      kind,
+		"quizName":  quizName,
+		"data":      data,
+		"deletedAt": now,
+		"expiresAt": now.Add(trashRetention),
+	}
+}
+
+// moveQuizToTrash deletes the quiz document and keeps a copy of it in the user's trash
+func moveQuizToTrash(ctx context.Context, client *firestore.Client, userID string, quizName string, quizData map[string]interface{}) error {
+	batch := client.Batch()
+	batch.Create(trashCollection(client, userID).NewDoc(), newTrashDoc("quiz", quizName, quizData))
+	batch.Delete(client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName))
+	batch.Delete(catalogDoc(client, userID, quizName))
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// removeQuestionsToTrash deletes the removed questions from the quiz document and keeps them in the owner's trash,
+// skipping questions another editor has already removed or changed
+func removeQuestionsToTrash(ctx context.Context, client *firestore.Client, ownerID string, quizName string, editorID string, editorName string, removed map[string]string) error {
+	quizRef := client.Collection("USERS").Doc(ownerID).Collection("QUIZZES").Doc(quizName)
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(quizRef)
+		if err != nil {
+			return err
+		}
+		if !canEditQuiz(doc.Data(), ownerID, editorID) {
+			return errNotEditor
+		}
+
+		existing := quizQuestions(doc.Data())
+		existingMedia := quizAttachments(doc.Data())
+		existingFormatting := quizFormatting(doc.Data())
+		removedData := make(map[string]interface{})
+		removedMedia := make(map[string]interface{})
+		removedFormatting := make(map[string]interface{})
+		var updates []firestore.Update
+		for question, answer := range removed {
+			if existing[question] != answer {
+				continue
+			}
+
+			removedData[question] = answer
+			delete(existing, question)
+			updates = append(updates, firestore.Update{FieldPath: []string{question}, Value: firestore.Delete})
+			if attachment, ok := existingMedia[question]; ok {
+				removedMedia[question] = attachment.data()
+				updates = append(updates, firestore.Update{FieldPath: []string{"media", question}, Value: firestore.Delete})
+			}
+			if formatting, ok := existingFormatting[question]; ok {
+				removedFormatting[question] = formatting.data()
+				updates = append(updates, firestore.Update{FieldPath: []string{"formatting", question}, Value: firestore.Delete})
+			}
+			if err := tx.Create(quizHistoryCollection(client, ownerID, quizName).NewDoc(), newHistoryDoc(editorID, editorName, "remove", question, answer)); err != nil {
+				return err
+			}
+		}
+
+		if len(removedData) == 0 {
+			return nil
+		}
+
+		updates = append(updates,
+			firestore.Update{Path: "numQns", Value: len(existing)},
+			firestore.Update{Path: "score", Value: "none"},
+		)
+		trashDoc := newTrashDoc("questions", quizName, removedData)
+		// media and formatting of the removed questions come back with them on restore
+		if len(removedMedia) > 0 {
+			trashDoc["media"] = removedMedia
+		}
+		if len(removedFormatting) > 0 {
+			trashDoc["formatting"] = removedFormatting
+		}
+		if err := tx.Create(trashCollection(client, ownerID).NewDoc(), trashDoc); err != nil {
+			return err
+		}
+		return tx.Update(quizRef, updates)
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, ownerID, quizName)
+	}
+	return err
+}
+
+// listTrash returns the user's trash entries, newest first, purging any that have expired
+func listTrash(ctx context.Context, client *firestore.Client, userID string) ([]trashEntry, error) {
+	var entries []trashEntry
+	now := time.Now()
+
+	iter := trashCollection(client, userID).OrderBy("deletedAt", firestore.Desc).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := newTrashEntry(doc.Ref, doc.Data())
+		if entry.expired(now) {
+			if _, err := doc.Ref.Delete(ctx); err != nil {
+				log.Printf("An error has occurred trying to purge trash entry %s: %s", doc.Ref.ID, err)
+			}
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// restoredQuizData returns the quiz with the trashed questions put back, or a new quiz of them if it no longer
+// exists. A question added again meanwhile is replaced by the trashed one, along with its media and formatting
+func restoredQuizData(quizData map[string]interface{}, entry trashEntry) map[string]interface{} {
+	restored := map[string]interface{}{}
+	for field, value := range quizData {
+		restored[field] = value
+	}
+
+	for question, answer := range entry.data {
+		restored[question] = answer
+	}
+	for _, field := range []string{"media", "formatting"} {
+		details := make(map[string]interface{})
+		if existing, ok := restored[field].(map[string]interface{}); ok {
+			for question, detail := range existing {
+				if _, trashed := entry.data[question]; !trashed {
+					details[question] = detail
+				}
+			}
+		}
+		if len(details) > 0 {
+			restored[field] = details
+		} else {
+			delete(restored, field)
+		}
+	}
+	mergeQuestionDetails(restored, "media", entry.media)
+	mergeQuestionDetails(restored, "formatting", entry.formatting)
+
+	restored["numQns"] = len(quizQuestions(restored))
+	restored["score"] = "none"
+	return restored
+}
+
+// restoreTrashEntry puts a trashed quiz or trashed questions back into the user's quizzes
+func restoreTrashEntry(ctx context.Context, client *firestore.Client, userID string, entry trashEntry) error {
+	quizRef := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(entry.quizName)
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(quizRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		switch entry.kind {
+		case "quiz":
+			if doc.Exists() {
+				return errQuizExists
+			}
+			if err := tx.Set(quizRef, entry.data); err != nil {
+				return err
+			}
+
+		case "questions":
+			var quizData map[string]interface{}
+			if doc.Exists() {
+				quizData = doc.Data()
+			}
+			if err := tx.Set(quizRef, restoredQuizData(quizData, entry)); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unknown trash entry kind %q", entry.kind)
+		}
+
+		return tx.Delete(entry.ref)
+	})
+	if err == nil {
+		updateCatalogEntry(ctx, client, userID, entry.quizName)
+	}
+	return err
+}
+
+func trashEntryDescription(chatID int64, entry trashEntry) string {
+	if entry.kind == "quiz" {
+		return tr(chatID, "quiz <strong>%s</strong>", escapeHTML(entry.quizName))
+	}
+
+	return trn(chatID, len(entry.data), "%d question from <strong>%s</strong>", "%d questions from <strong>%s</strong>",
+		len(entry.data), escapeHTML(entry.quizName))
+}
+
+// trashList lists the user's trash entries by number, for /restore
+func trashList(chatID int64, entries []trashEntry) *pagedList {
+	if len(entries) == 0 {
+		return newPagedList(chatID, tr(chatID, "Your trash is empty."), nil, "")
+	}
+
+	var lines []string
+	for i, entry := range entries {
+		daysLeft := int(time.Until(entry.expiresAt).Hours()/24) + 1
+		lines = append(lines, fmt.Sprint(i+1)+". "+trashEntryDescription(chatID, entry)+
+			trn(chatID, daysLeft, " (deleted %s, %d day left)", " (deleted %s, %d days left)", entry.deletedAt.Format("2 Jan 15:04"), daysLeft))
+	}
+	return newPagedList(chatID, tr(chatID, "Here is your trash: \n"), lines,
+		tr(chatID, "\nUse <strong>/restore <i>number</i></strong> to restore an item, "+
+			"or <strong>/undo</strong> to restore the most recent one."))
+}
+
+func sendRestoreResult(chatID int64, bot *tgbotapi.BotAPI, entry trashEntry, err error) {
+	msg := tgbotapi.NewMessage(chatID, "")
+	msg.ParseMode = "HTML"
+
+	if err == nil {
+		msg.Text = tr(chatID, "Restored %s.", trashEntryDescription(chatID, entry))
+	} else if errors.Is(err, errQuizExists) {
+		msg.Text = tr(chatID, "A quiz named <strong>%s</strong> already exists. Delete or rename it before restoring.",
+			escapeHTML(entry.quizName))
+	} else {
+		log.Printf("An error has occurred trying to restore trash entry: %s", err)
+		msg.Text = tr(chatID, "Sorry, the item could not be restored. Please try again.")
+	}
+
+	queueMessage(bot, msg.ChatID, msg)
+}
+
+// mergeQuestionDetails puts restored per-question details, such as media, back into the quiz's field for them
+func mergeQuestionDetails(quizData map[string]interface{}, field string, details map[string]interface{}) {
+	if len(details) == 0 {
+		return
+	}
+	merged, _ := quizData[field].(map[string]interface{})
+	if merged == nil {
+		merged = make(map[string]interface{})
+	}
+	for question, detail := range details {
+		merged[question] = detail
+	}
+	quizData[field] = merged
+}
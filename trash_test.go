@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrashEntryDescription(t *testing.T) {
+	quizEntry := trashEntry{kind: "quiz", quizName: "demo quiz"}
+	outputStr := trashEntryDescription(1, quizEntry)
+
+	if outputStr != "quiz <strong>demo quiz</strong>" {
+		t.Error("Expected: quiz <strong>demo quiz</strong> but got: " + outputStr)
+	}
+
+	qnsEntry := trashEntry{
+		kind:     "questions",
+		quizName: "demo quiz",
+		data:     map[string]interface{}{"qn 1": "ans 1", "qn 2": "ans 2"},
+	}
+	outputStr = trashEntryDescription(1, qnsEntry)
+
+	if outputStr != "2 questions from <strong>demo quiz</strong>" {
+		t.Error("Expected: 2 questions from <strong>demo quiz</strong> but got: " + outputStr)
+	}
+}
+
+func TestRestoredQuizData(t *testing.T) {
+	entry := trashEntry{
+		kind:     "questions",
+		quizName: "demo quiz",
+		data:     map[string]interface{}{"qn 1": "ans 1", "qn 2": "ans 2"},
+		media:    map[string]interface{}{"qn 1": questionAttachments{question: questionMedia{kind: "photo", fileID: "file1"}}.data()},
+	}
+
+	// into an existing quiz, whose own questions and media stay
+	quizData := map[string]interface{}{
+		"numQns": int64(1),
+		"score":  "2/3",
+		"qn 3":   "ans 3",
+		"media":  map[string]interface{}{"qn 3": questionAttachments{question: questionMedia{kind: "voice", fileID: "file3"}}.data()},
+	}
+	restored := restoredQuizData(quizData, entry)
+	if restored["numQns"] != 3 || restored["score"] != "none" || restored["qn 1"] != "ans 1" || restored["qn 3"] != "ans 3" {
+		t.Errorf("Expected the questions to be added to the quiz but got: %v", restored)
+	}
+	if media := quizAttachments(restored); media["qn 1"].question.fileID != "file1" || media["qn 3"].question.fileID != "file3" {
+		t.Errorf("Expected the media of both questions but got: %v", media)
+	}
+	if _, changed := quizData["qn 1"]; changed {
+		t.Errorf("Expected the loaded quiz not to be changed")
+	}
+
+	// into a quiz that no longer exists
+	restored = restoredQuizData(nil, entry)
+	if restored["numQns"] != 2 || restored["qn 2"] != "ans 2" {
+		t.Errorf("Expected a new quiz of the questions but got: %v", restored)
+	}
+	if _, found := restored["formatting"]; found {
+		t.Errorf("Expected no formatting field without formatting but got: %v", restored)
+	}
+
+	// a question added again meanwhile is replaced, dropping the media it was added with
+	quizData = map[string]interface{}{
+		"numQns": int64(1),
+		"qn 2":   "new ans 2",
+		"media":  map[string]interface{}{"qn 2": questionAttachments{question: questionMedia{kind: "voice", fileID: "file2"}}.data()},
+	}
+	restored = restoredQuizData(quizData, entry)
+	if restored["numQns"] != 2 || restored["qn 2"] != "ans 2" {
+		t.Errorf("Expected the trashed question to replace the new one but got: %v", restored)
+	}
+	if _, found := quizAttachments(restored)["qn 2"]; found {
+		t.Errorf("Expected the media of the replaced question to be dropped but got: %v", restored["media"])
+	}
+}
+
+func TestTrashEntryExpired(t *testing.T) {
+	entry := newTrashEntry(nil, newTrashDoc("questions", "demo quiz", map[string]interface{}{"qn 1": "ans 1"}))
+	deletedAt := entry.deletedAt
+
+	if entry.kind != "questions" || entry.quizName != "demo quiz" || entry.data["qn 1"] != "ans 1" {
+		t.Errorf("Expected the saved entry but got: %+v", entry)
+	}
+	if entry.expired(deletedAt.Add(trashRetention - time.Minute)) {
+		t.Errorf("Expected the entry to be kept until the retention period is over")
+	}
+	if !entry.expired(deletedAt.Add(trashRetention + time.Minute)) {
+		t.Errorf("Expected the entry to be purged after the retention period")
+	}
+}
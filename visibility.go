@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// who can try a quiz besides its owner, private unless the owner changes it
+const (
+	visibilityPrivate = "private" // only the owner
+	visibilityShared  = "shared"  // users and groups in sharedWith, and anyone with a share link
+	visibilityPublic  = "public"  // anyone
+)
+
+func quizVisibility(quizData map[string]interface{}) string {
+	switch visibility, _ := quizData["visibility"].(string); visibility {
+	case visibilityShared, visibilityPublic:
+		return visibility
+	}
+	return visibilityPrivate
+}
+
+func quizSharedWith(quizData map[string]interface{}) []string {
+	var sharedWith []string
+	if ids, ok := quizData["sharedWith"].([]interface{}); ok {
+		for _, id := range ids {
+			if idStr, ok := id.(string); ok {
+				sharedWith = append(sharedWith, idStr)
+			}
+		}
+	}
+	return sharedWith
+}
+
+// canReadQuiz reports whether the user may try the quiz from the given chat, checked on every read of another user's quiz
+func canReadQuiz(bot *tgbotapi.BotAPI, quizData map[string]interface{}, ownerID string, userID string, chatID int64) bool {
+	if ownerID == userID {
+		return true
+	}
+
+	switch quizVisibility(quizData) {
+	case visibilityPublic:
+		return true
+
+	case visibilityShared:
+		for _, id := range quizSharedWith(quizData) {
+			if id == userID || id == fmt.Sprint(chatID) {
+				return true
+			}
+
+			// quizzes shared with a group can be tried by its members from any chat
+			if groupID, err := strconv.ParseInt(id, 10, 64); err == nil && groupID < 0 {
+				memberID, _ := strconv.ParseInt(userID, 10, 64)
+				member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+					ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: groupID, UserID: memberID},
+				})
+				if err == nil && !member.HasLeft() && !member.WasKicked() {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// canReadSharedQuiz reports whether a share link for the quiz may be used
+func canReadSharedQuiz(quizData map[string]interface{}) bool {
+	return quizVisibility(quizData) != visibilityPrivate
+}
+
+func setQuizVisibility(ctx context.Context, client *firestore.Client, userID string, quizName string, visibility string, sharedWith []string) error {
+	updates := []firestore.Update{
+		{
+			Path:  "visibility",
+			Value: visibility,
+		},
+	}
+	if sharedWith != nil {
+		updates = append(updates, firestore.Update{
+			Path:  "sharedWith",
+			Value: sharedWith,
+		})
+	}
+
+	_, err := client.Collection("USERS").Doc(userID).Collection("QUIZZES").Doc(quizName).Update(ctx, updates)
+	if err == nil {
+		updateCatalogEntry(ctx, client, userID, quizName)
+	}
+	return err
+}
+
+// resolveShareTargets turns user IDs, @usernames and group chat IDs separated by commas or spaces into IDs,
+// along with the inputs that could not be resolved
+func resolveShareTargets(ctx context.Context, client *firestore.Client, input string) ([]string, []string, error) {
+	var ids []string
+	var unresolved []string
+
+	targets := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+	for _, target := range targets {
+		if _, err := strconv.ParseInt(target, 10, 64); err == nil {
+			ids = append(ids, target)
+			continue
+		}
+
+		docs, err := client.Collection("USERS").Where("username", "==", strings.TrimPrefix(target, "@")).Limit(1).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(docs) == 0 {
+			unresolved = append(unresolved, target)
+			continue
+		}
+		ids = append(ids, docs[0].Ref.ID)
+	}
+
+	return ids, unresolved, nil
+}
+
+func visibilityDescription(chatID int64, visibility string, sharedWith []string) string {
+	switch visibility {
+	case visibilityPublic:
+		return tr(chatID, "public, anyone can try it")
+	case visibilityShared:
+		if len(sharedWith) == 0 {
+			return tr(chatID, "shared with anyone who has its share link")
+		}
+		return tr(chatID, "shared with %s and anyone who has its share link", strings.Join(sharedWith, ", "))
+	}
+	return tr(chatID, "private, only you can try it")
+}
+
+func visibilityKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnPrivate),
+			newActionButton(chatID, btnShared),
+			newActionButton(chatID, btnPublic),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			newActionButton(chatID, btnCancel),
+		),
+	)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQuizVisibilityDefaultsToPrivate(t *testing.T) {
+	if visibility := quizVisibility(map[string]interface{}{"numQns": int64(1)}); visibility != visibilityPrivate {
+		t.Error("Expected: private but got: " + visibility)
+	}
+
+	if visibility := quizVisibility(map[string]interface{}{"visibility": "everyone"}); visibility != visibilityPrivate {
+		t.Error("Expected: private but got: " + visibility)
+	}
+}
+
+func TestCanReadQuiz(t *testing.T) {
+	privateQuiz := map[string]interface{}{"visibility": visibilityPrivate}
+	publicQuiz := map[string]interface{}{"visibility": visibilityPublic}
+	sharedQuiz := map[string]interface{}{
+		"visibility": visibilityShared,
+		"sharedWith": []interface{}{"222"},
+	}
+
+	if !canReadQuiz(nil, privateQuiz, "111", "111", 111) {
+		t.Error("Expected the owner to read their private quiz")
+	}
+	if canReadQuiz(nil, privateQuiz, "111", "222", 222) {
+		t.Error("Expected other users not to read a private quiz")
+	}
+	if !canReadQuiz(nil, publicQuiz, "111", "333", 333) {
+		t.Error("Expected anyone to read a public quiz")
+	}
+	if !canReadQuiz(nil, sharedQuiz, "111", "222", 222) {
+		t.Error("Expected a user in sharedWith to read a shared quiz")
+	}
+	if canReadQuiz(nil, sharedQuiz, "111", "333", 333) {
+		t.Error("Expected a user not in sharedWith not to read a shared quiz")
+	}
+}